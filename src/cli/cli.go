@@ -1,27 +1,119 @@
 package cli
 
 import (
+	"docklett/compiler/evaluator"
+	"docklett/compiler/vars"
 	"flag"
 	"fmt"
 	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	CommandCompile = "compile"
+	CommandFmt     = "fmt"
+	CommandPreview = "preview"
+	CommandREPL    = "repl"
+)
+
+// Formats of -trace
+const (
+	TraceText = "text"
+	TraceJSON = "json"
 )
 
 type CommandLine struct {
+	Command  string
 	FilePath string
+	Strict   bool // redefining a @SET variable in the same scope is an error
+	// NoImplicitTruthiness requires @IF/@ELIF conditions and && / || operands to be bool
+	NoImplicitTruthiness bool
+	// StrictInterpolation rejects ${name} references that are neither Docklett variables nor ARG/ENV names
+	StrictInterpolation bool
+	ContextRoot         string        // -context: build context for the filesystem built-ins
+	AllowEnv            []string      // -allow-env: host environment variables env() may read
+	ReportPath          string        // -report: where to write the JSON compile report
+	Sandbox             bool          // -sandbox: compile or preview with the limits for files from an untrusted source
+	Timeout             time.Duration // -timeout: stop compiling or previewing after this long, zero waits forever
+	Trace               string        // -trace: TraceText or TraceJSON to log each decision of the translation, empty for none
+	// Vars are the build variables of -var-file and -var; a -var wins over a file, a later file over an earlier one
+	Vars     map[string]any
+	varFlags map[string]any // -var values, applied after every file
+
+	// fmt
+	Paths []string
+	Write bool // -w: write the result back to the source file
+	List  bool // -l: list files whose formatting differs
+	Diff  bool // -d: print a diff instead of the formatted source
+
+	// preview
+	AST bool // -ast: print the residual program as a tree instead of Docklett source
 }
 
 func NewCommandLine() *CommandLine {
-	return &CommandLine{}
+	return &CommandLine{Command: CommandCompile}
 }
 
-func (c *CommandLine) ParseArgs() error {
-	flag.StringVar(&c.FilePath, "file", "", "Path to Dockerfile or Docklett file")
-	flag.StringVar(&c.FilePath, "F", "", "Path to Dockerfile or Docklett file (shorthand)")
-	flag.Parse()
+// ParseArgs parses the arguments after the program name, e.g. os.Args[1:].
+//
+//	docklett [-strict] [-strict-interpolation] [-no-implicit-truthiness] [-context <dir>]
+//	         [-allow-env <names>]... [-report <path>] [-sandbox] [-timeout <duration>] [-trace text|json]
+//	         [-var NAME=VALUE]... [-var-file <path>]... -file <path>                                compile
+//	docklett fmt [-w] [-l] [-d] [path ...]
+//	docklett preview [-ast] [-sandbox] [-timeout <duration>] [-var NAME=VALUE]... [-var-file <path>]... <path>
+//	docklett repl [-var NAME=VALUE]... [-var-file <path>]...
+func (c *CommandLine) ParseArgs(args []string) error {
+	if len(args) > 0 && args[0] == CommandFmt {
+		c.Command = CommandFmt
+		return c.parseFmtArgs(args[1:])
+	}
+	if len(args) > 0 && args[0] == CommandPreview {
+		c.Command = CommandPreview
+		return c.parsePreviewArgs(args[1:])
+	}
+	if len(args) > 0 && args[0] == CommandREPL {
+		c.Command = CommandREPL
+		return c.parseREPLArgs(args[1:])
+	}
+
+	flags := flag.NewFlagSet("docklett", flag.ContinueOnError)
+	flags.StringVar(&c.FilePath, "file", "", "Path to Dockerfile or Docklett file")
+	flags.StringVar(&c.FilePath, "F", "", "Path to Dockerfile or Docklett file (shorthand)")
+	flags.BoolVar(&c.Strict, "strict", false, "Report redefinition of a @SET variable in the same scope as an error")
+	flags.BoolVar(&c.StrictInterpolation, "strict-interpolation", false, "Report ${name} references that are not Docklett variables or ARG/ENV names")
+	flags.BoolVar(&c.NoImplicitTruthiness, "no-implicit-truthiness", false, "Require conditions and && / || operands to be bool")
+	flags.StringVar(&c.ContextRoot, "context", "", "Build context read by file_exists, glob, read_file, ... (default: directory of the file)")
+	flags.Func("allow-env", "Comma-separated environment variables env() may read, e.g. CI_*,BUILD_NUMBER (repeatable)", func(list string) error {
+		for _, name := range strings.Split(list, ",") {
+			name = strings.TrimSpace(name)
+			if _, err := path.Match(name, ""); name == "" || err != nil {
+				return fmt.Errorf("invalid variable name or pattern %q", name)
+			}
+			c.AllowEnv = append(c.AllowEnv, name)
+		}
+		return nil
+	})
+	flags.StringVar(&c.ReportPath, "report", "", "Write the files and environment variables the compilation read to this JSON file")
+	flags.BoolVar(&c.Sandbox, "sandbox", false, "Limit loops, nesting, output and value sizes, and deny file and environment access, for untrusted files")
+	flags.DurationVar(&c.Timeout, "timeout", 0, "Stop compiling after this long, e.g. 5s (default: no limit)")
+	flags.Func("trace", "Print each condition, loop iteration, interpolation and instruction to stdout as text or json", func(format string) error {
+		if format != TraceText && format != TraceJSON {
+			return fmt.Errorf("trace format must be %s or %s, got %q", TraceText, TraceJSON, format)
+		}
+		c.Trace = format
+		return nil
+	})
+	c.defineVarFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	c.applyVarFlags()
 
 	if c.FilePath == "" {
-		if flag.NArg() > 0 {
-			c.FilePath = flag.Arg(0)
+		if flags.NArg() > 0 {
+			c.FilePath = flags.Arg(0)
 		} else {
 			return fmt.Errorf("file path is required")
 		}
@@ -30,6 +122,107 @@ func (c *CommandLine) ParseArgs() error {
 	if _, err := os.Stat(c.FilePath); os.IsNotExist(err) {
 		return fmt.Errorf("file does not exist: %s", c.FilePath)
 	}
+	if c.ContextRoot != "" {
+		if info, err := os.Stat(c.ContextRoot); err != nil || !info.IsDir() {
+			return fmt.Errorf("build context is not a directory: %s", c.ContextRoot)
+		}
+	}
+
+	return nil
+}
+
+// Limits returns the evaluation limits asked for on the command line: SandboxLimits with -sandbox,
+// none otherwise.
+func (c *CommandLine) Limits() evaluator.Limits {
+	if c.Sandbox {
+		return evaluator.SandboxLimits
+	}
+	return evaluator.Limits{}
+}
+
+func (c *CommandLine) parseFmtArgs(args []string) error {
+	flags := flag.NewFlagSet("docklett fmt", flag.ContinueOnError)
+	flags.BoolVar(&c.Write, "w", false, "Write result to (source) file instead of stdout")
+	flags.BoolVar(&c.List, "l", false, "List files whose formatting differs from docklett fmt's")
+	flags.BoolVar(&c.Diff, "d", false, "Display diffs instead of rewriting files")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	c.Paths = flags.Args()
+
+	if c.Write && len(c.Paths) == 0 {
+		return fmt.Errorf("cannot use -w with standard input")
+	}
+	for _, path := range c.Paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return fmt.Errorf("file does not exist: %s", path)
+		}
+	}
+	return nil
+}
+
+func (c *CommandLine) parsePreviewArgs(args []string) error {
+	flags := flag.NewFlagSet("docklett preview", flag.ContinueOnError)
+	flags.BoolVar(&c.AST, "ast", false, "Print the residual program as a syntax tree")
+	flags.BoolVar(&c.Sandbox, "sandbox", false, "Limit loops, nesting and value sizes while folding, for untrusted files")
+	flags.DurationVar(&c.Timeout, "timeout", 0, "Stop previewing after this long, e.g. 5s (default: no limit)")
+	c.defineVarFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	c.applyVarFlags()
+	if flags.NArg() != 1 {
+		return fmt.Errorf("preview needs exactly one file path")
+	}
+	c.FilePath = flags.Arg(0)
+
+	if _, err := os.Stat(c.FilePath); os.IsNotExist(err) {
+		return fmt.Errorf("file does not exist: %s", c.FilePath)
+	}
+	return nil
+}
 
+func (c *CommandLine) parseREPLArgs(args []string) error {
+	flags := flag.NewFlagSet("docklett repl", flag.ContinueOnError)
+	c.defineVarFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	c.applyVarFlags()
+	if flags.NArg() != 0 {
+		return fmt.Errorf("repl takes no arguments, got %q", flags.Args())
+	}
 	return nil
 }
+
+// defineVarFlags adds -var and -var-file to flags. Files are read as they are parsed, so a bad
+// file is reported like any other flag error.
+func (c *CommandLine) defineVarFlags(flags *flag.FlagSet) {
+	c.Vars = make(map[string]any)
+	c.varFlags = make(map[string]any)
+	flags.Func("var", "Set a build variable, e.g. MODE=prod or PORT=8080, overriding its @DEFAULT (repeatable)", func(text string) error {
+		name, val, err := vars.ParseAssignment(text)
+		if err != nil {
+			return err
+		}
+		c.varFlags[name] = val
+		return nil
+	})
+	flags.Func("var-file", "Read build variables from a .json, .yaml/.yml or .env file (repeatable)", func(path string) error {
+		fileVars, err := vars.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for name, val := range fileVars {
+			c.Vars[name] = val
+		}
+		return nil
+	})
+}
+
+// applyVarFlags lays the -var values over the ones read from files.
+func (c *CommandLine) applyVarFlags() {
+	for name, val := range c.varFlags {
+		c.Vars[name] = val
+	}
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+type diffLine struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// unifiedDiff returns a unified diff between two texts, or "" when they are equal.
+// Files are small, so a plain LCS table is good enough.
+func unifiedDiff(name, before, after string) string {
+	a, b := splitLines(before), splitLines(after)
+	lines := diffLines(a, b)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s.orig\n+++ %s\n", name, name)
+	hunks := 0
+	for start := 0; start < len(lines); {
+		// find the next change
+		first := start
+		for first < len(lines) && lines[first].kind == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		// extend the hunk while changes are close enough to share context
+		from := max(first-diffContext, start)
+		to := first
+		for i := first; i < len(lines); i++ {
+			if lines[i].kind != ' ' {
+				to = i + 1
+			} else if i-to >= 2*diffContext {
+				break
+			}
+		}
+		to = min(to+diffContext, len(lines))
+
+		writeHunk(&sb, lines, from, to)
+		hunks++
+		start = to
+	}
+	if hunks == 0 {
+		return ""
+	}
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, lines []diffLine, from, to int) {
+	// line numbers are 1-based; count the lines of each side before the hunk
+	aStart, bStart := 1, 1
+	for _, l := range lines[:from] {
+		if l.kind != '+' {
+			aStart++
+		}
+		if l.kind != '-' {
+			bStart++
+		}
+	}
+	aCount, bCount := 0, 0
+	for _, l := range lines[from:to] {
+		if l.kind != '+' {
+			aCount++
+		}
+		if l.kind != '-' {
+			bCount++
+		}
+	}
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+	for _, l := range lines[from:to] {
+		sb.WriteByte(l.kind)
+		sb.WriteString(l.text)
+		sb.WriteByte('\n')
+	}
+}
+
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{'+', b[j]})
+	}
+	return lines
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"bytes"
+	"docklett/compiler/format"
+	"fmt"
+	"io"
+	"os"
+)
+
+// RunFmt formats the files in c.Paths, or standard input when no path is given, like gofmt:
+//
+//	(no flag)  print the formatted source
+//	-l         print the names of files whose formatting differs
+//	-d         print a unified diff between the file and its formatted source
+//	-w         write the formatted source back to the file
+//
+// Files that fail to parse are reported on stderr and the remaining files are still processed.
+func (c *CommandLine) RunFmt(stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(c.Paths) == 0 {
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		return c.formatFile("<standard input>", src, stdout)
+	}
+
+	failed := false
+	for _, path := range c.Paths {
+		src, err := os.ReadFile(path)
+		if err == nil {
+			err = c.formatFile(path, src, stdout)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		return fmt.Errorf("formatting failed")
+	}
+	return nil
+}
+
+func (c *CommandLine) formatFile(name string, src []byte, stdout io.Writer) error {
+	formatted, err := format.Source(name, src)
+	if err != nil {
+		return err
+	}
+
+	changed := !bytes.Equal(src, formatted)
+	if c.List && changed {
+		fmt.Fprintln(stdout, name)
+	}
+	if c.Write && changed {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, formatted, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if c.Diff && changed {
+		fmt.Fprint(stdout, unifiedDiff(name, string(src), string(formatted)))
+	}
+	if !c.List && !c.Write && !c.Diff {
+		_, err = stdout.Write(formatted)
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"bytes"
+	"docklett/compiler/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	tidySource   = "@SET MODE = \"prod\"\nRUN echo ${MODE}\n"
+	messySource  = "@set MODE = \"prod\"\nrun echo ${MODE}\n"
+	brokenSource = "@IF MODE ==\nRUN echo\n"
+)
+
+// writeFmtFiles creates a formatted, an unformatted and an unparsable file in a new directory.
+func writeFmtFiles(t *testing.T) (tidy, messy, broken string) {
+	t.Helper()
+	dir := t.TempDir()
+	tidy = filepath.Join(dir, "tidy.dock")
+	messy = filepath.Join(dir, "messy.dock")
+	broken = filepath.Join(dir, "broken.dock")
+	for path, src := range map[string]string{tidy: tidySource, messy: messySource, broken: brokenSource} {
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tidy, messy, broken
+}
+
+func runFmt(t *testing.T, stdin string, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+	c := NewCommandLine()
+	if err := c.ParseArgs(append([]string{CommandFmt}, args...)); err != nil {
+		t.Fatalf("ParseArgs(%v): %v", args, err)
+	}
+	var out, errOut bytes.Buffer
+	err = c.RunFmt(strings.NewReader(stdin), &out, &errOut)
+	return out.String(), errOut.String(), err
+}
+
+func TestRunFmt(t *testing.T) {
+	formatted, err := format.Source("messy.dock", []byte(messySource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(formatted) != tidySource {
+		t.Fatalf("messySource formats to %q, want %q", formatted, tidySource)
+	}
+
+	t.Run("print", func(t *testing.T) {
+		tidy, messy, _ := writeFmtFiles(t)
+		stdout, stderr, err := runFmt(t, "", tidy, messy)
+		if err != nil || stderr != "" {
+			t.Fatalf("err = %v, stderr = %q", err, stderr)
+		}
+		if stdout != tidySource+tidySource {
+			t.Errorf("stdout = %q, want both files formatted", stdout)
+		}
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		stdout, _, err := runFmt(t, messySource)
+		if err != nil || stdout != tidySource {
+			t.Errorf("stdout = %q, err = %v, want %q", stdout, err, tidySource)
+		}
+	})
+
+	t.Run("-l", func(t *testing.T) {
+		tidy, messy, _ := writeFmtFiles(t)
+		stdout, _, err := runFmt(t, "", "-l", tidy, messy)
+		if err != nil || stdout != messy+"\n" {
+			t.Errorf("stdout = %q, err = %v, want only %s", stdout, err, messy)
+		}
+		if src, _ := os.ReadFile(messy); string(src) != messySource {
+			t.Errorf("-l changed %s", messy)
+		}
+	})
+
+	t.Run("-d", func(t *testing.T) {
+		tidy, messy, _ := writeFmtFiles(t)
+		stdout, _, err := runFmt(t, "", "-d", tidy, messy)
+		if err != nil {
+			t.Fatalf("err = %v", err)
+		}
+		want := "--- " + messy + ".orig\n+++ " + messy + "\n"
+		if !strings.HasPrefix(stdout, want) {
+			t.Errorf("stdout = %q, want a diff of %s only", stdout, messy)
+		}
+		for _, line := range []string{"-@set MODE = \"prod\"\n", "+@SET MODE = \"prod\"\n", "-run echo ${MODE}\n", "+RUN echo ${MODE}\n"} {
+			if !strings.Contains(stdout, line) {
+				t.Errorf("diff has no line %q:\n%s", line, stdout)
+			}
+		}
+		if src, _ := os.ReadFile(messy); string(src) != messySource {
+			t.Errorf("-d changed %s", messy)
+		}
+	})
+
+	t.Run("-w", func(t *testing.T) {
+		tidy, messy, _ := writeFmtFiles(t)
+		stdout, _, err := runFmt(t, "", "-w", tidy, messy)
+		if err != nil || stdout != "" {
+			t.Fatalf("stdout = %q, err = %v, want no output", stdout, err)
+		}
+		for _, path := range []string{tidy, messy} {
+			if src, _ := os.ReadFile(path); string(src) != tidySource {
+				t.Errorf("%s = %q after -w, want %q", path, src, tidySource)
+			}
+		}
+	})
+
+	t.Run("-l -w", func(t *testing.T) {
+		tidy, messy, _ := writeFmtFiles(t)
+		stdout, _, err := runFmt(t, "", "-l", "-w", tidy, messy)
+		if err != nil || stdout != messy+"\n" {
+			t.Errorf("stdout = %q, err = %v, want only %s", stdout, err, messy)
+		}
+		if src, _ := os.ReadFile(messy); string(src) != tidySource {
+			t.Errorf("%s not rewritten", messy)
+		}
+	})
+}
+
+func TestRunFmt_Errors(t *testing.T) {
+	for _, mode := range []string{"", "-l", "-d", "-w"} {
+		t.Run("mode "+mode, func(t *testing.T) {
+			tidy, messy, broken := writeFmtFiles(t)
+			args := []string{broken, messy, tidy}
+			if mode != "" {
+				args = append([]string{mode}, args...)
+			}
+			_, stderr, err := runFmt(t, "", args...)
+			if err == nil || err.Error() != "formatting failed" {
+				t.Errorf("err = %v, want formatting failed", err)
+			}
+			if !strings.Contains(stderr, "Unexpected token") || strings.Count(stderr, "Compile Error") != 1 {
+				t.Errorf("stderr = %q, want one error, for %s", stderr, broken)
+			}
+			// the files after the broken one are still processed
+			if mode == "-w" {
+				if src, _ := os.ReadFile(messy); string(src) != tidySource {
+					t.Errorf("%s not rewritten after the error", messy)
+				}
+			}
+			if src, _ := os.ReadFile(broken); string(src) != brokenSource {
+				t.Errorf("%s changed", broken)
+			}
+		})
+	}
+
+	t.Run("stdin", func(t *testing.T) {
+		stdout, _, err := runFmt(t, brokenSource)
+		if err == nil || stdout != "" {
+			t.Errorf("stdout = %q, err = %v, want an error and no output", stdout, err)
+		}
+	})
+
+	t.Run("-w without files", func(t *testing.T) {
+		c := NewCommandLine()
+		err := c.ParseArgs([]string{CommandFmt, "-w"})
+		if err == nil || err.Error() != "cannot use -w with standard input" {
+			t.Errorf("ParseArgs = %v, want cannot use -w with standard input", err)
+		}
+	})
+}
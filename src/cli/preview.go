@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"context"
+	"docklett/compiler/format"
+	"docklett/compiler/parser"
+	"docklett/compiler/partial"
+	"docklett/compiler/scanner"
+	"fmt"
+	"io"
+)
+
+// RunPreview partially evaluates c.FilePath and prints the residual program: every decision that
+// does not depend on a variable bound inside the file is already taken, everything else is left open.
+//
+//	(no flag)  print the residual program as Docklett source
+//	-ast       print it as a syntax tree
+//	-sandbox   fold and unroll within evaluator.SandboxLimits
+//	-timeout   stop folding and unrolling after this long
+func (c *CommandLine) RunPreview(stdout io.Writer) error {
+	s := &scanner.Scanner{}
+	if err := s.ReadSource(c.FilePath); err != nil {
+		return err
+	}
+	if err := s.ScanSource(); err != nil {
+		return err
+	}
+	statements, err := (&parser.Parser{}).Parse(s.Tokens)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	residual, err := partial.EvaluateContext(ctx, statements, c.Vars, c.Limits())
+	if err != nil {
+		return err
+	}
+
+	if c.AST {
+		tree, err := parser.NewTreePrinter().Sprint(residual)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(stdout, tree)
+		return err
+	}
+	_, err = fmt.Fprint(stdout, format.Statements(residual))
+	return err
+}
@@ -0,0 +1,255 @@
+package cli
+
+import (
+	"bufio"
+	"docklett/compiler/ast"
+	"docklett/compiler/builtin"
+	"docklett/compiler/format"
+	"docklett/compiler/parser"
+	"docklett/compiler/scanner"
+	"docklett/compiler/scope"
+	"docklett/compiler/token"
+	"docklett/compiler/translator"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	replPrompt         = "docklett> "
+	replContinuePrompt = "...       "
+)
+
+const replHelp = `Enter an expression to print its value, or directives and Docker instructions to run them.
+Input continues over several lines until every @IF and @FOR is closed by @END.
+Variables persist between inputs; the filesystem built-ins read the current directory.
+
+  :vars            list the variables in scope
+  :tokens <input>  print the tokens of the input
+  :ast <input>     print the syntax tree of the input
+  :emit <input>    print the Docker instructions the input would produce, keeping none of its variables
+  :emit            print the Docker instructions produced so far
+  :help            show this help
+  :quit            leave (or end the input)
+`
+
+// RunREPL reads Docklett input from stdin and runs each complete input against one persistent
+// scope, printing expression values and meta-command output to stdout and errors to stderr:
+//
+//	docklett> @SET PKGS = ["curl", "git"]
+//	docklett> [upper(p) for p in PKGS]
+//	["CURL", "GIT"]
+//	docklett> :emit @FOR p IN PKGS
+//	...       RUN apk add ${p}
+//	...       @END
+//	RUN apk add curl
+//	RUN apk add git
+func (c *CommandLine) RunREPL(stdin io.Reader, stdout, stderr io.Writer) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	r := &repl{translator: translator.NewTranslator(), stdout: stdout, stderr: stderr}
+	r.translator.Host = builtin.NewHost(dir)
+	r.translator.SetVars(c.Vars)
+
+	lines := bufio.NewScanner(stdin)
+	var pending []string // lines of an input still waiting for its @END
+	fmt.Fprint(stdout, replPrompt)
+	for lines.Scan() {
+		pending = append(pending, lines.Text())
+		input := strings.Join(pending, "\n")
+		if _, source := splitMetaCommand(input); unclosedDirectives(source) {
+			fmt.Fprint(stdout, replContinuePrompt)
+			continue
+		}
+		pending = nil
+		if quit := r.handle(input); quit {
+			return nil
+		}
+		fmt.Fprint(stdout, replPrompt)
+	}
+	fmt.Fprintln(stdout)
+	return lines.Err()
+}
+
+type repl struct {
+	translator *translator.Translator // its Scope holds the session's variables
+	stdout     io.Writer
+	stderr     io.Writer
+}
+
+// handle runs one complete input and reports whether the session should end.
+func (r *repl) handle(input string) bool {
+	command, source := splitMetaCommand(input)
+	switch command {
+	case "":
+		r.run(source)
+	case ":quit", ":q", ":exit":
+		return true
+	case ":help":
+		fmt.Fprint(r.stdout, replHelp)
+	case ":vars":
+		r.printVars()
+	case ":tokens":
+		r.printTokens(source)
+	case ":ast":
+		r.printAST(source)
+	case ":emit":
+		r.emit(source)
+	default:
+		fmt.Fprintf(r.stderr, "unknown command %s, try :help\n", command)
+	}
+	return false
+}
+
+// run executes source in the session scope. Input that parses as an expression is evaluated and
+// its value printed; anything else, such as a directive or a Docker instruction, is translated.
+func (r *repl) run(source string) {
+	if strings.TrimSpace(source) == "" {
+		return
+	}
+	expr, exprErr := parser.ParseExpression(source, token.Position{Line: 1, Col: 1})
+	if exprErr == nil {
+		val, err := r.translator.Evaluate(expr)
+		if err != nil {
+			fmt.Fprintln(r.stderr, err)
+			return
+		}
+		fmt.Fprintln(r.stdout, format.Value(val))
+		return
+	}
+	statements, err := parse(source)
+	if err != nil {
+		if !strings.HasPrefix(strings.TrimSpace(source), "@") {
+			err = exprErr // most likely an expression with a mistake, not a broken directive
+		}
+		fmt.Fprintln(r.stderr, err)
+		return
+	}
+	r.translate(statements)
+}
+
+// emit prints the instructions source produces, translating it in a child scope so its
+// declarations are dropped afterwards; without source it prints every instruction so far.
+func (r *repl) emit(source string) {
+	if strings.TrimSpace(source) == "" {
+		for _, instruction := range r.translator.Instructions() {
+			fmt.Fprintln(r.stdout, instruction)
+		}
+		return
+	}
+	statements, err := parse(source)
+	if err != nil {
+		fmt.Fprintln(r.stderr, err)
+		return
+	}
+	session := r.translator.Scope
+	r.translator.Scope = scope.New(session)
+	defer func() { r.translator.Scope = session }()
+
+	before := len(r.translator.Instructions())
+	r.translate(statements)
+	for _, instruction := range r.translator.Instructions()[before:] {
+		fmt.Fprintln(r.stdout, instruction)
+	}
+}
+
+func (r *repl) translate(statements []ast.Statement) {
+	_ = r.translator.Translate(statements)
+	for _, err := range r.translator.Errors() {
+		fmt.Fprintln(r.stderr, err)
+	}
+}
+
+func (r *repl) printVars() {
+	visible := r.translator.Scope.Visible()
+	names := make([]string, 0, len(visible))
+	for name := range visible {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(r.stdout, "%s = %s\n", name, format.Value(visible[name]))
+	}
+}
+
+func (r *repl) printTokens(source string) {
+	tokens, err := scan(source)
+	if err != nil {
+		fmt.Fprintln(r.stderr, err)
+		return
+	}
+	for _, tok := range tokens {
+		fmt.Fprintf(r.stdout, "%d:%d\t%-14s %q\n", tok.Line, tok.Col, token.TokenTypeNames[tok.Type], tok.Lexeme)
+	}
+}
+
+func (r *repl) printAST(source string) {
+	statements, err := parse(source)
+	if err != nil {
+		fmt.Fprintln(r.stderr, err)
+		return
+	}
+	tree, err := parser.NewTreePrinter().Sprint(statements)
+	if err != nil {
+		fmt.Fprintln(r.stderr, err)
+		return
+	}
+	fmt.Fprint(r.stdout, tree)
+}
+
+// scan reads source the way a file is read, so a name only becomes a keyword after an @.
+func scan(source string) ([]token.Token, error) {
+	s := scanner.Scanner{Source: source + "\n"}
+	if err := s.ScanSource(); err != nil {
+		return nil, err
+	}
+	return s.Tokens, nil
+}
+
+func parse(source string) ([]ast.Statement, error) {
+	tokens, err := scan(source)
+	if err != nil {
+		return nil, err
+	}
+	return (&parser.Parser{}).Parse(tokens)
+}
+
+// splitMetaCommand separates a leading ":command" from the input it applies to.
+func splitMetaCommand(input string) (command, source string) {
+	trimmed := strings.TrimSpace(input)
+	if !strings.HasPrefix(trimmed, ":") {
+		return "", input
+	}
+	end := strings.IndexFunc(trimmed, unicode.IsSpace)
+	if end < 0 {
+		return trimmed, ""
+	}
+	return trimmed[:end], trimmed[end+1:]
+}
+
+// unclosedDirectives reports whether source opens more @IF and @FOR blocks than it closes with
+// @END. Input that does not scan counts as complete, so its error is reported right away.
+func unclosedDirectives(source string) bool {
+	tokens, err := scan(source)
+	if err != nil {
+		return false
+	}
+	open := 0
+	for _, tok := range tokens {
+		if !strings.HasPrefix(tok.Lexeme, "@") {
+			continue // "for" in a comprehension is not a directive
+		}
+		switch tok.Type {
+		case token.IF, token.FOR:
+			open++
+		case token.END:
+			open--
+		}
+	}
+	return open > 0
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// runREPL feeds input to a new session and returns what it printed, without the prompts.
+func runREPL(t *testing.T, input string) (stdout, stderr string) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	if err := NewCommandLine().RunREPL(strings.NewReader(input), &out, &errOut); err != nil {
+		t.Fatalf("RunREPL: %v", err)
+	}
+	stdout = strings.TrimSuffix(out.String(), replPrompt+"\n") // the end of input
+	stdout = strings.ReplaceAll(stdout, replPrompt, "")
+	stdout = strings.ReplaceAll(stdout, replContinuePrompt, "")
+	return stdout, errOut.String()
+}
+
+func TestRunREPL(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		stdout string
+		stderr string
+	}{
+		{
+			"expression",
+			"1 + 2\n",
+			"3\n",
+			"",
+		},
+		{
+			"variables persist",
+			"@SET PKGS = [\"curl\", \"git\"]\n[upper(p) for p in PKGS]\n",
+			"[\"CURL\", \"GIT\"]\n",
+			"",
+		},
+		{
+			"multi-line input until @END",
+			"@SET N = 0\n@FOR i IN range(0, 3)\n@IF i > 0\nN = N + i\n@END\n@END\nN\n",
+			"3\n",
+			"",
+		},
+		{
+			"comprehension is not a block",
+			"[x for x in [1, 2] if x > 1]\n",
+			"[2]\n",
+			"",
+		},
+		{
+			":vars",
+			"@SET B = 2\n@SET A = \"x\"\n:vars\n",
+			"A = \"x\"\nB = 2\n",
+			"",
+		},
+		{
+			":tokens",
+			":tokens @SET X = 1\n",
+			"1:1\tSET            \"@SET\"\n" +
+				"1:6\tIDENTIFIER     \"X\"\n" +
+				"1:8\tASSIGN         \"=\"\n" +
+				"1:10\tNUMBER         \"1\"\n" +
+				"1:11\tNEW_LINE       \"\\n\"\n" +
+				"2:1\tEOF            \"\"\n",
+			"",
+		},
+		{
+			":ast",
+			":ast @SET X = 1\n",
+			"Program\n" +
+				"└─[0]: VariableDeclaration\n" +
+				"  ├─Keyword: SET [@SET] @Line:1,Col:1\n" +
+				"  ├─Name: IDENTIFIER [X] @Line:1,Col:6 (Literal: X)\n" +
+				"  └─Initializer: LiteralExpression\n" +
+				"    └─Value: 1\n",
+			"",
+		},
+		{
+			":emit of multi-line input keeps no variables",
+			":emit @FOR p IN [1, 2]\n@SET DOUBLE = p * 2\nRUN echo ${DOUBLE}\n@END\n@SET X = 1\n:vars\n",
+			"RUN echo 2\nRUN echo 4\nX = 1\n",
+			"",
+		},
+		{
+			":emit without input",
+			"FROM alpine\n@SET TAG = \"v1\"\nLABEL tag=${TAG}\n:emit\n",
+			"FROM alpine\nLABEL tag=v1\n",
+			"",
+		},
+		{
+			"errors do not end the session",
+			"1 +\nUNDEFINED\n@FOR i IN 3\n@END\n@SET X = 1\nX * 2\n",
+			"2\n",
+			"Compile Error: [line 1] Unexpected token \n" +
+				"Compile Error: [line 1] undefined variable 'UNDEFINED'\n" +
+				"Compile Error: [line 1] for loop iterable must be an array or range, got int\n",
+		},
+		{
+			"meta-command errors",
+			":nope\n:tokens \"open\n:ast @SET = 1\n1\n",
+			"1\n",
+			"unknown command :nope, try :help\n" +
+				"Compile Error: [line 1] unterminated string literal\n" +
+				"Compile Error: [line 1] ",
+		},
+		{
+			":quit ends the session",
+			"1\n:quit\n2\n",
+			"1\n",
+			"",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stdout, stderr := runREPL(t, tc.input)
+			if stdout != tc.stdout {
+				t.Errorf("stdout = %q, want %q", stdout, tc.stdout)
+			}
+			if !strings.HasPrefix(stderr, tc.stderr) || (tc.stderr == "") != (stderr == "") {
+				t.Errorf("stderr = %q, want %q", stderr, tc.stderr)
+			}
+		})
+	}
+}
+
+func TestRunREPL_Prompts(t *testing.T) {
+	var out bytes.Buffer
+	input := "@IF TRUE\nRUN echo a\n@END\n1\n"
+	if err := NewCommandLine().RunREPL(strings.NewReader(input), &out, &bytes.Buffer{}); err != nil {
+		t.Fatalf("RunREPL: %v", err)
+	}
+	want := replPrompt + replContinuePrompt + replContinuePrompt + replPrompt + "1\n" + replPrompt + "\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Package analysis implements the semantic warnings pass. Warnings never stop compilation; they point at
+code that is legal but almost certainly not what the author meant.
+
+CODES are stable and may be used to filter or suppress warnings in tooling:
+
+	W001 unused-variable       @SET/@CONST/@DEFAULT binding that is never read or interpolated
+	W002 write-only-variable   binding that is assigned after its declaration but never read
+	W003 constant-condition    @IF/@ELIF condition without variables or filesystem reads, so one branch is dead
+	W004 empty-loop            @FOR over an empty array literal or an empty range
+	W005 duplicate-condition   @ELIF arm with the same condition as an earlier arm of its chain
+
+EXAMPLES:
+
+	@SET DEBUG = TRUE             W001: variable 'DEBUG' is declared but never used
+	@IF 1 > 2                     W003: condition is always false; the @IF body is dead
+	@FOR p IN range(0, 0)         W004: @FOR loop over an empty range never runs
+	@IF MODE == "prod"
+	@ELIF MODE == "prod"          W005: condition duplicates the one at line 4; this arm can never run
+	@END
+
+Reads are taken from the resolver's references, including ${name} in Docker arguments, so Analyze
+needs the Resolution of the same statements.
+*/
+package analysis
+
+import (
+	"context"
+	"docklett/compiler/ast"
+	"docklett/compiler/builtin"
+	"docklett/compiler/evaluator"
+	"docklett/compiler/format"
+	"docklett/compiler/resolver"
+	"docklett/compiler/scope"
+	"docklett/compiler/token"
+	"docklett/compiler/value"
+	"fmt"
+)
+
+// Code identifies a kind of warning. Codes never change meaning once released.
+type Code string
+
+const (
+	UnusedVariable     Code = "W001"
+	WriteOnlyVariable  Code = "W002"
+	ConstantCondition  Code = "W003"
+	EmptyLoop          Code = "W004"
+	DuplicateCondition Code = "W005"
+)
+
+// Warning is one non-blocking diagnostic.
+type Warning struct {
+	Code    Code
+	Pos     token.Position // first character of the offending code
+	End     token.Position // one past its last character
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("Warning %s: [line %d, column %d] %s", w.Code, w.Pos.Line, w.Pos.Col, w.Message)
+}
+
+// Analyze returns every warning for statements in source order of discovery:
+// binding warnings first (by declaration), then control-flow warnings (by position).
+func Analyze(statements []ast.Statement, resolution *resolver.Resolution) []Warning {
+	return AnalyzeContext(context.Background(), statements, resolution, evaluator.Limits{})
+}
+
+// AnalyzeContext analyzes like Analyze, evaluating constant conditions and loops within limits and
+// only until ctx ends, like the compilation it is part of. What cannot be evaluated in time or
+// within the limits gets no warning.
+func AnalyzeContext(ctx context.Context, statements []ast.Statement, resolution *resolver.Resolution, limits evaluator.Limits) []Warning {
+	a := &analyzer{evaluator: evaluator.New(scope.New(nil))}
+	a.evaluator.Limits, a.evaluator.Context = limits, ctx
+	var warnings []Warning
+	if resolution != nil {
+		warnings = append(warnings, unusedBindings(resolution)...)
+	}
+	for _, stmt := range statements {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.IfStatement:
+				warnings = append(warnings, a.ifChain(n)...)
+			case *ast.ForStatement:
+				if w, ok := a.emptyLoop(n); ok {
+					warnings = append(warnings, w)
+				}
+			}
+			return true
+		})
+	}
+	return warnings
+}
+
+// analyzer evaluates the constant expressions of one program; its evaluator's limits and counters
+// span all of them.
+type analyzer struct {
+	evaluator *evaluator.Evaluator
+}
+
+// unusedBindings reports @SET, @CONST and @DEFAULT declarations without reads.
+// Loop variables are skipped: "@FOR i IN range(0, 3)" is a common way to repeat a block.
+func unusedBindings(resolution *resolver.Resolution) []Warning {
+	var warnings []Warning
+	for _, decl := range resolution.Declarations {
+		if decl.Kind != resolver.Variable && decl.Kind != resolver.Constant && decl.Kind != resolver.Default {
+			continue
+		}
+		reads, writes := 0, 0
+		for _, ref := range decl.References {
+			if _, ok := ref.(*ast.AssignmentExpression); ok {
+				writes++
+			} else {
+				reads++
+			}
+		}
+		if reads > 0 {
+			continue
+		}
+		w := Warning{Code: UnusedVariable, Pos: decl.Name.Position, End: decl.Name.End(),
+			Message: fmt.Sprintf("variable '%s' is declared but never used", decl.Name.Lexeme)}
+		if writes > 0 {
+			w.Code = WriteOnlyVariable
+			w.Message = fmt.Sprintf("variable '%s' is assigned but never read", decl.Name.Lexeme)
+		}
+		warnings = append(warnings, w)
+	}
+	return warnings
+}
+
+// ifChain checks one @IF statement: its condition, and for the head of a chain, repeated @ELIF conditions.
+func (a *analyzer) ifChain(stmt *ast.IfStatement) []Warning {
+	var warnings []Warning
+	directive := "@" + token.TokenTypeNames[stmt.Open.Type]
+
+	if val, ok := a.constant(stmt.Condition); ok {
+		truthy := value.Truthy(val)
+		dead := fmt.Sprintf("the %s body is dead", directive)
+		if truthy {
+			dead = "every later branch is dead"
+			if stmt.ElseBranch == nil {
+				dead = "the check is redundant"
+			}
+		}
+		warnings = append(warnings, Warning{Code: ConstantCondition, Pos: stmt.Condition.Pos(), End: stmt.Condition.End(),
+			Message: fmt.Sprintf("condition is always %t; %s", truthy, dead)})
+	}
+
+	if stmt.Open.Type != token.IF {
+		return warnings
+	}
+	seen := make(map[string]int) // canonical condition → line of first arm
+	for arm := stmt; arm != nil; {
+		text := format.Expression(arm.Condition)
+		if line, ok := seen[text]; ok {
+			warnings = append(warnings, Warning{Code: DuplicateCondition, Pos: arm.Condition.Pos(), End: arm.Condition.End(),
+				Message: fmt.Sprintf("condition duplicates the one at line %d; this arm can never run", line)})
+		} else {
+			seen[text] = arm.Condition.Pos().Line
+		}
+		arm, _ = arm.ElseBranch.(*ast.IfStatement)
+	}
+	return warnings
+}
+
+// emptyLoop reports a @FOR whose iterable is known to be empty without running the program.
+func (a *analyzer) emptyLoop(stmt *ast.ForStatement) (Warning, bool) {
+	val, ok := a.constant(stmt.Iterable)
+	if arr, isArray := val.([]any); !ok || !isArray || len(arr) > 0 {
+		return Warning{}, false
+	}
+	kind := "array"
+	if _, isRange := stmt.Iterable.(*ast.RangeExpression); isRange {
+		kind = "range"
+	}
+	return Warning{Code: EmptyLoop, Pos: stmt.Iterable.Pos(), End: stmt.Iterable.End(),
+		Message: fmt.Sprintf("@FOR loop over an empty %s never runs", kind)}, true
+}
+
+// constant evaluates expr if it does not reference any variable. Expressions that fail to
+// evaluate are left to the evaluator to report.
+func (a *analyzer) constant(expr ast.Expression) (any, bool) {
+	if expr == nil {
+		return nil, false
+	}
+	hasVariables := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.VariableExpression, *ast.AssignmentExpression:
+			hasVariables = true
+		case *ast.CallExpression:
+			// file_exists("go.mod") depends on the build context, not only on the source
+			if f, ok := builtin.Lookup(n.Callee.Lexeme); ok && !f.Pure() {
+				hasVariables = true
+			}
+		}
+		return !hasVariables
+	})
+	if hasVariables {
+		return nil, false
+	}
+	val, err := a.evaluator.Evaluate(expr)
+	return val, err == nil
+}
@@ -0,0 +1,122 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"docklett/compiler/ast"
+	"docklett/compiler/evaluator"
+	"docklett/compiler/parser"
+	"docklett/compiler/resolver"
+	"docklett/compiler/scanner"
+)
+
+func analyzeSource(t *testing.T, source string) []Warning {
+	t.Helper()
+	statements, resolution := resolveSource(t, source)
+	return Analyze(statements, resolution)
+}
+
+func resolveSource(t *testing.T, source string) ([]ast.Statement, *resolver.Resolution) {
+	t.Helper()
+
+	s := scanner.Scanner{SourceName: "test.dock", Source: source}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan source: %v", err)
+	}
+	var p parser.Parser
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	resolution, err := resolver.Resolve(statements, "MODE")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	return statements, resolution
+}
+
+func summarize(warnings []Warning) []string {
+	var got []string
+	for _, w := range warnings {
+		got = append(got, fmt.Sprintf("%s@%d:%d %s", w.Code, w.Pos.Line, w.Pos.Col, w.Message))
+	}
+	return got
+}
+
+func TestAnalyze_Warnings(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"used in expression", "@SET A = 1\n@SET B = A\nRUN echo ${B}\n", nil},
+		{"unused variable", "@SET DEBUG = TRUE\n", []string{"W001@1:6 variable 'DEBUG' is declared but never used"}},
+		{"unused constant", "@CONST BASE = \"alpine\"\n", []string{"W001@1:8 variable 'BASE' is declared but never used"}},
+		{"used only in docker args", "@SET TAG = \"1\"\nFROM alpine:${TAG}\n", nil},
+		{"shadowed outer unused", "@SET X = 1\n@IF MODE\n@SET X = 2\nRUN echo ${X}\n@END\n",
+			[]string{"W001@1:6 variable 'X' is declared but never used"}},
+		{"write only", "@SET N = 1\nN = 2\n", []string{"W002@1:6 variable 'N' is assigned but never read"}},
+		{"loop variable unused", "@FOR i IN range(0, 2)\nRUN echo hi\n@END\n", nil},
+		{"constant true with else", "@IF 1 < 2\nRUN echo a\n@ELSE\nRUN echo b\n@END\n",
+			[]string{"W003@1:5 condition is always true; every later branch is dead"}},
+		{"constant false", "@IF FALSE\nRUN echo a\n@END\n", []string{"W003@1:5 condition is always false; the @IF body is dead"}},
+		{"constant elif", "@IF MODE == \"a\"\n@ELIF \"x\" == \"y\"\n@END\n",
+			[]string{"W003@2:7 condition is always false; the @ELIF body is dead"}},
+		{"variable condition", "@IF MODE\n@END\n", nil},
+		{"filesystem condition", "@IF file_exists(\"go.mod\")\n@END\n", nil},
+		{"pure call condition", "@IF upper(\"a\") == \"A\"\n@END\n", []string{"W003@1:5 condition is always true; the check is redundant"}},
+		{"empty array loop", "@FOR p IN []\n@END\n", []string{"W004@1:11 @FOR loop over an empty array never runs"}},
+		{"empty range loop", "@FOR p IN range(5, 0)\n@END\n", []string{"W004@1:11 @FOR loop over an empty range never runs"}},
+		{"nested empty loop", "@IF MODE\n@FOR p IN range(0, 0)\n@END\n@END\n", []string{"W004@2:11 @FOR loop over an empty range never runs"}},
+		{"non-empty loop", "@FOR p IN [1]\n@END\n", nil},
+		{"duplicate elif", "@IF MODE == \"prod\"\n@ELIF MODE==\"dev\"\n@ELIF MODE == \"prod\"\n@END\n",
+			[]string{"W005@3:7 condition duplicates the one at line 1; this arm can never run"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := summarize(analyzeSource(t, test.source))
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("warnings = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeContext_Limits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name   string
+		source string
+		ctx    context.Context
+		limits evaluator.Limits
+		want   []string
+	}{
+		{"oversized range is not evaluated", "@IF len(range(0, 200000000)) > 0\n@END\n", context.Background(), evaluator.SandboxLimits, nil},
+		{"small range within limits", "@IF len(range(0, 3)) > 0\n@END\n", context.Background(), evaluator.SandboxLimits,
+			[]string{"W003@1:5 condition is always true; the check is redundant"}},
+		{"comprehension after cancel", "@IF len([1 for x in range(0, 3)]) > 5\n@END\n", canceled, evaluator.Limits{}, nil},
+		{"comprehension", "@IF len([1 for x in range(0, 3)]) > 5\n@END\n", context.Background(), evaluator.Limits{},
+			[]string{"W003@1:5 condition is always false; the @IF body is dead"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statements, resolution := resolveSource(t, test.source)
+			got := summarize(AnalyzeContext(test.ctx, statements, resolution, test.limits))
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("warnings = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWarning_String(t *testing.T) {
+	w := Warning{Code: EmptyLoop, Message: "@FOR loop over an empty array never runs"}
+	w.Pos.Line, w.Pos.Col = 3, 11
+	if got, want := w.String(), "Warning W004: [line 3, column 11] @FOR loop over an empty array never runs"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
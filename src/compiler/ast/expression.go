@@ -9,6 +9,10 @@ The expression hierarchy from atomic to complex:
 	binary:   BinaryExpression (+, -, *, /, ==, !=, <, >, <=, >=)
 	logic:    LogicalExpression(or / and)
 	assign:   AssignmentExpression (x = value)
+	call:     CallExpression (upper(name))
+	map:      MapLiteralExpression ({"os": "linux"})
+	derived:  ComprehensionExpression ([p + "-dev" for p in pkgs if p != "curl"])
+	member:   MemberExpression (semver(V).major)
 
 EXAMPLES:
 
@@ -29,6 +33,7 @@ import (
 )
 
 type Expression interface {
+	Node
 	Accept(visitor ExpressionVisitor) (any, error)
 }
 
@@ -42,6 +47,9 @@ func (ve *VariableExpression) Accept(visitor ExpressionVisitor) (any, error) {
 	return visitor.VisitVariableExpr(ve)
 }
 
+func (ve *VariableExpression) Pos() token.Position { return ve.Name.Position }
+func (ve *VariableExpression) End() token.Position { return ve.Name.End() }
+
 // LiteralExpression represents atomic constant values that directly produce a runtime value.
 // This is the terminal node in expression evaluation - it requires no further computation.
 type LiteralExpression struct {
@@ -53,6 +61,9 @@ func (le *LiteralExpression) Accept(visitor ExpressionVisitor) (any, error) {
 	return visitor.VisitLiteralExpr(le)
 }
 
+func (le *LiteralExpression) Pos() token.Position { return le.Token.Position }
+func (le *LiteralExpression) End() token.Position { return le.Token.End() }
+
 // UnaryExpression represents a prefix operator applied to a single operand.
 // Evaluation occurs right-to-left: first evaluate Right, then apply Operator.
 // Supported Operators:
@@ -67,16 +78,25 @@ func (u *UnaryExpression) Accept(visitor ExpressionVisitor) (any, error) {
 	return visitor.VisitUnaryExpr(u)
 }
 
+func (u *UnaryExpression) Pos() token.Position { return u.Operator.Position }
+func (u *UnaryExpression) End() token.Position { return u.Right.End() }
+
 // GroupingExpression represents a parenthesized expression that overrides operator precedence.
 // Parentheses force inner expression to evaluate first before outer operations.
 type GroupingExpression struct {
-	Expression // The wrapped expression to evaluate
+	Expression             // The wrapped expression to evaluate
+	LParen     token.Token // opening ( token
+	RParen     token.Token // closing ) token
 }
 
 func (g *GroupingExpression) Accept(visitor ExpressionVisitor) (any, error) {
 	return visitor.VisitGroupingExpr(g)
 }
 
+// Pos and End cover the parentheses, unlike the promoted methods of the wrapped expression.
+func (g *GroupingExpression) Pos() token.Position { return g.LParen.Position }
+func (g *GroupingExpression) End() token.Position { return g.RParen.End() }
+
 // two arbitrary values combined through + - * /
 type BinaryExpression struct {
 	Left     Expression  // Left operand expression
@@ -88,6 +108,9 @@ func (b *BinaryExpression) Accept(visitor ExpressionVisitor) (any, error) {
 	return visitor.VisitBinaryExpr(b)
 }
 
+func (b *BinaryExpression) Pos() token.Position { return b.Left.Pos() }
+func (b *BinaryExpression) End() token.Position { return b.Right.End() }
+
 type LogicalExpression struct {
 	Left     Expression  // Left operand, always evaluated
 	Right    Expression  // Right operand, conditionally evaluated
@@ -98,6 +121,9 @@ func (l *LogicalExpression) Accept(visitor ExpressionVisitor) (any, error) {
 	return visitor.VisitLogicalExpr(l)
 }
 
+func (l *LogicalExpression) Pos() token.Position { return l.Left.Pos() }
+func (l *LogicalExpression) End() token.Position { return l.Right.End() }
+
 // AssignmentExpression represents binding a value to an existing variable (not declaration).
 // This is a statement-like expression that produces a side effect AND returns a value. This returns value because AssignmentExpression lives inside an ExpressionStatement, and it's returned value would be brought to an effect
 // e.g ExpressionStatement(AssignmentExpression("x", 5)).
@@ -116,14 +142,18 @@ func (l *LogicalExpression) Accept(visitor ExpressionVisitor) (any, error) {
 //	AST: AssignmentExpression("x", BinaryExpression(VariableExpression(y), +, LiteralExpression(1)))
 //	Evaluation: Look up y → Add 1 → Update x binding → Return result
 type AssignmentExpression struct {
-	Name  token.Token // Identifier for the target variable
-	Value Expression  // Expression to evaluate and assign
+	Name   token.Token // Identifier for the target variable
+	Equals token.Token // = token
+	Value  Expression  // Expression to evaluate and assign
 }
 
 func (a *AssignmentExpression) Accept(visitor ExpressionVisitor) (any, error) {
 	return visitor.VisitAssignmentExpr(a)
 }
 
+func (a *AssignmentExpression) Pos() token.Position { return a.Name.Position }
+func (a *AssignmentExpression) End() token.Position { return a.Value.End() }
+
 // ArrayLiteralExpression represents an inline list of expressions.
 // Used as a ForStatement iterable: @FOR pkg IN ["curl", "git", "vim"]
 //
@@ -134,12 +164,63 @@ func (a *AssignmentExpression) Accept(visitor ExpressionVisitor) (any, error) {
 type ArrayLiteralExpression struct {
 	Bracket  token.Token  // opening [ token for error reporting
 	Elements []Expression // ordered list of element expressions
+	RBracket token.Token  // closing ] token
 }
 
 func (a *ArrayLiteralExpression) Accept(visitor ExpressionVisitor) (any, error) {
 	return visitor.VisitArrayLiteralExpr(a)
 }
 
+func (a *ArrayLiteralExpression) Pos() token.Position { return a.Bracket.Position }
+func (a *ArrayLiteralExpression) End() token.Position { return a.RBracket.End() }
+
+// MapLiteralExpression represents an inline map from string keys to values.
+// Keys and Values are parallel: entry i is Keys[i]: Values[i], in source order.
+//
+// Example:
+//
+//	Source:  {"os": OS, "arch": "arm64"}
+//	AST:    MapLiteralExpression{Keys: [Literal("os"), Literal("arch")], Values: [Variable(OS), Literal("arm64")]}
+type MapLiteralExpression struct {
+	Brace  token.Token  // opening { token for error reporting
+	Keys   []Expression // key expressions, must evaluate to strings
+	Values []Expression // value expressions, one per key
+	RBrace token.Token  // closing } token
+}
+
+func (m *MapLiteralExpression) Accept(visitor ExpressionVisitor) (any, error) {
+	return visitor.VisitMapLiteralExpr(m)
+}
+
+func (m *MapLiteralExpression) Pos() token.Position { return m.Brace.Position }
+func (m *MapLiteralExpression) End() token.Position { return m.RBrace.End() }
+
+// ComprehensionExpression builds an array from an iterable: the element expression is evaluated once
+// per item, in a scope of its own that binds the targets, and kept when the optional filter is truthy.
+//
+// Arrays and ranges bind their elements, or the index and the element with two targets. Maps bind
+// their keys in key order, or the key and the value with two targets.
+//
+// Example:
+//
+//	Source:  ["lib" + p + "-dev" for p in pkgs if p != "curl"]
+//	AST:    ComprehensionExpression{Element: Binary(...), Targets: [p], Iterable: Variable(pkgs), Filter: Binary(...)}
+type ComprehensionExpression struct {
+	Bracket  token.Token   // opening [ token
+	Element  Expression    // expression evaluated for each kept item
+	Targets  []token.Token // one or two loop variables
+	Iterable Expression    // array, range or map to iterate
+	Filter   Expression    // nil → every item is kept
+	RBracket token.Token   // closing ] token
+}
+
+func (c *ComprehensionExpression) Accept(visitor ExpressionVisitor) (any, error) {
+	return visitor.VisitComprehensionExpr(c)
+}
+
+func (c *ComprehensionExpression) Pos() token.Position { return c.Bracket.Position }
+func (c *ComprehensionExpression) End() token.Position { return c.RBracket.End() }
+
 // RangeExpression represents a range() call for generating integer sequences at compile time.
 // Used as a ForStatement iterable: @FOR i IN range(0, 5)
 //
@@ -156,12 +237,55 @@ func (a *ArrayLiteralExpression) Accept(visitor ExpressionVisitor) (any, error)
 //	range(0, 10, 2)  → [0, 2, 4, 6, 8]
 //	range(5, 0, -1)  → [5, 4, 3, 2, 1]
 type RangeExpression struct {
-	Token token.Token // RANGE keyword token for error reporting
-	Start Expression  // inclusive lower bound
-	End   Expression  // exclusive upper bound
-	Step  Expression  // nil → step of 1
+	Token  token.Token // RANGE keyword token for error reporting
+	Start  Expression  // inclusive lower bound
+	Stop   Expression  // exclusive upper bound
+	Step   Expression  // nil → step of 1
+	RParen token.Token // closing ) token
 }
 
 func (r *RangeExpression) Accept(visitor ExpressionVisitor) (any, error) {
 	return visitor.VisitRangeExpr(r)
 }
+
+func (r *RangeExpression) Pos() token.Position { return r.Token.Position }
+func (r *RangeExpression) End() token.Position { return r.RParen.End() }
+
+// CallExpression represents a call to a built-in function, see package builtin.
+// Only names can be called: Docklett has no user-defined functions or first-class function values.
+//
+// Example:
+//
+//	Source:  replace(TAG, "/", "-")
+//	AST:    CallExpression{Callee: replace, Arguments: [Variable(TAG), Literal("/"), Literal("-")]}
+type CallExpression struct {
+	Callee    token.Token  // identifier naming the function
+	Arguments []Expression // argument expressions, evaluated left to right
+	RParen    token.Token  // closing ) token
+}
+
+func (c *CallExpression) Accept(visitor ExpressionVisitor) (any, error) {
+	return visitor.VisitCallExpr(c)
+}
+
+func (c *CallExpression) Pos() token.Position { return c.Callee.Position }
+func (c *CallExpression) End() token.Position { return c.RParen.End() }
+
+// MemberExpression reads a named component of a value. Only versions have components so far.
+//
+// Example:
+//
+//	Source:  semver(NODE_VERSION).major
+//	AST:    MemberExpression{Object: CallExpression(semver, ...), Name: major}
+type MemberExpression struct {
+	Object Expression  // the value whose component is read
+	Dot    token.Token // "." token
+	Name   token.Token // identifier naming the component
+}
+
+func (m *MemberExpression) Accept(visitor ExpressionVisitor) (any, error) {
+	return visitor.VisitMemberExpr(m)
+}
+
+func (m *MemberExpression) Pos() token.Position { return m.Object.Pos() }
+func (m *MemberExpression) End() token.Position { return m.Name.End() }
@@ -0,0 +1,26 @@
+/*
+Node is the common interface of every expression and statement in the AST.
+
+Each node reports the source span it was parsed from:
+
+	Pos() → position of the first character belonging to the node
+	End() → position immediately after the last character belonging to the node
+
+Spans are derived from the tokens the parser stores on each node, so a node built by hand
+without tokens reports zero positions. Diagnostics use Pos() to point at the offending code
+regardless of which kind of node raised them.
+
+EXAMPLES:
+
+	@SET x = 1 + 2       → VariableDeclarationStatement spans "@SET" through "2"
+	(a + b)              → GroupingExpression spans "(" through ")"
+	@IF A ... @END       → IfStatement spans "@IF" through "@END"
+*/
+package ast
+
+import "docklett/compiler/token"
+
+type Node interface {
+	Pos() token.Position
+	End() token.Position
+}
@@ -0,0 +1,283 @@
+/*
+Rewrite traverses an AST and lets callers replace, delete or insert nodes in place,
+modelled on golang.org/x/tools/go/ast/astutil.Apply.
+
+For every node, pre is called before the children are traversed and post afterwards.
+Both receive a Cursor describing the node and where it sits in its parent:
+
+	Cursor.Node()         current node
+	Cursor.Parent()       node holding the current node (nil for the root)
+	Cursor.Name()         parent field name, e.g. "Left", "Statements"
+	Cursor.Index()        position inside a list field, or -1
+	Cursor.Replace(n)     swap the current node for n
+	Cursor.Delete()       remove the current node from its list
+	Cursor.InsertBefore() / InsertAfter()   add siblings to the current list
+
+Replacing a node in pre means the new node's children are traversed instead of the old ones.
+Nodes inserted with InsertBefore/InsertAfter are not traversed.
+
+EXAMPLE (fold every "x" reference into the literal 1):
+
+	ast.Rewrite(stmt, func(c *ast.Cursor) bool {
+		if v, ok := c.Node().(*ast.VariableExpression); ok && v.Name.Lexeme == "x" {
+			c.Replace(&ast.LiteralExpression{Value: 1, Token: v.Name})
+		}
+		return true
+	}, nil)
+
+A field typed as a concrete node (IfStatement.ThenBranch, ForStatement.Body) only accepts a
+*BlockStatement replacement; violating a field's type panics, like astutil.
+*/
+package ast
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A RewriteFunc is invoked by Rewrite for each non-nil node, before and/or after the node's children.
+// If pre returns false, no children are traversed and post is not called for the node.
+// If post returns false, the whole traversal stops.
+type RewriteFunc func(*Cursor) bool
+
+// A Cursor describes a node encountered during Rewrite.
+type Cursor struct {
+	parent  Node
+	name    string
+	index   int // -1 when the node is not part of a list
+	node    Node
+	replace func(Node)
+	list    *listEditor // nil when the node is not part of a list
+}
+
+// Node returns the current node.
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent returns the parent of the current node, nil for the root.
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Name returns the name of the parent field that contains the current node.
+func (c *Cursor) Name() string { return c.name }
+
+// Index reports the index of the current node in its parent's list, or -1 if it is not part of a list.
+func (c *Cursor) Index() int { return c.index }
+
+// Replace replaces the current node with n.
+func (c *Cursor) Replace(n Node) {
+	c.replace(n)
+	c.node = n
+}
+
+// Delete deletes the current node from its containing list.
+func (c *Cursor) Delete() {
+	c.mustList("Delete").delete()
+}
+
+// InsertBefore inserts n before the current node in its containing list.
+func (c *Cursor) InsertBefore(n Node) {
+	c.mustList("InsertBefore").insert(0, n)
+}
+
+// InsertAfter inserts n after the current node in its containing list.
+func (c *Cursor) InsertAfter(n Node) {
+	c.mustList("InsertAfter").insert(1, n)
+}
+
+func (c *Cursor) mustList(operation string) *listEditor {
+	if c.list == nil {
+		panic(fmt.Sprintf("ast.Rewrite: %s is only valid for nodes in a list (field %q)", operation, c.name))
+	}
+	return c.list
+}
+
+// listEditor mutates the list currently being iterated and keeps the iteration index consistent.
+type listEditor struct {
+	index  int // index of the current element
+	step   int // how far to advance after the current element
+	length func() int
+	remove func(i int)
+	add    func(i int, n Node)
+}
+
+func (l *listEditor) delete() {
+	l.remove(l.index)
+	l.step--
+}
+
+func (l *listEditor) insert(offset int, n Node) {
+	l.add(l.index+offset, n)
+	if offset == 0 {
+		l.index++
+	} else {
+		l.step++
+	}
+}
+
+// errAbort unwinds the traversal once a post function returns false.
+var errAbort = errors.New("ast.Rewrite aborted")
+
+type rewriter struct {
+	pre, post RewriteFunc
+}
+
+// Rewrite traverses root recursively, calling pre and post for each node, and returns the
+// possibly replaced root. Either function may be nil.
+func Rewrite(root Node, pre, post RewriteFunc) (result Node) {
+	var wrapper Node = root
+	r := &rewriter{pre: pre, post: post}
+	defer func() {
+		if p := recover(); p != nil && p != errAbort {
+			panic(p)
+		}
+		result = wrapper
+	}()
+	r.apply(nil, "Root", -1, nil, root, func(n Node) { wrapper = n })
+	return wrapper
+}
+
+// apply visits one node; replace writes a new node back into the parent field.
+func (r *rewriter) apply(parent Node, name string, index int, list *listEditor, node Node, replace func(Node)) {
+	if node == nil || isNilNode(node) {
+		return
+	}
+	cursor := &Cursor{parent: parent, name: name, index: index, node: node, replace: replace, list: list}
+	if r.pre != nil && !r.pre(cursor) {
+		return
+	}
+	r.applyChildren(cursor.node)
+	if r.post != nil && !r.post(cursor) {
+		panic(errAbort)
+	}
+}
+
+func (r *rewriter) applyExpr(parent Node, name string, field *Expression) {
+	r.apply(parent, name, -1, nil, *field, func(n Node) { *field = asExpression(n) })
+}
+
+func (r *rewriter) applyStmt(parent Node, name string, field *Statement) {
+	r.apply(parent, name, -1, nil, *field, func(n Node) { *field = asStatement(n) })
+}
+
+func (r *rewriter) applyBlock(parent Node, name string, field **BlockStatement) {
+	r.apply(parent, name, -1, nil, *field, func(n Node) {
+		block, ok := n.(*BlockStatement)
+		if !ok {
+			panic(fmt.Sprintf("ast.Rewrite: field %q requires *BlockStatement, got %T", name, n))
+		}
+		*field = block
+	})
+}
+
+// applyList traverses a list field, tolerating deletions and insertions made through the cursor.
+func applyList[T Node](r *rewriter, parent Node, name string, list *[]T, convert func(Node) T) {
+	editor := &listEditor{
+		length: func() int { return len(*list) },
+		remove: func(i int) { *list = append((*list)[:i], (*list)[i+1:]...) },
+		add: func(i int, n Node) {
+			*list = append(*list, convert(n))
+			copy((*list)[i+1:], (*list)[i:])
+			(*list)[i] = convert(n)
+		},
+	}
+	for editor.index < editor.length() {
+		editor.step = 1
+		i := editor.index
+		r.apply(parent, name, i, editor, (*list)[i], func(n Node) { (*list)[editor.index] = convert(n) })
+		editor.index += editor.step
+	}
+}
+
+func (r *rewriter) applyChildren(node Node) {
+	switch n := node.(type) {
+	// expressions
+	case *VariableExpression, *LiteralExpression:
+		// leaves
+	case *UnaryExpression:
+		r.applyExpr(n, "Right", &n.Right)
+	case *GroupingExpression:
+		r.applyExpr(n, "Expression", &n.Expression)
+	case *BinaryExpression:
+		r.applyExpr(n, "Left", &n.Left)
+		r.applyExpr(n, "Right", &n.Right)
+	case *LogicalExpression:
+		r.applyExpr(n, "Left", &n.Left)
+		r.applyExpr(n, "Right", &n.Right)
+	case *AssignmentExpression:
+		r.applyExpr(n, "Value", &n.Value)
+	case *ArrayLiteralExpression:
+		applyList(r, n, "Elements", &n.Elements, asExpression)
+	case *MapLiteralExpression:
+		// entries can be replaced but not deleted, so Keys and Values stay parallel
+		for i := range n.Keys {
+			r.apply(n, "Keys", i, nil, n.Keys[i], func(k Node) { n.Keys[i] = asExpression(k) })
+			r.apply(n, "Values", i, nil, n.Values[i], func(v Node) { n.Values[i] = asExpression(v) })
+		}
+	case *ComprehensionExpression:
+		r.applyExpr(n, "Element", &n.Element)
+		r.applyExpr(n, "Iterable", &n.Iterable)
+		r.applyExpr(n, "Filter", &n.Filter)
+	case *RangeExpression:
+		r.applyExpr(n, "Start", &n.Start)
+		r.applyExpr(n, "Stop", &n.Stop)
+		r.applyExpr(n, "Step", &n.Step)
+	case *CallExpression:
+		applyList(r, n, "Arguments", &n.Arguments, asExpression)
+	case *MemberExpression:
+		r.applyExpr(n, "Object", &n.Object)
+
+	// statements
+	case *ExpressionStatement:
+		r.applyExpr(n, "Expression", &n.Expression)
+	case *VariableDeclarationStatement:
+		r.applyExpr(n, "Initializer", &n.Initializer)
+	case *BlockStatement:
+		applyList(r, n, "Statements", &n.Statements, asStatement)
+	case *IfStatement:
+		r.applyExpr(n, "Condition", &n.Condition)
+		r.applyBlock(n, "ThenBranch", &n.ThenBranch)
+		r.applyStmt(n, "ElseBranch", &n.ElseBranch)
+	case *DockerStatement:
+		// leaf
+	case *ForStatement:
+		r.applyExpr(n, "Iterable", &n.Iterable)
+		r.applyBlock(n, "Body", &n.Body)
+	}
+}
+
+// RewriteStatements applies Rewrite to a top-level statement list, such as the Parser output.
+// Deletions and insertions at the top level are reflected in the returned slice.
+func RewriteStatements(statements []Statement, pre, post RewriteFunc) []Statement {
+	program := &BlockStatement{Statements: statements}
+	r := &rewriter{pre: pre, post: post}
+	func() {
+		defer func() {
+			if p := recover(); p != nil && p != errAbort {
+				panic(p)
+			}
+		}()
+		applyList(r, nil, "Statements", &program.Statements, asStatement)
+	}()
+	return program.Statements
+}
+
+func asExpression(n Node) Expression {
+	if n == nil {
+		return nil
+	}
+	expr, ok := n.(Expression)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T is not an Expression", n))
+	}
+	return expr
+}
+
+func asStatement(n Node) Statement {
+	if n == nil {
+		return nil
+	}
+	stmt, ok := n.(Statement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T is not a Statement", n))
+	}
+	return stmt
+}
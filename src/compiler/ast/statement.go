@@ -22,6 +22,7 @@ package ast
 import "docklett/compiler/token"
 
 type Statement interface {
+	Node
 	Accept(visitor StatementVisitor) (any, error)
 }
 
@@ -50,6 +51,9 @@ func (es *ExpressionStatement) Accept(visitor StatementVisitor) (any, error) {
 	return visitor.VisitExpressionStatement(es)
 }
 
+func (es *ExpressionStatement) Pos() token.Position { return es.Expression.Pos() }
+func (es *ExpressionStatement) End() token.Position { return es.Expression.End() }
+
 // VariableDeclarationStatement creates a new variable binding in the current scope.
 // This is a declaration that introduces a NEW identifier (unlike AssignmentExpression which updates existing).
 // Declarations create bindings without producing usable values and variables must be declared separately before they can be used (assigned).
@@ -73,24 +77,66 @@ func (es *ExpressionStatement) Accept(visitor StatementVisitor) (any, error) {
 //	Source: @SET y
 //	AST: VariableDeclarationStatement(Name=\"y\", Initializer=nil)
 //	Execution: Create binding y → Assign nil to y
+//
+// CONSTANTS:
+// @CONST NAME = expr uses the same node with a CONST keyword. A constant always has an initializer
+// and can never be reassigned, redefined or shadowed by an inner scope.
+//
+// DEFAULTS:
+// @DEFAULT NAME = expr declares a build variable: a --var NAME=... given to the compiler replaces the
+// initializer, which is then not evaluated. Defaults are only allowed at the top level of a file.
+//
+// TYPE ANNOTATIONS:
+// An optional type follows the name: @SET port: int = 8080. The type checker verifies the initializer
+// and later assignments against it, and unannotated bindings get the type inferred from their initializer.
 type VariableDeclarationStatement struct {
-	Name        token.Token // The identifier token for the new variable
-	Initializer Expression  // Expression to evaluate for initial value (nil for uninitialized)
+	Keyword     token.Token     // The @SET, @CONST or @DEFAULT directive token
+	Name        token.Token     // The identifier token for the new variable
+	Type        *TypeAnnotation // Declared type (nil when not annotated)
+	Initializer Expression      // Expression to evaluate for initial value (nil for uninitialized)
 }
 
 func (varStmt *VariableDeclarationStatement) Accept(visitor StatementVisitor) (any, error) {
 	return visitor.VisitVarDeclarationStatement(varStmt)
 }
 
+// IsConstant reports whether the declaration is an immutable @CONST binding.
+func (varStmt *VariableDeclarationStatement) IsConstant() bool {
+	return varStmt.Keyword.Type == token.CONST
+}
+
+// IsDefault reports whether the declaration is an overridable @DEFAULT binding.
+func (varStmt *VariableDeclarationStatement) IsDefault() bool {
+	return varStmt.Keyword.Type == token.DEFAULT
+}
+
+func (varStmt *VariableDeclarationStatement) Pos() token.Position { return varStmt.Keyword.Position }
+func (varStmt *VariableDeclarationStatement) End() token.Position {
+	if varStmt.Initializer != nil {
+		return varStmt.Initializer.End()
+	}
+	if varStmt.Type != nil {
+		return varStmt.Type.End()
+	}
+	return varStmt.Name.End()
+}
+
 // BlockStatement wraps a list of statements into a single Statement node.
+// Open and Close are the directives delimiting the block: the @IF, @ELIF, @ELSE or @FOR that starts it
+// and the @ELIF, @ELSE or @END that finishes it.
 type BlockStatement struct {
+	Open       token.Token // directive token opening the block
 	Statements []Statement
+	Close      token.Token // directive token closing the block
 }
 
 func (blStmt *BlockStatement) Accept(visitor StatementVisitor) (any, error) {
 	return visitor.VisitBlockStatement(blStmt)
 }
 
+func (blStmt *BlockStatement) Pos() token.Position { return blStmt.Open.Position }
+func (blStmt *BlockStatement) End() token.Position { return blStmt.Close.End() }
+
 // IfStatement represents a conditional branch in this structure: IF <Condition> THEN ... ELSE ...
 // THEN contains statements in the true branch, and ELSE contains statments in the false one.
 // ELIF is not a separate node type — it becomes a nested IfStatement linked as ElseBranch.
@@ -102,16 +148,23 @@ func (blStmt *BlockStatement) Accept(visitor StatementVisitor) (any, error) {
 //     The IfStatement is responsible for identifying its own statement boundaries. It treats
 //     everything between the condition and the next keyword (ELIF/ELSE/END)
 //     as a logical "Then" block.
+//
+// Open is the @IF (or @ELIF for a nested link) directive and Close is the @END shared by the whole chain.
 type IfStatement struct {
+	Open       token.Token     // @IF or @ELIF directive token
 	Condition  Expression      // Boolean guard — determines which branch executes
 	ThenBranch *BlockStatement // Instructions to run when Condition is true
 	ElseBranch Statement       // A link to the next IfStatement (ELIF) or a BlockStatement (ELSE).
+	Close      token.Token     // @END directive token closing the chain
 }
 
 func (iStmt *IfStatement) Accept(visitor StatementVisitor) (any, error) {
 	return visitor.VisitIfStatement(iStmt)
 }
 
+func (iStmt *IfStatement) Pos() token.Position { return iStmt.Open.Position }
+func (iStmt *IfStatement) End() token.Position { return iStmt.Close.End() }
+
 // DockerStatement represents a single vanilla Docker instruction.
 // The scanner splits each Docker line into DOCKER_KEYWORD + DOCKER_ARGS,
 // and the parser combines them into this structured node.
@@ -123,14 +176,23 @@ func (iStmt *IfStatement) Accept(visitor StatementVisitor) (any, error) {
 //
 // The Translator dispatches on Keyword.Lexeme to determine which LLB operation to construct.
 type DockerStatement struct {
-	Keyword token.Token // instruction verb: FROM, RUN, COPY, ENV, WORKDIR, etc.
-	Args    string      // raw argument text after the keyword, whitespace-trimmed
+	Keyword   token.Token // instruction verb: FROM, RUN, COPY, ENV, WORKDIR, etc.
+	Args      string      // raw argument text after the keyword, whitespace-trimmed
+	ArgsToken token.Token // DOCKER_ARGS token the Args were taken from
 }
 
 func (ds *DockerStatement) Accept(visitor StatementVisitor) (any, error) {
 	return visitor.VisitDockerStatement(ds)
 }
 
+func (ds *DockerStatement) Pos() token.Position { return ds.Keyword.Position }
+func (ds *DockerStatement) End() token.Position {
+	if ds.Args == "" {
+		return ds.Keyword.End()
+	}
+	return ds.ArgsToken.End()
+}
+
 // ForStatement is Docklett's Pythonic for loop: @FOR var IN iterable ... @END
 // The loop variable (Target) is bound in the current scope and rebound on each iteration.
 // After the loop completes, the target variable is removed from the scope.
@@ -149,11 +211,16 @@ func (ds *DockerStatement) Accept(visitor StatementVisitor) (any, error) {
 //	        @END
 //	AST:    ForStatement{Target: "pkg", Iterable: ArrayLiteralExpr, Body: BlockStatement}
 type ForStatement struct {
+	Open     token.Token // @FOR directive token
 	Target   token.Token // loop variable identifier
 	Iterable Expression  // ArrayLiteralExpression or RangeExpression
 	Body     *BlockStatement
+	Close    token.Token // @END directive token
 }
 
 func (fs *ForStatement) Accept(visitor StatementVisitor) (any, error) {
 	return visitor.VisitForStatement(fs)
 }
+
+func (fs *ForStatement) Pos() token.Position { return fs.Open.Position }
+func (fs *ForStatement) End() token.Position { return fs.Close.End() }
@@ -0,0 +1,40 @@
+package ast
+
+import "docklett/compiler/token"
+
+// TypeAnnotation is the optional type written after a declared name.
+// Annotations are not expressions: they are never evaluated, visited or rewritten, only read by the type checker.
+//
+//	@SET port: int = 8080            → TypeAnnotation{Name: int}
+//	@SET pkgs: [string] = ["curl"]   → TypeAnnotation{Element: TypeAnnotation{Name: string}}
+type TypeAnnotation struct {
+	Name     token.Token     // type name (int, float, string, bool, any); zero for array types
+	LBracket token.Token     // "[" of an array type
+	Element  *TypeAnnotation // element type of an array type, nil otherwise
+	RBracket token.Token     // "]" of an array type
+}
+
+// IsArray reports whether the annotation is an array type [T].
+func (ta *TypeAnnotation) IsArray() bool { return ta.Element != nil }
+
+// String returns the annotation as written in canonical form, e.g. "[string]".
+func (ta *TypeAnnotation) String() string {
+	if ta.IsArray() {
+		return "[" + ta.Element.String() + "]"
+	}
+	return ta.Name.Lexeme
+}
+
+func (ta *TypeAnnotation) Pos() token.Position {
+	if ta.IsArray() {
+		return ta.LBracket.Position
+	}
+	return ta.Name.Position
+}
+
+func (ta *TypeAnnotation) End() token.Position {
+	if ta.IsArray() {
+		return ta.RBracket.End()
+	}
+	return ta.Name.End()
+}
@@ -17,6 +17,9 @@
 	Usage:
 		expressionNode.Accept(printer) -> "1 + 2"
 		expressionNode.Accept(interpreter) -> 3
+
+	Passes that only care about a few node kinds (collecting variable uses, folding constants)
+	don't need a full visitor: see Walk/Inspect in walk.go and Rewrite in rewrite.go.
 */
 
 package ast
@@ -30,11 +33,14 @@ type ExpressionVisitor interface {
 	VisitLogicalExpr(logical *LogicalExpression) (any, error)
 	VisitAssignmentExpr(assignment *AssignmentExpression) (any, error)
 	VisitArrayLiteralExpr(array *ArrayLiteralExpression) (any, error)
+	VisitMapLiteralExpr(mapLiteral *MapLiteralExpression) (any, error)
+	VisitComprehensionExpr(comprehension *ComprehensionExpression) (any, error)
 	VisitRangeExpr(rangeExpr *RangeExpression) (any, error)
+	VisitCallExpr(call *CallExpression) (any, error)
+	VisitMemberExpr(member *MemberExpression) (any, error)
 }
 
 type StatementVisitor interface {
-	VisitStatement(statement *Statement) (any, error)
 	VisitExpressionStatement(expressionStatement *ExpressionStatement) (any, error)
 	VisitVarDeclarationStatement(varDeclareStatement *VariableDeclarationStatement) (any, error)
 	VisitBlockStatement(blockStatement *BlockStatement) (any, error)
@@ -0,0 +1,153 @@
+/*
+Generic AST traversal, modelled on go/ast.
+
+Passes that only care about a few node kinds should not have to implement the full
+ExpressionVisitor and StatementVisitor interfaces. Walk and Inspect visit every node in
+depth-first source order and let the caller decide which nodes matter.
+
+ORDER:
+A node is visited before its children (pre-order). Once all children are done, the visitor
+is called again with nil, which marks the post-order point of the node:
+
+	@SET x = a + 1
+	Inspect order: VariableDeclarationStatement, BinaryExpression, VariableExpression(a), nil,
+	               LiteralExpression(1), nil, nil (Binary), nil (Declaration)
+
+USAGE:
+
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		if v, ok := n.(*ast.VariableExpression); ok {
+			uses = append(uses, v.Name.Lexeme)
+		}
+		return true
+	})
+
+Walk never modifies the tree. Use Rewrite to replace, delete or insert nodes.
+*/
+package ast
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children of node with w,
+// followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order starting at node.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	for _, child := range Children(node) {
+		Walk(v, child)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: it starts by calling f(node); node must not be nil.
+// If f returns true, Inspect invokes f recursively for each of the non-nil children of node,
+// followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// InspectPost calls f for every node after all of its children have been visited (post-order).
+// Useful for bottom-up passes such as folding, where children must be handled before their parent.
+func InspectPost(node Node, f func(Node)) {
+	for _, child := range Children(node) {
+		InspectPost(child, f)
+	}
+	f(node)
+}
+
+// Children returns the direct, non-nil child nodes of node in source order.
+// Optional fields that are absent (a missing initializer, step or else branch) are skipped.
+func Children(node Node) []Node {
+	var children []Node
+	add := func(child Node) {
+		if child != nil && !isNilNode(child) {
+			children = append(children, child)
+		}
+	}
+
+	switch n := node.(type) {
+	// expressions
+	case *VariableExpression, *LiteralExpression:
+		// leaves
+	case *UnaryExpression:
+		add(n.Right)
+	case *GroupingExpression:
+		add(n.Expression)
+	case *BinaryExpression:
+		add(n.Left)
+		add(n.Right)
+	case *LogicalExpression:
+		add(n.Left)
+		add(n.Right)
+	case *AssignmentExpression:
+		add(n.Value)
+	case *ArrayLiteralExpression:
+		for _, element := range n.Elements {
+			add(element)
+		}
+	case *MapLiteralExpression:
+		for i := range n.Keys {
+			add(n.Keys[i])
+			add(n.Values[i])
+		}
+	case *ComprehensionExpression:
+		add(n.Element)
+		add(n.Iterable)
+		add(n.Filter)
+	case *RangeExpression:
+		add(n.Start)
+		add(n.Stop)
+		add(n.Step)
+	case *CallExpression:
+		for _, arg := range n.Arguments {
+			add(arg)
+		}
+	case *MemberExpression:
+		add(n.Object)
+
+	// statements
+	case *ExpressionStatement:
+		add(n.Expression)
+	case *VariableDeclarationStatement:
+		add(n.Initializer)
+	case *BlockStatement:
+		for _, stmt := range n.Statements {
+			add(stmt)
+		}
+	case *IfStatement:
+		add(n.Condition)
+		add(n.ThenBranch)
+		add(n.ElseBranch)
+	case *DockerStatement:
+		// leaf
+	case *ForStatement:
+		add(n.Iterable)
+		add(n.Body)
+	}
+	return children
+}
+
+// isNilNode reports whether an interface holds a typed nil pointer, e.g. a nil *BlockStatement.
+func isNilNode(node Node) bool {
+	switch n := node.(type) {
+	case *BlockStatement:
+		return n == nil
+	case *IfStatement:
+		return n == nil
+	}
+	return false
+}
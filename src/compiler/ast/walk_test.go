@@ -0,0 +1,145 @@
+package ast_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"docklett/compiler/ast"
+	"docklett/compiler/parser"
+	"docklett/compiler/scanner"
+	"docklett/compiler/token"
+)
+
+func parseSource(t *testing.T, source string) []ast.Statement {
+	t.Helper()
+
+	s := scanner.Scanner{SourceName: "test.dock", Source: source}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan source: %v", err)
+	}
+	var p parser.Parser
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return statements
+}
+
+// describe gives a short label for a node so traversal orders can be compared as strings
+func describe(n ast.Node) string {
+	switch node := n.(type) {
+	case nil:
+		return "/"
+	case *ast.VariableExpression:
+		return node.Name.Lexeme
+	case *ast.LiteralExpression:
+		return fmt.Sprintf("%v", node.Value)
+	case *ast.BinaryExpression:
+		return node.Operator.Lexeme
+	default:
+		return strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")
+	}
+}
+
+func TestInspect_PreAndPostOrder(t *testing.T) {
+	statements := parseSource(t, "@SET x = a + 1\n")
+
+	var order []string
+	ast.Inspect(statements[0], func(n ast.Node) bool {
+		order = append(order, describe(n))
+		return true
+	})
+	got := strings.Join(order, " ")
+	want := "VariableDeclarationStatement + a / 1 / / /"
+	if got != want {
+		t.Errorf("Inspect order:\n got  %s\n want %s", got, want)
+	}
+
+	var post []string
+	ast.InspectPost(statements[0], func(n ast.Node) {
+		post = append(post, describe(n))
+	})
+	if got, want := strings.Join(post, " "), "a 1 + VariableDeclarationStatement"; got != want {
+		t.Errorf("InspectPost order:\n got  %s\n want %s", got, want)
+	}
+}
+
+func TestInspect_SkipsChildren(t *testing.T) {
+	statements := parseSource(t, "@IF MODE == \"prod\"\n@FOR p IN [a, b]\nRUN echo ${p}\n@END\n@ELSE\n@SET c = d\n@END\n")
+
+	var names []string
+	ast.Inspect(statements[0], func(n ast.Node) bool {
+		if _, isFor := n.(*ast.ForStatement); isFor {
+			return false
+		}
+		if v, ok := n.(*ast.VariableExpression); ok {
+			names = append(names, v.Name.Lexeme)
+		}
+		return true
+	})
+	if got := strings.Join(names, ","); got != "MODE,d" {
+		t.Errorf("variables outside the loop: got %q, want %q", got, "MODE,d")
+	}
+}
+
+func TestRewrite_ReplaceDeleteInsert(t *testing.T) {
+	statements := parseSource(t, "@SET x = a + b\nRUN one\nRUN two\n@FOR i IN [a]\nRUN three\n@END\n")
+
+	statements = ast.RewriteStatements(statements, func(c *ast.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.VariableExpression:
+			if n.Name.Lexeme == "a" {
+				c.Replace(&ast.LiteralExpression{Value: 1, Token: n.Name})
+			}
+		case *ast.DockerStatement:
+			switch n.Args {
+			case "one":
+				c.Delete()
+			case "two":
+				c.InsertBefore(&ast.DockerStatement{Keyword: n.Keyword, Args: "before"})
+				c.InsertAfter(&ast.DockerStatement{Keyword: n.Keyword, Args: "after"})
+			}
+		}
+		return true
+	}, nil)
+
+	var docker []string
+	for _, stmt := range statements {
+		if ds, ok := stmt.(*ast.DockerStatement); ok {
+			docker = append(docker, ds.Args)
+		}
+	}
+	if got := strings.Join(docker, ","); got != "before,two,after" {
+		t.Errorf("top-level docker statements: got %q", got)
+	}
+
+	binary := statements[0].(*ast.VariableDeclarationStatement).Initializer.(*ast.BinaryExpression)
+	if lit, ok := binary.Left.(*ast.LiteralExpression); !ok || lit.Value != 1 {
+		t.Errorf("left operand not replaced: %T", binary.Left)
+	}
+	loop := statements[len(statements)-1].(*ast.ForStatement)
+	if _, ok := loop.Iterable.(*ast.ArrayLiteralExpression).Elements[0].(*ast.LiteralExpression); !ok {
+		t.Errorf("array element not replaced")
+	}
+}
+
+func TestRewrite_ReplaceRootAndCursorInfo(t *testing.T) {
+	statements := parseSource(t, "@SET x = (a)\n")
+	grouping := statements[0].(*ast.VariableDeclarationStatement).Initializer
+
+	var fields []string
+	result := ast.Rewrite(grouping, nil, func(c *ast.Cursor) bool {
+		fields = append(fields, fmt.Sprintf("%s:%d", c.Name(), c.Index()))
+		if _, ok := c.Node().(*ast.GroupingExpression); ok {
+			c.Replace(&ast.LiteralExpression{Value: "folded", Token: token.Token{Type: token.STRING}})
+		}
+		return true
+	})
+	if lit, ok := result.(*ast.LiteralExpression); !ok || lit.Value != "folded" {
+		t.Errorf("root not replaced: %T", result)
+	}
+	if got := strings.Join(fields, " "); got != "Expression:-1 Root:-1" {
+		t.Errorf("cursor fields: got %q", got)
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Package builtin defines the functions Docklett programs can call, in directive expressions and in
+${...} templates in Docker arguments:
+
+	@SET TAG = replace(lower(BRANCH), "/", "-")
+	RUN echo ${format("%s-%d", NAME, BUILD)}
+
+Most built-ins are pure: their result depends only on their arguments, so calls can be folded at
+compile time. The filesystem built-ins (file_exists, read_file, ...) and env() are impure: they read
+the build context or the environment through a Host, and calls to them are only evaluated when the
+caller provides one. A Host can deny either capability, e.g. for files from an untrusted source.
+
+SIGNATURES:
+A Function declares the Docklett type name of each parameter ("string", "int", "float", "bool",
+"any", "[any]", "map", "semver"). Call checks the argument count and types before running the function, so the
+implementations can type-assert their arguments directly:
+
+	upper("a", "b")     → upper() takes 1 argument, got 2
+	pad("7", "3")       → pad() argument 2 must be int, got string
+
+An "int" parameter also accepts a float holding a whole number, such as the result of 6 / 2.
+
+LENGTH:
+Built-ins that can build a string far longer than their arguments (pad, format, join, replace) are
+Bounded: they work out the length of the result first and fail with ErrTooLong instead of allocating
+more than the caller's limit, or MaxStringLength when the caller has none.
+
+	pad("", 9223372036854775807)   → pad() result exceeds the limit of 268435456 bytes
+
+DEFINED:
+defined(NAME) takes a variable name rather than a value, so it is true or false without ever reporting
+NAME as undefined. Its Function has ByName set and no implementation: the evaluator looks the name up.
+The static type checker uses the same signatures, see package types.
+*/
+package builtin
+
+import (
+	"docklett/compiler/value"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Function is one built-in.
+type Function struct {
+	Name     string
+	Params   []string // Docklett type name of each parameter
+	Optional int      // number of trailing parameters that may be omitted
+	Variadic bool     // the last parameter may repeat any number of times, including zero
+	Result   string   // Docklett type name of the result
+	Call     func(args []any) (any, error)
+	Bounded  func(args []any, maxLength int) (any, error) // set instead of Call by built-ins that can build long strings
+	Host     func(h *Host, args []any) (any, error)       // set instead of Call by impure built-ins
+	Needs    Capability                                   // what an impure built-in reads through its Host
+	ByName   bool                                         // the arguments are variable names, looked up by the caller
+}
+
+// Capability is a part of the machine that impure built-ins read, which a Host can deny.
+type Capability int
+
+const (
+	Files Capability = iota + 1 // the build context: file_exists, glob, read_file, ...
+	Env                         // host environment variables: env
+)
+
+func (c Capability) String() string {
+	switch c {
+	case Files:
+		return "the build context"
+	case Env:
+		return "environment variables"
+	}
+	return "nothing"
+}
+
+// ErrDisabled is wrapped by the error of a call to a built-in whose capability the Host denies.
+var ErrDisabled = errors.New("is disabled")
+
+// ErrTooLong is wrapped by the error of a call whose result would be longer than its limit.
+var ErrTooLong = errors.New("exceeds the limit")
+
+// MaxStringLength is the longest string, in bytes, a Bounded built-in builds when the caller sets no
+// lower limit.
+const MaxStringLength = 1 << 28
+
+// Pure reports whether the result of f depends only on its arguments.
+func (f *Function) Pure() bool {
+	return f.Host == nil
+}
+
+// registry maps every built-in name to its definition.
+var registry = index(stringFunctions, collectionFunctions, convertFunctions, semverFunctions, fileFunctions, envFunctions,
+	[]*Function{{Name: "defined", Params: []string{"name"}, Result: "bool", ByName: true}})
+
+func index(groups ...[]*Function) map[string]*Function {
+	functions := make(map[string]*Function)
+	for _, group := range groups {
+		for _, f := range group {
+			functions[f.Name] = f
+		}
+	}
+	return functions
+}
+
+// Lookup returns the built-in called name.
+func Lookup(name string) (*Function, bool) {
+	f, ok := registry[name]
+	return f, ok
+}
+
+// Names returns the name of every built-in, sorted.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Call checks args against the signature of f and runs it. h may be nil, in which case impure
+// built-ins report that they are unavailable.
+func Call(h *Host, f *Function, args []any) (any, error) {
+	return CallWithin(h, f, args, MaxStringLength)
+}
+
+// CallWithin calls f like Call, failing with ErrTooLong rather than building a string longer than
+// maxLength bytes. A maxLength of zero, or above MaxStringLength, means MaxStringLength.
+func CallWithin(h *Host, f *Function, args []any, maxLength int) (any, error) {
+	if err := f.CheckArity(len(args)); err != nil {
+		return nil, err
+	}
+	if f.ByName {
+		return nil, fmt.Errorf("%s() takes a variable name, not a value", f.Name)
+	}
+	converted := make([]any, len(args))
+	for i, arg := range args {
+		param := f.Param(i)
+		val, ok := convert(arg, param)
+		if !ok {
+			return nil, fmt.Errorf("%s() argument %d must be %s, got %s", f.Name, i+1, param, value.TypeName(arg))
+		}
+		converted[i] = val
+	}
+	if !f.Pure() {
+		if h == nil {
+			return nil, fmt.Errorf("%s() is not available here: it needs access to %s", f.Name, f.Needs)
+		}
+		if h.Denies(f.Needs) {
+			return nil, fmt.Errorf("%s() %w: this compilation may not read %s", f.Name, ErrDisabled, f.Needs)
+		}
+		return f.Host(h, converted)
+	}
+	if f.Bounded != nil {
+		if maxLength <= 0 || maxLength > MaxStringLength {
+			maxLength = MaxStringLength
+		}
+		return f.Bounded(converted, maxLength)
+	}
+	return f.Call(converted)
+}
+
+// checkLength reports a result of fixed bytes plus count repetitions of size bytes that would be
+// longer than maxLength, without computing a length that may overflow.
+func checkLength(f string, fixed, count, size, maxLength int) error {
+	if fixed > maxLength || (size > 0 && count > (maxLength-fixed)/size) {
+		return fmt.Errorf("%s() result %w of %d bytes", f, ErrTooLong, maxLength)
+	}
+	return nil
+}
+
+// CheckArity reports whether f can be called with n arguments.
+func (f *Function) CheckArity(n int) error {
+	required := len(f.Params) - f.Optional
+	if f.Variadic {
+		required--
+	}
+	switch {
+	case f.Variadic && n < required:
+		return fmt.Errorf("%s() takes at least %s, got %d", f.Name, plural(required, "argument"), n)
+	case f.Variadic:
+		return nil
+	case f.Optional > 0 && (n < required || n > len(f.Params)):
+		return fmt.Errorf("%s() takes %d to %d arguments, got %d", f.Name, required, len(f.Params), n)
+	case f.Optional == 0 && n != len(f.Params):
+		return fmt.Errorf("%s() takes %s, got %d", f.Name, plural(len(f.Params), "argument"), n)
+	}
+	return nil
+}
+
+// Param returns the type name of the i-th argument, accounting for a variadic last parameter.
+func (f *Function) Param(i int) string {
+	if i >= len(f.Params) {
+		return f.Params[len(f.Params)-1]
+	}
+	return f.Params[i]
+}
+
+// convert checks that v is a value of the named type, normalising whole floats to int for "int".
+func convert(v any, typeName string) (any, bool) {
+	switch typeName {
+	case "any":
+		return v, true
+	case "int":
+		n, ok := value.ToInt(v)
+		return n, ok
+	case "float":
+		f, ok := value.ToFloat(v)
+		return f, ok
+	case "[any]":
+		_, ok := v.([]any)
+		return v, ok
+	}
+	return v, value.TypeName(v) == typeName
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
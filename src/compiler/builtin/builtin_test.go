@@ -0,0 +1,538 @@
+package builtin
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func call(t *testing.T, name string, args ...any) (any, error) {
+	t.Helper()
+	f, ok := Lookup(name)
+	if !ok {
+		t.Fatalf("no built-in %q", name)
+	}
+	return Call(nil, f, args)
+}
+
+func TestCall_Strings(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   string
+		args []any
+		want any
+	}{
+		{"upper", "upper", []any{"alpine"}, "ALPINE"},
+		{"upper UTF-8", "upper", []any{"héllo wörld"}, "HÉLLO WÖRLD"},
+		{"lower UTF-8", "lower", []any{"ÇA VA"}, "ça va"},
+		{"trim whitespace", "trim", []any{"  3.19\n"}, "3.19"},
+		{"trim ideographic space", "trim", []any{"　名前　"}, "名前"},
+		{"trim cutset", "trim", []any{"--v1--", "-"}, "v1"},
+		{"trim UTF-8 cutset", "trim", []any{"«tag»", "«»"}, "tag"},
+		{"replace", "replace", []any{"feature/login", "/", "-"}, "feature-login"},
+		{"replace UTF-8", "replace", []any{"naïve café", "é", "e"}, "naïve cafe"},
+		{"split", "split", []any{"a,b,,c", ","}, []any{"a", "b", "", "c"}},
+		{"split characters", "split", []any{"日本語", ""}, []any{"日", "本", "語"}},
+		{"startswith", "startswith", []any{"python3.12", "python"}, true},
+		{"startswith UTF-8", "startswith", []any{"ünicode", "ü"}, true},
+		{"endswith", "endswith", []any{"app.tar.gz", ".zip"}, false},
+		{"pad left", "pad", []any{7, 3, "0"}, "007"},
+		{"pad whole float", "pad", []any{"ab", 4.0}, "  ab"},
+		{"pad zero width", "pad", []any{"ab", 0}, "ab"},
+		{"pad widest", "pad", []any{"", 1 << 20, "."}, strings.Repeat(".", 1<<20)},
+		{"pad counts characters", "pad", []any{"日本", 4, "*"}, "**日本"},
+		{"pad UTF-8 fill", "pad", []any{"x", 3, "é"}, "ééx"},
+		{"pad already wide", "pad", []any{"long", 2}, "long"},
+		{"format", "format", []any{"%s-%d", "api", 42}, "api-42"},
+		{"format whole float", "format", []any{"v%d", 5.0}, "v5"},
+		{"format precision", "format", []any{"%.2f%%", 99.5}, "99.50%"},
+		{"format quote and hex", "format", []any{"%q %x %v", "a b", 255, []any{"x", 1}}, `"a b" ff x 1`},
+		{"format UTF-8 layout", "format", []any{"→ %s ←", "ünï"}, "→ ünï ←"},
+		{"format without verbs", "format", []any{"plain"}, "plain"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := call(t, tc.fn, tc.args...)
+			if err != nil {
+				t.Fatalf("%s%v: %v", tc.fn, tc.args, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s%v = %#v, want %#v", tc.fn, tc.args, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCall_Collections(t *testing.T) {
+	m := map[string]any{"b": 2, "a": "x", "c": []any{true}}
+	tests := []struct {
+		name string
+		fn   string
+		args []any
+		want any
+	}{
+		{"len string counts characters", "len", []any{"日本語"}, 3},
+		{"len array", "len", []any{[]any{1, 2}}, 2},
+		{"len map", "len", []any{m}, 3},
+		{"concat", "concat", []any{[]any{1}, []any{}, []any{"a", 2}}, []any{1, "a", 2}},
+		{"concat nothing", "concat", []any{}, []any{}},
+		{"join", "join", []any{[]any{"curl", 1, true}, " "}, "curl 1 true"},
+		{"sort numbers", "sort", []any{[]any{3, 1.5, -2}}, []any{-2, 1.5, 3}},
+		{"sort is stable", "sort", []any{[]any{1.0, 0, 1}}, []any{0, 1.0, 1}},
+		{"sort ints beyond 2^53", "sort", []any{[]any{9007199254740993, 9007199254740992, 9007199254740994}}, []any{9007199254740992, 9007199254740993, 9007199254740994}},
+		{"sort strings", "sort", []any{[]any{"b", "a", "B"}}, []any{"B", "a", "b"}},
+		{"sort empty", "sort", []any{[]any{}}, []any{}},
+		{"unique", "unique", []any{[]any{"a", 1, "a", 1.0, "b"}}, []any{"a", 1, "b"}},
+		{"unique ints beyond 2^53", "unique", []any{[]any{9007199254740993, 9007199254740992, 9007199254740993}}, []any{9007199254740993, 9007199254740992}},
+		{"reverse", "reverse", []any{[]any{1, 2, 3}}, []any{3, 2, 1}},
+		{"contains substring", "contains", []any{"linux/arm64", "arm"}, true},
+		{"contains UTF-8", "contains", []any{"Grüße", "üß"}, true},
+		{"contains element", "contains", []any{[]any{"a", 2}, 2.0}, true},
+		{"contains missing element", "contains", []any{[]any{"a"}, "b"}, false},
+		{"contains key", "contains", []any{m, "c"}, true},
+		{"index_of element", "index_of", []any{[]any{"x", "y"}, "y"}, 1},
+		{"index_of missing", "index_of", []any{[]any{"x"}, "z"}, -1},
+		{"index_of counts characters", "index_of", []any{"héllo", "l"}, 2},
+		{"keys sorted", "keys", []any{m}, []any{"a", "b", "c"}},
+		{"values in key order", "values", []any{m}, []any{"x", 2, []any{true}}},
+		{"zip truncates", "zip", []any{[]any{1, 2, 3}, []any{"a", "b"}}, []any{[]any{1, "a"}, []any{2, "b"}}},
+		{"zip three", "zip", []any{[]any{1}, []any{2}, []any{3}}, []any{[]any{1, 2, 3}}},
+		{"flatten deep", "flatten", []any{[]any{1, []any{2, []any{3, []any{}}}, 4}}, []any{1, 2, 3, 4}},
+		{"chunk", "chunk", []any{[]any{1, 2, 3, 4, 5}, 2}, []any{[]any{1, 2}, []any{3, 4}, []any{5}}},
+		{"chunk empty", "chunk", []any{[]any{}, 3}, []any{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := call(t, tc.fn, tc.args...)
+			if err != nil {
+				t.Fatalf("%s%v: %v", tc.fn, tc.args, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s%v = %#v, want %#v", tc.fn, tc.args, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCall_Conversions(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   string
+		arg  any
+		want any
+	}{
+		{"int of int", "int", 42, 42},
+		{"int truncates towards zero", "int", -2.9, -2},
+		{"int of decimal string", "int", " 8080\n", 8080},
+		{"int of signed string", "int", "-17", -17},
+		{"float of int", "float", 3, 3.0},
+		{"float of string", "float", "1.5", 1.5},
+		{"float of whole string", "float", "2", 2.0},
+		{"str of int", "str", 8080, "8080"},
+		{"str of whole float", "str", 3.0, "3"},
+		{"str of bool", "str", true, "true"},
+		{"str of array", "str", []any{"a", 1}, "a 1"},
+		{"str of string", "str", "as is", "as is"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := call(t, tc.fn, tc.arg)
+			if err != nil {
+				t.Fatalf("%s(%v): %v", tc.fn, tc.arg, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s(%v) = %#v, want %#v", tc.fn, tc.arg, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCall_CollectionsDoNotModifyArguments(t *testing.T) {
+	arr := []any{3, 1, 2}
+	for _, fn := range []string{"sort", "reverse"} {
+		if _, err := call(t, fn, arr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(arr, []any{3, 1, 2}) {
+		t.Errorf("argument modified: %v", arr)
+	}
+}
+
+func TestCall_Satisfies(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		want       bool
+	}{
+		{"1.4.0", "^1.2", true},
+		{"2.0.0", "^1.2", false},
+		{"1.1.9", "^1.2", false},
+		{"0.2.5", "^0.2.3", true},
+		{"0.3.0", "^0.2.3", false},
+		{"0.0.4", "^0.0.3", false},
+		{"1.2.9", "~1.2.3", true},
+		{"1.3.0", "~1.2.3", false},
+		{"1.9.0", "~1", true},
+		{"1.2.7", "1.2.x", true},
+		{"1.3.0", "1.2.*", false},
+		{"7.0.0", "*", true},
+		{"1.3.0", ">1.2", true},
+		{"1.2.9", ">1.2", false},
+		{"1.2.9", "<=1.2", true},
+		{"1.2.0", "<1.2", false},
+		{"v18.19.0", ">=18.0.0 <19", true},
+		{"20.1.0", ">=18.0.0, <19", false},
+		{"20.1.0", "^16 || ^18 || ^20", true},
+		{"1.2.3+build.7", "=1.2.3", true},
+		{"1.5.0-beta", "^1.2", false},
+		{"2.0.0-rc.1", ">=1.0.0", false},
+		{"2.0.0-rc.2", ">=2.0.0-rc.1", true},
+		{"2.0.0-rc.2", ">=2.0.0-rc.1 <2.0.0-rc.2", false},
+		{"2.0.0", ">=2.0.0-rc.1", true},
+		{"2.0.0", "1.2.3 - 2.0.0", true},
+		{"1.2.2", "1.2.3 - 2.0.0", false},
+		{"2.0.1", "1.2.3 - 2.0.0", false},
+		{"2.3.9", "1.2 - 2.3", true},
+		{"2.4.0", "1.2 - 2.3", false},
+		{"3.0.5", "^1 || 3.0.0 - 3.1.0", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.version+" "+tc.constraint, func(t *testing.T) {
+			got, err := call(t, "satisfies", tc.version, tc.constraint)
+			if err != nil {
+				t.Fatalf("satisfies(%q, %q): %v", tc.version, tc.constraint, err)
+			}
+			if got != tc.want {
+				t.Errorf("satisfies(%q, %q) = %v, want %v", tc.version, tc.constraint, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCall_SortVersions(t *testing.T) {
+	var versions []any
+	for _, text := range []string{"1.10.0", "1.9.0", "1.10.0-rc.1", "v0.9.1"} {
+		v, err := call(t, "semver", text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, v)
+	}
+	got, err := call(t, "sort", versions)
+	if err != nil {
+		t.Fatalf("sort: %v", err)
+	}
+	if fmt.Sprint(got) != "[0.9.1 1.9.0 1.10.0-rc.1 1.10.0]" {
+		t.Errorf("sort = %v, want versions in precedence order", got)
+	}
+}
+
+func TestCall_Errors(t *testing.T) {
+	tests := []struct {
+		fn      string
+		args    []any
+		message string
+	}{
+		{"upper", []any{"a", "b"}, "upper() takes 1 argument, got 2"},
+		{"replace", []any{"a"}, "replace() takes 3 arguments, got 1"},
+		{"trim", []any{}, "trim() takes 1 to 2 arguments, got 0"},
+		{"format", []any{}, "format() takes at least 1 argument, got 0"},
+		{"upper", []any{1}, "upper() argument 1 must be string, got int"},
+		{"split", []any{"a", nil}, "split() argument 2 must be string, got nil"},
+		{"pad", []any{"7", "3"}, "pad() argument 2 must be int, got string"},
+		{"pad", []any{"7", 2.5}, "pad() argument 2 must be int, got float"},
+		{"pad", []any{"7", 3, "ab"}, `pad() fill must be a single character, got "ab"`},
+		{"pad", []any{"ab", -4, "."}, "pad() width must not be negative, got -4"},
+		{"pad", []any{"", math.MinInt}, "pad() width must not be negative, got -9223372036854775808"},
+		{"pad", []any{"", math.MaxInt}, "pad() result exceeds the limit of 268435456 bytes"},
+		{"format", []any{"%s-%d", "api"}, "format() layout has more verbs than the 1 argument(s) given"},
+		{"format", []any{"%s", "a", "b"}, "format() got 2 argument(s) but the layout only uses 1"},
+		{"format", []any{"%d", "api"}, "format() %d needs a whole number, got string api"},
+		{"format", []any{"%f", true}, "format() %f needs a number, got bool true"},
+		{"format", []any{"%y", 1}, "format() does not support the verb %y"},
+		{"format", []any{"%.2s", "a"}, "format() precision is only supported for %f, got %.2s"},
+		{"format", []any{"100%", 1}, "format() layout ends with an incomplete verb"},
+		{"len", []any{3}, "len() argument 1 must be string, array or map, got int"},
+		{"concat", []any{[]any{}, "a"}, "concat() argument 2 must be [any], got string"},
+		{"sort", []any{[]any{1, "a"}}, "sort() needs an array of only numbers, only strings or only versions"},
+		{"contains", []any{"abc", 1}, "contains() searches a string for a string, got int"},
+		{"contains", []any{map[string]any{}, 1}, "contains() looks up a string key in a map, got int"},
+		{"index_of", []any{nil, 1}, "index_of() argument 1 must be string or array, got nil"},
+		{"keys", []any{[]any{}}, "keys() argument 1 must be map, got array"},
+		{"zip", []any{[]any{}}, "zip() takes at least 2 arguments, got 1"},
+		{"chunk", []any{[]any{1}, 0}, "chunk() size must be positive, got 0"},
+		{"semver", []any{"1.2"}, `invalid semantic version "1.2": want MAJOR.MINOR.PATCH`},
+		{"semver", []any{"1.02.3"}, `invalid semantic version "1.02.3": "02" is not a version number`},
+		{"semver", []any{"1.2.3-rc..1"}, `invalid semantic version "1.2.3-rc..1": pre-release must be dot-separated alphanumeric identifiers`},
+		{"satisfies", []any{1, "^1"}, "satisfies() argument 1 must be semver or string, got int"},
+		{"satisfies", []any{"1.0.0", "^1 ||"}, `invalid version constraint "^1 ||": empty alternative`},
+		{"satisfies", []any{"1.0.0", "1.2.3 -"}, `invalid version constraint "1.2.3 -": a hyphen range needs a version after " - "`},
+		{"satisfies", []any{"1.0.0", "- 2.0.0"}, `invalid version constraint "- 2.0.0": a hyphen range needs a version before " - "`},
+		{"satisfies", []any{"1.0.0", ">=1.2-rc.1"}, `invalid version constraint ">=1.2-rc.1": "1.2-rc.1": a pre-release needs a full MAJOR.MINOR.PATCH version`},
+		{"int", []any{"8080/tcp"}, `int() cannot convert string "8080/tcp" to int`},
+		{"int", []any{"1.5"}, `int() cannot convert string "1.5" to int`},
+		{"int", []any{1e19}, "int() cannot convert float 1e+19 to int: out of range"},
+		{"int", []any{true}, "int() cannot convert bool to int"},
+		{"float", []any{"fast"}, `float() cannot convert string "fast" to float`},
+		{"float", []any{"NaN"}, `float() cannot convert string "NaN" to float`},
+		{"float", []any{nil}, "float() cannot convert nil to float"},
+		{"str", []any{}, "str() takes 1 argument, got 0"},
+		{"satisfies", []any{"1.0.0", "~one"}, `invalid version constraint "~one": "one" is not a version`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.message, func(t *testing.T) {
+			_, err := call(t, tc.fn, tc.args...)
+			if err == nil || err.Error() != tc.message {
+				t.Errorf("%s%v: error = %v, want %q", tc.fn, tc.args, err, tc.message)
+			}
+		})
+	}
+}
+
+// contextDir builds a build context with a symbolic link out of it, and a file outside it.
+func contextDir(t *testing.T) string {
+	t.Helper()
+	parent := t.TempDir()
+	root := filepath.Join(parent, "context")
+	files := map[string]string{
+		"context/package-lock.json":  "{}\n",
+		"context/pkgs.txt":           "curl\r\ngit\n\njq\n",
+		"context/services/api/part":  "RUN api\n",
+		"context/services/web/part":  "RUN web\n",
+		"context/services/web/other": "",
+		"secret.txt":                 "hunter2\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(parent, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"escape.txt":        filepath.Join(parent, "secret.txt"),
+		"services/out/part": filepath.Join(parent, "secret.txt"),
+		"lock.json":         "package-lock.json",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(link))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, path); err != nil {
+			t.Skipf("symbolic links unavailable: %v", err)
+		}
+	}
+	return root
+}
+
+func TestCallWithin_Length(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   string
+		args []any
+		want any // a string, or the error message
+	}{
+		{"pad at the limit", "pad", []any{"ab", 10, "."}, "........ab"},
+		{"pad over the limit", "pad", []any{"ab", 11, "."}, "pad() result exceeds the limit of 10 bytes"},
+		{"pad counts fill bytes", "pad", []any{"", 6, "é"}, "pad() result exceeds the limit of 10 bytes"},
+		{"pad widest", "pad", []any{"", math.MaxInt, "é"}, "pad() result exceeds the limit of 10 bytes"},
+		{"format precision", "format", []any{"%.999999999f", 1.5}, "format() result exceeds the limit of 10 bytes"},
+		{"format precision overflows", "format", []any{"%.99999999999999999999f", 1.5}, "format() result exceeds the limit of 10 bytes"},
+		{"format precision at the limit", "format", []any{"%.8f", 1.5}, "1.50000000"},
+		{"format arguments", "format", []any{"%s-%s", "abcde", "abcde"}, "format() result exceeds the limit of 10 bytes"},
+		{"join at the limit", "join", []any{[]any{"abc", "def"}, "----"}, "abc----def"},
+		{"join over the limit", "join", []any{[]any{"abc", "def", "g"}, "--"}, "join() result exceeds the limit of 10 bytes"},
+		{"replace grows", "replace", []any{"aaaa", "a", "bcd"}, "replace() result exceeds the limit of 10 bytes"},
+		{"replace empty old", "replace", []any{"abc", "", "---"}, "replace() result exceeds the limit of 10 bytes"},
+		{"replace shrinks", "replace", []any{"aaaaaaaaaa", "aa", "b"}, "bbbbb"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, _ := Lookup(tc.fn)
+			got, err := CallWithin(nil, f, tc.args, 10)
+			if message, _ := tc.want.(string); strings.Contains(message, "exceeds") {
+				if !errors.Is(err, ErrTooLong) || err.Error() != message {
+					t.Errorf("%s%v: error = %v, want %q", tc.fn, tc.args, err, message)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("%s%v = %#v, %v, want %#v", tc.fn, tc.args, got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestCall_Files(t *testing.T) {
+	h := NewHost(contextDir(t))
+	tests := []struct {
+		fn   string
+		arg  string
+		want any
+	}{
+		{"file_exists", "package-lock.json", true},
+		{"file_exists", "./services/../package-lock.json", true},
+		{"file_exists", "lock.json", true},
+		{"file_exists", "yarn.lock", false},
+		{"file_exists", "services", false},
+		{"dir_exists", "services", true},
+		{"dir_exists", "package-lock.json", false},
+		{"glob", "services/*/part", []any{"services/api/part", "services/web/part"}},
+		{"glob", "*.go", []any{}},
+		{"read_file", "services/api/part", "RUN api\n"},
+		{"read_file", "lock.json", "{}\n"},
+		{"read_lines", "pkgs.txt", []any{"curl", "git", "", "jq"}},
+		{"read_lines", "services/web/other", []any{}},
+		{"file_sha256", "package-lock.json", "ca3d163bab055381827226140568f3bef7eaac187cebd76878e0b63e9e442356"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.fn+" "+tc.arg, func(t *testing.T) {
+			f, _ := Lookup(tc.fn)
+			got, err := Call(h, f, []any{tc.arg})
+			if err != nil {
+				t.Fatalf("%s(%q): %v", tc.fn, tc.arg, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s(%q) = %#v, want %#v", tc.fn, tc.arg, got, tc.want)
+			}
+		})
+	}
+
+	want := []string{"lock.json", "package-lock.json", "pkgs.txt", "services/api/part", "services/web/other"}
+	if got := h.Inputs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Inputs() = %q, want %q", got, want)
+	}
+}
+
+func TestCall_FilesStayInContext(t *testing.T) {
+	h := NewHost(contextDir(t))
+	tests := []struct {
+		fn      string
+		arg     string
+		message string
+	}{
+		{"read_file", "../secret.txt", `read_file() "../secret.txt" is outside the build context`},
+		{"read_file", "escape.txt", `read_file() "escape.txt" is outside the build context through a symbolic link`},
+		{"file_exists", "escape.txt", `file_exists() "escape.txt" is outside the build context through a symbolic link`},
+		{"read_lines", "/etc/passwd", `read_lines() "/etc/passwd" must be a path relative to the build context`},
+		{"file_sha256", "missing", `file_sha256() "missing" does not exist in the build context`},
+		{"glob", "../*", `glob() pattern "../*" must be a relative path inside the build context, without ".."`},
+		{"glob", "[", `glob() pattern "[": syntax error in pattern`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.message, func(t *testing.T) {
+			f, _ := Lookup(tc.fn)
+			_, err := Call(h, f, []any{tc.arg})
+			if err == nil || err.Error() != tc.message {
+				t.Errorf("%s(%q): error = %v, want %q", tc.fn, tc.arg, err, tc.message)
+			}
+		})
+	}
+
+	// a matching link out of the context is left out rather than followed
+	f, _ := Lookup("glob")
+	if got, _ := Call(h, f, []any{"services/*/part"}); len(got.([]any)) != 2 {
+		t.Errorf("glob followed a link out of the context: %v", got)
+	}
+	if len(h.Inputs()) != 0 {
+		t.Errorf("failed reads recorded as inputs: %v", h.Inputs())
+	}
+
+	if _, err := call(t, "read_file", "package-lock.json"); err == nil ||
+		err.Error() != "read_file() is not available here: it needs access to the build context" {
+		t.Errorf("without a host: error = %v", err)
+	}
+}
+
+func TestCall_Env(t *testing.T) {
+	h := NewHost(t.TempDir())
+	h.Env = func(name string) (string, bool) {
+		val, ok := map[string]string{"CI_COMMIT_SHA": "abc123", "BUILD_NUMBER": "", "HOME": "/root"}[name]
+		return val, ok
+	}
+	h.AllowEnv = []string{"CI_*", "BUILD_NUMBER", "RELEASE"}
+	env, _ := Lookup("env")
+
+	tests := []struct {
+		args    []any
+		want    any
+		message string
+	}{
+		{[]any{"CI_COMMIT_SHA"}, "abc123", ""},
+		{[]any{"CI_COMMIT_SHA", "unknown"}, "abc123", ""},
+		{[]any{"BUILD_NUMBER", "0"}, "", ""},
+		{[]any{"RELEASE", "dev"}, "dev", ""},
+		{[]any{"CI_JOB_ID"}, nil, `env() variable "CI_JOB_ID" is not set and has no default`},
+		{[]any{"HOME", "/"}, nil, `env() "HOME" is not in the environment allowlist`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.args[0].(string), func(t *testing.T) {
+			got, err := Call(h, env, tc.args)
+			if tc.message != "" {
+				if err == nil || err.Error() != tc.message {
+					t.Errorf("env%v: error = %v, want %q", tc.args, err, tc.message)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("env%v = %#v, %v, want %#v", tc.args, got, err, tc.want)
+			}
+		})
+	}
+
+	// HOME was refused, so it did not influence anything
+	want := []string{"BUILD_NUMBER", "CI_COMMIT_SHA", "CI_JOB_ID", "RELEASE"}
+	if got := h.EnvVars(); !reflect.DeepEqual(got, want) {
+		t.Errorf("EnvVars() = %q, want %q", got, want)
+	}
+}
+
+func TestCall_DeniedCapabilities(t *testing.T) {
+	h := NewHost(t.TempDir())
+	h.AllowEnv = []string{"*"}
+	h.NoFiles, h.NoEnv = true, true
+
+	tests := []struct {
+		name    string
+		args    []any
+		message string
+	}{
+		{"file_exists", []any{"go.mod"}, "file_exists() is disabled: this compilation may not read the build context"},
+		{"read_file", []any{"VERSION"}, "read_file() is disabled: this compilation may not read the build context"},
+		{"env", []any{"HOME", ""}, "env() is disabled: this compilation may not read environment variables"},
+		{"upper", []any{"pure"}, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, _ := Lookup(tc.name)
+			_, err := Call(h, f, tc.args)
+			if tc.message == "" {
+				if err != nil {
+					t.Errorf("%s%v: error = %v, want none", tc.name, tc.args, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrDisabled) || err.Error() != tc.message {
+				t.Errorf("%s%v: error = %v, want %q", tc.name, tc.args, err, tc.message)
+			}
+		})
+	}
+
+	// nothing was read, so nothing is reported as an input of the build
+	if inputs, vars := h.Inputs(), h.EnvVars(); len(inputs) != 0 || len(vars) != 0 {
+		t.Errorf("Inputs() = %q, EnvVars() = %q, want none", inputs, vars)
+	}
+}
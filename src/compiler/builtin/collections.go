@@ -0,0 +1,216 @@
+/*
+Collection built-ins. They never modify their arguments: every result is a new array or map.
+Anything that lists map entries does so in ascending key order, so results are deterministic.
+
+	len(v)                  characters in a string, elements in an array, entries in a map
+	concat(arrays...)       one array with the elements of every argument, in order
+	join(arr, sep)          "curl git": elements printed as when interpolated, separated by sep
+	sort(arr)               ascending; the elements must be all numbers, all strings or all versions
+	unique(arr)             first occurrence of every element, order kept
+	reverse(arr)
+	contains(v, x)          substring of a string, element of an array, key of a map
+	index_of(v, x)          first position of x in an array, or of a substring in a string; -1 if absent
+	keys(m), values(m)      map keys and the values in key order
+	zip(a, b, ...)          [[a0, b0], [a1, b1], ...], as long as the shortest argument
+	flatten(arr)            nested arrays spliced in at every depth: [1, [2, [3]]] → [1, 2, 3]
+	chunk(arr, n)           consecutive slices of n elements, the last one possibly shorter:
+	                        chunk(PKGS, 50) splits a long package list across several RUN layers
+*/
+package builtin
+
+import (
+	"docklett/compiler/value"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var collectionFunctions = []*Function{
+	{Name: "len", Params: []string{"any"}, Result: "int", Call: length},
+	{Name: "concat", Params: []string{"[any]"}, Variadic: true, Result: "[any]", Call: func(args []any) (any, error) {
+		result := []any{}
+		for _, arg := range args {
+			result = append(result, arg.([]any)...)
+		}
+		return result, nil
+	}},
+	{Name: "join", Params: []string{"[any]", "string"}, Result: "string", Bounded: func(args []any, maxLength int) (any, error) {
+		elements, sep := args[0].([]any), args[1].(string)
+		parts := make([]string, len(elements))
+		total := 0
+		for i, elem := range elements {
+			parts[i] = value.Stringify(elem)
+			total += len(parts[i])
+			if err := checkLength("join", total, i, len(sep), maxLength); err != nil {
+				return nil, err
+			}
+		}
+		return strings.Join(parts, sep), nil
+	}},
+	{Name: "sort", Params: []string{"[any]"}, Result: "[any]", Call: sortArray},
+	{Name: "unique", Params: []string{"[any]"}, Result: "[any]", Call: func(args []any) (any, error) {
+		result := []any{}
+		for _, elem := range args[0].([]any) {
+			if indexOf(result, elem) < 0 {
+				result = append(result, elem)
+			}
+		}
+		return result, nil
+	}},
+	{Name: "reverse", Params: []string{"[any]"}, Result: "[any]", Call: func(args []any) (any, error) {
+		elements := args[0].([]any)
+		result := make([]any, len(elements))
+		for i, elem := range elements {
+			result[len(elements)-1-i] = elem
+		}
+		return result, nil
+	}},
+	{Name: "contains", Params: []string{"any", "any"}, Result: "bool", Call: contains},
+	{Name: "index_of", Params: []string{"any", "any"}, Result: "int", Call: func(args []any) (any, error) {
+		switch v := args[0].(type) {
+		case string:
+			sub, ok := args[1].(string)
+			if !ok {
+				return nil, fmt.Errorf("index_of() searches a string for a string, got %s", value.TypeName(args[1]))
+			}
+			i := strings.Index(v, sub)
+			if i < 0 {
+				return -1, nil
+			}
+			return utf8.RuneCountInString(v[:i]), nil
+		case []any:
+			return indexOf(v, args[1]), nil
+		}
+		return nil, fmt.Errorf("index_of() argument 1 must be string or array, got %s", value.TypeName(args[0]))
+	}},
+	{Name: "keys", Params: []string{"map"}, Result: "[string]", Call: func(args []any) (any, error) {
+		m := args[0].(map[string]any)
+		result := []any{}
+		for _, key := range value.SortedKeys(m) {
+			result = append(result, key)
+		}
+		return result, nil
+	}},
+	{Name: "values", Params: []string{"map"}, Result: "[any]", Call: func(args []any) (any, error) {
+		m := args[0].(map[string]any)
+		result := []any{}
+		for _, key := range value.SortedKeys(m) {
+			result = append(result, m[key])
+		}
+		return result, nil
+	}},
+	{Name: "zip", Params: []string{"[any]", "[any]", "[any]"}, Variadic: true, Result: "[[any]]", Call: func(args []any) (any, error) {
+		shortest := len(args[0].([]any))
+		for _, arg := range args[1:] {
+			shortest = min(shortest, len(arg.([]any)))
+		}
+		result := make([]any, shortest)
+		for i := range result {
+			tuple := make([]any, len(args))
+			for j, arg := range args {
+				tuple[j] = arg.([]any)[i]
+			}
+			result[i] = tuple
+		}
+		return result, nil
+	}},
+	{Name: "flatten", Params: []string{"[any]"}, Result: "[any]", Call: func(args []any) (any, error) {
+		return flatten([]any{}, args[0].([]any)), nil
+	}},
+	{Name: "chunk", Params: []string{"[any]", "int"}, Result: "[[any]]", Call: func(args []any) (any, error) {
+		elements, size := args[0].([]any), args[1].(int)
+		if size <= 0 {
+			return nil, fmt.Errorf("chunk() size must be positive, got %d", size)
+		}
+		result := []any{}
+		for start := 0; start < len(elements); start += size {
+			end := min(start+size, len(elements))
+			result = append(result, append([]any{}, elements[start:end]...))
+		}
+		return result, nil
+	}},
+}
+
+func length(args []any) (any, error) {
+	switch v := args[0].(type) {
+	case string:
+		return utf8.RuneCountInString(v), nil
+	case []any:
+		return len(v), nil
+	case map[string]any:
+		return len(v), nil
+	}
+	return nil, fmt.Errorf("len() argument 1 must be string, array or map, got %s", value.TypeName(args[0]))
+}
+
+func contains(args []any) (any, error) {
+	switch v := args[0].(type) {
+	case string:
+		sub, ok := args[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("contains() searches a string for a string, got %s", value.TypeName(args[1]))
+		}
+		return strings.Contains(v, sub), nil
+	case []any:
+		return indexOf(v, args[1]) >= 0, nil
+	case map[string]any:
+		key, ok := args[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("contains() looks up a string key in a map, got %s", value.TypeName(args[1]))
+		}
+		_, found := v[key]
+		return found, nil
+	}
+	return nil, fmt.Errorf("contains() argument 1 must be string, array or map, got %s", value.TypeName(args[0]))
+}
+
+// indexOf returns the position of the first element equal to x, or -1.
+func indexOf(elements []any, x any) int {
+	for i, elem := range elements {
+		if value.Equal(elem, x) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sortArray sorts a copy of an array of numbers, of strings or of versions. The sort is stable, so 1
+// and 1.0 keep their order, and so do versions that differ only in build metadata.
+func sortArray(args []any) (any, error) {
+	result := append([]any{}, args[0].([]any)...)
+	numbers, strs, versions := true, true, true
+	for _, elem := range result {
+		_, isNumber := value.ToFloat(elem)
+		_, isString := elem.(string)
+		_, isVersion := elem.(value.Version)
+		numbers, strs, versions = numbers && isNumber, strs && isString, versions && isVersion
+	}
+	switch {
+	case numbers:
+		sort.SliceStable(result, func(i, j int) bool {
+			c, _ := value.CompareNumbers(result[i], result[j])
+			return c < 0
+		})
+	case strs:
+		sort.SliceStable(result, func(i, j int) bool { return result[i].(string) < result[j].(string) })
+	case versions:
+		sort.SliceStable(result, func(i, j int) bool {
+			return result[i].(value.Version).Compare(result[j].(value.Version)) < 0
+		})
+	default:
+		return nil, fmt.Errorf("sort() needs an array of only numbers, only strings or only versions")
+	}
+	return result, nil
+}
+
+func flatten(into []any, elements []any) []any {
+	for _, elem := range elements {
+		if nested, ok := elem.([]any); ok {
+			into = flatten(into, nested)
+		} else {
+			into = append(into, elem)
+		}
+	}
+	return into
+}
@@ -0,0 +1,64 @@
+/*
+Conversion built-ins. Values never change type on their own, so text read from a file, an
+environment variable or a version component is converted explicitly:
+
+	@SET PORT = int(env("PORT", "8080")) + 1
+	@SET SCALE = float(REPLICAS) / 2
+
+	int(v)      an int from an int, a float (truncated towards zero) or a decimal string like "8080"
+	float(v)    a float from a number or a numeric string like "1.5"
+	str(v)      the text of any value, exactly as ${v} interpolates it
+
+Anything else is an error naming the value, e.g. int() cannot convert string "8080/tcp" to int.
+*/
+package builtin
+
+import (
+	"docklett/compiler/value"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var convertFunctions = []*Function{
+	{Name: "int", Params: []string{"any"}, Result: "int", Call: toInt},
+	{Name: "float", Params: []string{"any"}, Result: "float", Call: toFloat},
+	{Name: "str", Params: []string{"any"}, Result: "string", Call: func(args []any) (any, error) {
+		return value.Stringify(args[0]), nil
+	}},
+}
+
+func toInt(args []any) (any, error) {
+	switch v := args[0].(type) {
+	case int:
+		return v, nil
+	case float64:
+		// float64(math.MaxInt) rounds up to 2^63, which is already out of range
+		if math.IsNaN(v) || v < math.MinInt || v >= math.MaxInt {
+			return nil, fmt.Errorf("int() cannot convert float %v to int: out of range", v)
+		}
+		return int(v), nil
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("int() cannot convert string %q to int", v)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("int() cannot convert %s to int", value.TypeName(args[0]))
+}
+
+func toFloat(args []any) (any, error) {
+	if f, ok := value.ToFloat(args[0]); ok {
+		return f, nil
+	}
+	if text, ok := args[0].(string); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, fmt.Errorf("float() cannot convert string %q to float", text)
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("float() cannot convert %s to float", value.TypeName(args[0]))
+}
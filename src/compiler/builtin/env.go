@@ -0,0 +1,30 @@
+/*
+Environment built-in. It reads variables of the machine running the compiler, typically values a
+CI pipeline provides:
+
+	LABEL revision=${env("CI_COMMIT_SHA", "unknown")} build=${env("BUILD_NUMBER", "0")}
+
+	env(name[, default])    the value of the variable; default when it is not set, an error
+	                        when it is not set and there is no default
+
+Only variables in Host.AllowEnv can be read, and every read is recorded, see Host.EnvVars.
+*/
+package builtin
+
+import "fmt"
+
+var envFunctions = []*Function{
+	{Name: "env", Params: []string{"string", "string"}, Optional: 1, Result: "string", Needs: Env, Host: func(h *Host, args []any) (any, error) {
+		name := args[0].(string)
+		val, ok, err := h.lookupEnv(name)
+		switch {
+		case err != nil:
+			return nil, fmt.Errorf("env() %w", err)
+		case ok:
+			return val, nil
+		case len(args) == 2:
+			return args[1], nil
+		}
+		return nil, fmt.Errorf("env() variable %q is not set and has no default", name)
+	}},
+}
@@ -0,0 +1,119 @@
+/*
+Filesystem built-ins. They look at the build context, so a Dockerfile can adapt to the repository:
+
+	@IF file_exists("package-lock.json")
+	RUN npm ci
+	@END
+	@FOR part IN glob("docker/*.part")
+
+	file_exists(path)       whether path is a regular file (following symbolic links)
+	dir_exists(path)        whether path is a directory
+	glob(pattern)           sorted paths matching a pattern of path.Match syntax, applied per path
+	                        element, so "*" never crosses a "/"; there is no recursive "**"
+	read_file(path)         contents as a string
+	read_lines(path)        lines without their "\n" or "\r\n"; a final line break adds no empty line
+	file_sha256(path)       hex SHA-256 of the contents, e.g. for cache-busting labels
+
+Paths are slash-separated and relative to the build context root, see Host. Absolute paths, ".."
+segments and symbolic links that lead outside the root are errors; glob() leaves such matches out.
+The three functions that read contents record the file in Host.Inputs.
+*/
+package builtin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var fileFunctions = []*Function{
+	{Name: "file_exists", Params: []string{"string"}, Result: "bool", Needs: Files, Host: func(h *Host, args []any) (any, error) {
+		info, err := stat(h, "file_exists", args[0].(string))
+		return info != nil && info.Mode().IsRegular(), err
+	}},
+	{Name: "dir_exists", Params: []string{"string"}, Result: "bool", Needs: Files, Host: func(h *Host, args []any) (any, error) {
+		info, err := stat(h, "dir_exists", args[0].(string))
+		return info != nil && info.IsDir(), err
+	}},
+	{Name: "glob", Params: []string{"string"}, Result: "[string]", Needs: Files, Host: glob},
+	{Name: "read_file", Params: []string{"string"}, Result: "string", Needs: Files, Host: func(h *Host, args []any) (any, error) {
+		data, err := h.read(args[0].(string))
+		if err != nil {
+			return nil, fmt.Errorf("read_file() %w", err)
+		}
+		return string(data), nil
+	}},
+	{Name: "read_lines", Params: []string{"string"}, Result: "[string]", Needs: Files, Host: func(h *Host, args []any) (any, error) {
+		data, err := h.read(args[0].(string))
+		if err != nil {
+			return nil, fmt.Errorf("read_lines() %w", err)
+		}
+		text := strings.TrimSuffix(string(data), "\n")
+		result := []any{}
+		if text == "" {
+			return result, nil
+		}
+		for _, line := range strings.Split(text, "\n") {
+			result = append(result, strings.TrimSuffix(line, "\r"))
+		}
+		return result, nil
+	}},
+	{Name: "file_sha256", Params: []string{"string"}, Result: "string", Needs: Files, Host: func(h *Host, args []any) (any, error) {
+		data, err := h.read(args[0].(string))
+		if err != nil {
+			return nil, fmt.Errorf("file_sha256() %w", err)
+		}
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:]), nil
+	}},
+}
+
+// stat returns information about a context-relative path, or nil when it does not exist.
+func stat(h *Host, name, path string) (os.FileInfo, error) {
+	real, err := h.resolve(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s() %w", name, err)
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		return nil, nil
+	}
+	return info, nil
+}
+
+func glob(h *Host, args []any) (any, error) {
+	pattern := args[0].(string)
+	if !fs.ValidPath(pattern) {
+		return nil, fmt.Errorf("glob() pattern %q must be a relative path inside the build context, without \"..\"", pattern)
+	}
+	root, err := filepath.Abs(h.Root)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+	if err != nil {
+		return nil, fmt.Errorf("glob() pattern %q: %w", pattern, err)
+	}
+
+	result := []any{}
+	for _, match := range matches {
+		rel, err := filepath.Rel(root, match)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if _, err := h.resolve(rel); err != nil {
+			continue // a link out of the context, or one that points nowhere
+		}
+		result = append(result, rel)
+	}
+	return result, nil
+}
@@ -0,0 +1,134 @@
+package builtin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Host gives impure built-ins access to the machine running the compiler. Everything they
+// consult is recorded, so a cache can tell when the output of a compilation may have changed.
+type Host struct {
+	// Root is the build context: relative paths resolve against it and no file outside it can
+	// be read, not even through a symbolic link.
+	Root string
+	// Env looks up a host environment variable, os.LookupEnv unless replaced.
+	Env func(name string) (string, bool)
+	// AllowEnv lists the variables env() may read, as names or path.Match patterns such as "CI_*".
+	// Everything else is an error, so a build cannot silently depend on the machine it runs on.
+	AllowEnv []string
+	// NoFiles and NoEnv disable the filesystem built-ins and env() altogether, see Capability.
+	NoFiles bool
+	NoEnv   bool
+
+	inputs  map[string]bool // slash-separated paths, relative to Root, of files read
+	envRead map[string]bool // names of environment variables read
+}
+
+// NewHost returns a Host confined to the build context root, reading no environment variables.
+func NewHost(root string) *Host {
+	return &Host{Root: root, Env: os.LookupEnv, inputs: make(map[string]bool), envRead: make(map[string]bool)}
+}
+
+// Denies reports whether built-ins needing c may not run.
+func (h *Host) Denies(c Capability) bool {
+	return (c == Files && h.NoFiles) || (c == Env && h.NoEnv)
+}
+
+// Inputs returns the files read so far, relative to Root and sorted.
+func (h *Host) Inputs() []string {
+	inputs := make([]string, 0, len(h.inputs))
+	for path := range h.inputs {
+		inputs = append(inputs, path)
+	}
+	sort.Strings(inputs)
+	return inputs
+}
+
+// EnvVars returns the names of the environment variables read so far, sorted. A variable that was
+// read but not set counts too: its absence decided which default was used.
+func (h *Host) EnvVars() []string {
+	names := make([]string, 0, len(h.envRead))
+	for name := range h.envRead {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupEnv reads an allowed environment variable and records it.
+func (h *Host) lookupEnv(name string) (string, bool, error) {
+	allowed := false
+	for _, pattern := range h.AllowEnv {
+		if matched, _ := path.Match(pattern, name); matched {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", false, fmt.Errorf("%q is not in the environment allowlist", name)
+	}
+	h.envRead[name] = true
+	val, ok := h.Env(name)
+	return val, ok, nil
+}
+
+// errOutside reports a path that leaves the build context.
+var errOutside = errors.New("is outside the build context")
+
+// resolve returns the real location of a context-relative path after following symbolic links.
+// A path that leaves Root, lexically or through a link, is an error; a missing path is returned
+// with an error satisfying errors.Is(err, os.ErrNotExist).
+func (h *Host) resolve(path string) (string, error) {
+	if path == "" || filepath.IsAbs(path) || filepath.VolumeName(path) != "" {
+		return "", fmt.Errorf("%q must be a path relative to the build context", path)
+	}
+	root, err := filepath.Abs(h.Root)
+	if err != nil {
+		return "", err
+	}
+	full := filepath.Join(root, filepath.FromSlash(path))
+	if !within(root, full) {
+		return "", fmt.Errorf("%q %w", path, errOutside)
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("build context %s: %w", root, err)
+	}
+	real, err := filepath.EvalSymlinks(full)
+	if err != nil {
+		return "", err
+	}
+	if !within(realRoot, real) {
+		return "", fmt.Errorf("%q %w through a symbolic link", path, errOutside)
+	}
+	return real, nil
+}
+
+// within reports whether path is dir or inside it; both must be clean absolute paths.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// read returns the contents of a context-relative file and records it as an input.
+func (h *Host) read(path string) ([]byte, error) {
+	real, err := h.resolve(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%q does not exist in the build context", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(real)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q: %w", path, errors.Unwrap(err))
+	}
+	h.inputs[filepath.ToSlash(filepath.Clean(path))] = true
+	return data, nil
+}
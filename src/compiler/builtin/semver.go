@@ -0,0 +1,277 @@
+/*
+Semantic version built-ins.
+
+	semver(s)                   parses "1.2.3", "v18.19.0" or "2.0.0-rc.1+build.5" into a version;
+	                            versions compare by precedence and print back as text
+	satisfies(v, constraint)    whether the version v (or a string holding one) meets constraint
+
+CONSTRAINTS:
+A constraint is one or more alternatives separated by "||"; an alternative is one or more
+comparators separated by spaces or commas, all of which must hold. Versions in comparators may be
+partial, and "x", "X" or "*" stands for any number:
+
+	^1.2.3   >=1.2.3 <2.0.0      ^0.2.3   >=0.2.3 <0.3.0      ^0.0.3   >=0.0.3 <0.0.4
+	~1.2.3   >=1.2.3 <1.3.0      ~1.2     >=1.2.0 <1.3.0      ~1       >=1.0.0 <2.0.0
+	1.2.x    >=1.2.0 <1.3.0      1        >=1.0.0 <2.0.0      *        any version
+	>1.2     >=1.3.0             <=1.2    <1.3.0              =1.2.3   exactly 1.2.3
+	1.2.3 - 2.3.4   >=1.2.3 <=2.3.4     1.2 - 2.3   >=1.2.0 <2.4.0
+
+A hyphen range needs spaces around the "-", which would otherwise start a pre-release.
+
+As with npm, a pre-release only satisfies an alternative that mentions a pre-release of the same
+MAJOR.MINOR.PATCH, so satisfies("2.0.0-rc.1", ">=1.0.0") is false but
+satisfies("2.0.0-rc.2", ">=2.0.0-rc.1") is true.
+*/
+package builtin
+
+import (
+	"docklett/compiler/value"
+	"fmt"
+	"strings"
+)
+
+var semverFunctions = []*Function{
+	{Name: "semver", Params: []string{"string"}, Result: "semver", Call: func(args []any) (any, error) {
+		return value.ParseVersion(args[0].(string))
+	}},
+	{Name: "satisfies", Params: []string{"any", "string"}, Result: "bool", Call: func(args []any) (any, error) {
+		v, ok := args[0].(value.Version)
+		if text, isString := args[0].(string); isString {
+			var err error
+			if v, err = value.ParseVersion(text); err != nil {
+				return nil, err
+			}
+			ok = true
+		}
+		if !ok {
+			return nil, fmt.Errorf("satisfies() argument 1 must be semver or string, got %s", value.TypeName(args[0]))
+		}
+		constraint, err := parseConstraint(args[1].(string))
+		if err != nil {
+			return nil, err
+		}
+		return constraint.allows(v), nil
+	}},
+}
+
+// comparator is a single bound such as >=1.2.0.
+type comparator struct {
+	op      string // "=", ">", ">=", "<" or "<="
+	version value.Version
+}
+
+func (c comparator) allows(v value.Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return cmp == 0
+}
+
+// constraint is a disjunction of comparator sets.
+type constraint [][]comparator
+
+func (c constraint) allows(v value.Version) bool {
+	for _, set := range c {
+		if setAllows(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func setAllows(set []comparator, v value.Version) bool {
+	for _, comp := range set {
+		if !comp.allows(v) {
+			return false
+		}
+	}
+	if len(v.Prerelease) == 0 {
+		return true
+	}
+	for _, comp := range set {
+		bound := comp.version
+		if len(bound.Prerelease) > 0 && bound.Major == v.Major && bound.Minor == v.Minor && bound.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+func parseConstraint(text string) (constraint, error) {
+	invalid := func(reason string) (constraint, error) {
+		return nil, fmt.Errorf("invalid version constraint %q: %s", text, reason)
+	}
+
+	var result constraint
+	for _, alternative := range strings.Split(text, "||") {
+		fields := strings.FieldsFunc(alternative, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' })
+		if len(fields) == 0 {
+			return invalid("empty alternative")
+		}
+		var set []comparator
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			// a hyphen range "1.2 - 2.3" is ">=1.2 <=2.3"
+			if i+1 < len(fields) && fields[i+1] == "-" {
+				if i+2 == len(fields) {
+					return invalid(`a hyphen range needs a version after " - "`)
+				}
+				field, fields[i+2] = ">="+field, "<="+fields[i+2]
+				i++
+			} else if field == "-" {
+				return invalid(`a hyphen range needs a version before " - "`)
+			}
+			comparators, err := parseComparator(field)
+			if err != nil {
+				return invalid(err.Error())
+			}
+			set = append(set, comparators...)
+		}
+		result = append(result, set)
+	}
+	return result, nil
+}
+
+// parseComparator expands one comparator, e.g. "^1.2", into plain bounds.
+func parseComparator(text string) ([]comparator, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(text, prefix) {
+			op, text = prefix, text[len(prefix):]
+			break
+		}
+	}
+	p, err := parsePartial(text)
+	if err != nil {
+		return nil, err
+	}
+	low := p.version
+
+	// the first version above every version matching the partial: 1.2 → 1.3.0, 1 → 2.0.0
+	upper := func(parts int) comparator {
+		next := value.Version{Prerelease: []string{"0"}}
+		switch parts {
+		case 1:
+			next.Major = low.Major + 1
+		case 2:
+			next.Major, next.Minor = low.Major, low.Minor+1
+		default:
+			next.Major, next.Minor, next.Patch = low.Major, low.Minor, low.Patch+1
+		}
+		return comparator{"<", next}
+	}
+
+	switch op {
+	case "", "=":
+		if p.parts == 0 {
+			return nil, nil
+		}
+		if p.parts == 3 {
+			return []comparator{{"=", low}}, nil
+		}
+		return []comparator{{">=", low}, upper(p.parts)}, nil
+	case ">=":
+		return []comparator{{">=", low}}, nil
+	case "<":
+		return []comparator{{"<", withPrereleaseFloor(low, p.parts)}}, nil
+	case ">":
+		if p.parts == 0 {
+			return []comparator{{"<", value.Version{Prerelease: []string{"0"}}}}, nil
+		}
+		if p.parts == 3 {
+			return []comparator{{">", low}}, nil
+		}
+		next := upper(p.parts).version
+		next.Prerelease = nil
+		return []comparator{{">=", next}}, nil
+	case "<=":
+		if p.parts == 0 || p.parts == 3 {
+			return []comparator{{"<=", low}}, nil
+		}
+		return []comparator{upper(p.parts)}, nil
+	case "~":
+		if p.parts == 0 {
+			return nil, nil
+		}
+		return []comparator{{">=", low}, upper(min(p.parts, 2))}, nil
+	}
+
+	// "^": the leftmost non-zero component may not change
+	switch {
+	case p.parts == 0:
+		return nil, nil
+	case low.Major > 0 || p.parts == 1:
+		return []comparator{{">=", low}, upper(1)}, nil
+	case low.Minor > 0 || p.parts == 2:
+		return []comparator{{">=", low}, upper(2)}, nil
+	}
+	return []comparator{{">=", low}, upper(3)}, nil
+}
+
+// withPrereleaseFloor makes <1.2 exclude the pre-releases of 1.2.0, as npm does.
+func withPrereleaseFloor(v value.Version, parts int) value.Version {
+	if parts < 3 && len(v.Prerelease) == 0 {
+		v.Prerelease = []string{"0"}
+	}
+	return v
+}
+
+// partial is a version with trailing components left out or written as wildcards.
+type partial struct {
+	parts   int           // number of components given: 0 for "*", 3 for a full version
+	version value.Version // the lowest version matching the partial: 1.2 → 1.2.0
+}
+
+func parsePartial(text string) (partial, error) {
+	if text == "" {
+		return partial{}, fmt.Errorf("missing version")
+	}
+	text = strings.TrimPrefix(text, "v")
+	core, rest := text, ""
+	if i := strings.IndexAny(text, "-+"); i >= 0 {
+		core, rest = text[:i], text[i:]
+	}
+
+	components := strings.Split(core, ".")
+	if len(components) > 3 {
+		return partial{}, fmt.Errorf("%q has more than 3 components", text)
+	}
+	parts := 0
+	for _, component := range components {
+		if component == "x" || component == "X" || component == "*" {
+			break
+		}
+		parts++
+	}
+	if parts == 3 {
+		v, err := value.ParseVersion(text)
+		if err != nil {
+			return partial{}, err
+		}
+		return partial{parts: 3, version: v}, nil
+	}
+	if rest != "" {
+		return partial{}, fmt.Errorf("%q: a pre-release needs a full MAJOR.MINOR.PATCH version", text)
+	}
+
+	filled := make([]string, 3)
+	for i := range filled {
+		filled[i] = "0"
+		if i < parts {
+			filled[i] = components[i]
+		}
+	}
+	v, err := value.ParseVersion(strings.Join(filled, "."))
+	if err != nil {
+		return partial{}, fmt.Errorf("%q is not a version", text)
+	}
+	return partial{parts: parts, version: v}, nil
+}
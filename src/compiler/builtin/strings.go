@@ -0,0 +1,177 @@
+/*
+String built-ins. All of them work on Unicode text: lengths and widths are counted in characters
+(runes), not bytes, and case mapping follows Unicode rules.
+
+	upper(s)                    "héllo" → "HÉLLO"
+	lower(s)
+	trim(s[, cutset])           surrounding whitespace, or any of the characters in cutset
+	replace(s, old, new)        every occurrence
+	split(s, sep)               ["a", "b"]; an empty sep splits into characters
+	startswith(s, prefix)
+	endswith(s, suffix)
+	contains(s, substr)         see collections.go, which also covers arrays and maps
+	pad(v, width[, fill])       left-pads the text of v to width characters with fill (default " "),
+	                            width must not be negative: pad(7, 3, "0") → "007"
+	format(layout, args...)     printf-style formatting, see formatString
+*/
+package builtin
+
+import (
+	"docklett/compiler/value"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var stringFunctions = []*Function{
+	{Name: "upper", Params: []string{"string"}, Result: "string", Call: func(args []any) (any, error) {
+		return strings.ToUpper(args[0].(string)), nil
+	}},
+	{Name: "lower", Params: []string{"string"}, Result: "string", Call: func(args []any) (any, error) {
+		return strings.ToLower(args[0].(string)), nil
+	}},
+	{Name: "trim", Params: []string{"string", "string"}, Optional: 1, Result: "string", Call: func(args []any) (any, error) {
+		if len(args) == 1 {
+			return strings.TrimSpace(args[0].(string)), nil
+		}
+		return strings.Trim(args[0].(string), args[1].(string)), nil
+	}},
+	{Name: "replace", Params: []string{"string", "string", "string"}, Result: "string", Bounded: func(args []any, maxLength int) (any, error) {
+		s, old, replacement := args[0].(string), args[1].(string), args[2].(string)
+		count := strings.Count(s, old)
+		if err := checkLength("replace", len(s)-count*len(old), count, len(replacement), maxLength); err != nil {
+			return nil, err
+		}
+		return strings.ReplaceAll(s, old, replacement), nil
+	}},
+	{Name: "split", Params: []string{"string", "string"}, Result: "[string]", Call: func(args []any) (any, error) {
+		parts := strings.Split(args[0].(string), args[1].(string))
+		result := make([]any, len(parts))
+		for i, part := range parts {
+			result[i] = part
+		}
+		return result, nil
+	}},
+	{Name: "startswith", Params: []string{"string", "string"}, Result: "bool", Call: func(args []any) (any, error) {
+		return strings.HasPrefix(args[0].(string), args[1].(string)), nil
+	}},
+	{Name: "endswith", Params: []string{"string", "string"}, Result: "bool", Call: func(args []any) (any, error) {
+		return strings.HasSuffix(args[0].(string), args[1].(string)), nil
+	}},
+	{Name: "pad", Params: []string{"any", "int", "string"}, Optional: 1, Result: "string", Bounded: pad},
+	{Name: "format", Params: []string{"string", "any"}, Variadic: true, Result: "string", Bounded: func(args []any, maxLength int) (any, error) {
+		return formatString(args[0].(string), args[1:], maxLength)
+	}},
+}
+
+func pad(args []any, maxLength int) (any, error) {
+	text, width := value.Stringify(args[0]), args[1].(int)
+	fill := " "
+	if len(args) == 3 {
+		fill = args[2].(string)
+	}
+	if utf8.RuneCountInString(fill) != 1 {
+		return nil, fmt.Errorf("pad() fill must be a single character, got %q", fill)
+	}
+
+	if width < 0 {
+		return nil, fmt.Errorf("pad() width must not be negative, got %d", width)
+	}
+	missing := width - utf8.RuneCountInString(text)
+	if missing <= 0 {
+		return text, nil
+	}
+	if err := checkLength("pad", len(text), missing, len(fill), maxLength); err != nil {
+		return nil, err
+	}
+	return strings.Repeat(fill, missing) + text, nil
+}
+
+// formatString implements format(). It supports a fixed set of verbs, each taking one argument:
+//
+//	%s  any value as it would be interpolated     %d  whole number
+//	%v  same as %s                                %f  number, %.2f with precision
+//	%q  value as a double-quoted string           %x  whole number in hexadecimal
+//	%%  a literal percent sign
+//
+// Unknown verbs and a verb count that differs from the argument count are errors, rather than
+// the "%!d(string=...)" markers Go would print into the generated Dockerfile. So is a result longer
+// than maxLength bytes, found before a long precision is written out.
+func formatString(layout string, args []any, maxLength int) (string, error) {
+	var out strings.Builder
+	next := 0
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			out.WriteByte(layout[i])
+			continue
+		}
+		i++
+		if i < len(layout) && layout[i] == '%' {
+			out.WriteByte('%')
+			continue
+		}
+
+		precision := -1
+		if i < len(layout) && layout[i] == '.' {
+			start := i + 1
+			for i = start; i < len(layout) && layout[i] >= '0' && layout[i] <= '9'; i++ {
+			}
+			precision, _ = strconv.Atoi(layout[start:i])
+		}
+		if i >= len(layout) {
+			return "", fmt.Errorf("format() layout ends with an incomplete verb")
+		}
+		verb := layout[i]
+		if precision >= 0 && verb != 'f' {
+			return "", fmt.Errorf("format() precision is only supported for %%f, got %%.%d%c", precision, verb)
+		}
+
+		if next >= len(args) {
+			return "", fmt.Errorf("format() layout has more verbs than the %d argument(s) given", len(args))
+		}
+		arg := args[next]
+		next++
+
+		var piece string
+		switch verb {
+		case 's', 'v':
+			piece = value.Stringify(arg)
+		case 'q':
+			piece = strconv.Quote(value.Stringify(arg))
+		case 'd', 'x':
+			n, ok := value.ToInt(arg)
+			if !ok {
+				return "", fmt.Errorf("format() %%%c needs a whole number, got %s %s", verb, value.TypeName(arg), value.Stringify(arg))
+			}
+			base := 10
+			if verb == 'x' {
+				base = 16
+			}
+			piece = strconv.FormatInt(int64(n), base)
+		case 'f':
+			f, ok := value.ToFloat(arg)
+			if !ok {
+				return "", fmt.Errorf("format() %%f needs a number, got %s %s", value.TypeName(arg), value.Stringify(arg))
+			}
+			if precision < 0 {
+				precision = 6
+			}
+			if err := checkLength("format", out.Len(), precision, 1, maxLength); err != nil {
+				return "", err
+			}
+			piece = strconv.FormatFloat(f, 'f', precision, 64)
+		default:
+			r, _ := utf8.DecodeRuneInString(layout[i:])
+			return "", fmt.Errorf("format() does not support the verb %%%c", r)
+		}
+		if err := checkLength("format", out.Len()+len(piece), 0, 0, maxLength); err != nil {
+			return "", err
+		}
+		out.WriteString(piece)
+	}
+	if next < len(args) {
+		return "", fmt.Errorf("format() got %d argument(s) but the layout only uses %d", len(args), next)
+	}
+	return out.String(), nil
+}
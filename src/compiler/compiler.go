@@ -1,32 +1,77 @@
 package compiler
 
 import (
+	"context"
+	"docklett/compiler/analysis"
 	"docklett/compiler/ast"
+	"docklett/compiler/builtin"
+	"docklett/compiler/evaluator"
 	"docklett/compiler/parser"
+	"docklett/compiler/resolver"
 	"docklett/compiler/scanner"
 	"docklett/compiler/token"
+	"docklett/compiler/translator"
+	"docklett/compiler/types"
+	"errors"
+	"fmt"
+	"sort"
 )
 
 type Compiler struct {
 	Scanner         *scanner.Scanner
 	Parser          *parser.Parser
+	Translator      *translator.Translator
 	InputFilePath   string
 	InputFileName   string
 	GeneratedTokens []token.Token
 	GeneratedAST    ast.Expression
-	HasError        bool
+	Statements      []ast.Statement
+	Resolution      *resolver.Resolution
+	TypeInfo        *types.Info
+	Warnings        []analysis.Warning // non-blocking diagnostics, see package analysis
+	Strict          bool               // redefining a @SET variable in the same scope is an error
+	TypeConfig      types.Config
+	// StrictInterpolation makes ${name} an error unless name is a Docklett variable or an earlier ARG/ENV
+	StrictInterpolation bool
+	// ContextRoot is the build context read by file_exists, read_file, ...; empty means the
+	// directory of the source file (Scanner.SourcePath)
+	ContextRoot string
+	AllowEnv    []string // environment variables env() may read, names or patterns such as "CI_*"
+	// Vars are build variables from -var and -var-file, bound before the file runs; each must be
+	// declared by a @DEFAULT or read by the file, so a misspelled name is not silently ignored
+	Vars map[string]any
+	// Limits bound the resources the compilation may use, for files from an untrusted source;
+	// the zero value is unlimited, see evaluator.Limits
+	Limits evaluator.Limits
+	// Trace receives every condition, loop iteration, interpolation and instruction of the
+	// translation, see translator.TextTrace and translator.JSONTrace; nil disables tracing
+	Trace    func(translator.TraceEvent)
+	Report   Report
+	HasError bool
+}
+
+// Report lists what a compilation read besides its source, so a build cache knows what to watch.
+type Report struct {
+	Inputs []string `json:"inputs"` // build context files read, relative to the context root
+	Env    []string `json:"env"`    // names of host environment variables read by env()
 }
 
 func NewCompiler() *Compiler {
 	return &Compiler{
-		Scanner:  &scanner.Scanner{},
-		Parser:   &parser.Parser{},
-		HasError: false,
+		Scanner:    &scanner.Scanner{},
+		Parser:     &parser.Parser{},
+		Translator: translator.NewTranslator(),
+		HasError:   false,
 	}
 }
 
 // main entry point
 func (c *Compiler) Run(inputFilePath string) error {
+	return c.RunContext(context.Background(), inputFilePath)
+}
+
+// RunContext compiles like Run, stopping with a LimitError once ctx is canceled or its deadline passes.
+func (c *Compiler) RunContext(ctx context.Context, inputFilePath string) error {
 	c.InputFilePath = inputFilePath
 
 	err := c.Scanner.ReadSource(inputFilePath)
@@ -44,15 +89,95 @@ func (c *Compiler) Run(inputFilePath string) error {
 	}
 
 	c.GeneratedTokens = c.Scanner.Tokens
-	// statements, err := c.Parser.Parse(c.GeneratedTokens)
-	// _, err := c.Interpreter.Interpret(statements)
+	c.Statements, err = c.Parser.Parse(c.GeneratedTokens)
+	if err != nil {
+		c.HasError = true
+		return err
+	}
+
+	// catch undefined names and redefinitions in every branch before evaluation only visits the taken ones
+	names := make([]string, 0, len(c.Vars))
+	for name := range c.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if c.Strict {
+		c.Resolution, err = resolver.ResolveStrict(c.Statements, names...)
+	} else {
+		c.Resolution, err = resolver.Resolve(c.Statements, names...)
+	}
+	if err != nil {
+		c.HasError = true
+		return err
+	}
+	if err = unknownVars(c.Resolution, names); err != nil {
+		c.HasError = true
+		return err
+	}
 
+	c.Warnings = analysis.AnalyzeContext(ctx, c.Statements, c.Resolution, c.Limits)
+
+	c.TypeConfig.Vars = c.Vars
+	c.TypeInfo, err = types.Check(c.Statements, c.TypeConfig)
 	if err != nil {
 		c.HasError = true
 		return err
 	}
 
-	// c.GeneratedAST = ast
+	root := c.ContextRoot
+	if root == "" {
+		root = c.Scanner.SourcePath
+	}
+	host := builtin.NewHost(root)
+	host.AllowEnv = c.AllowEnv
+	host.NoFiles, host.NoEnv = c.Limits.NoFiles, c.Limits.NoEnv
+	c.Translator.Host = host
+	c.Translator.Limits = c.Limits
+	c.Translator.Context = ctx
+	c.Translator.SetStrict(c.Strict)
+	c.Translator.SetStrictInterpolation(c.StrictInterpolation)
+	c.Translator.SetVars(c.Vars)
+	c.Translator.SetTrace(c.Trace)
+	err = c.Translator.Translate(c.Statements)
+	c.Report = Report{Inputs: host.Inputs(), Env: host.EnvVars()}
+	if err != nil {
+		c.HasError = true
+		return err
+	}
 
 	return nil
 }
+
+// unknownVars reports the build variables that no @DEFAULT declares and nothing reads. A name the
+// file binds with @SET or @CONST gets its own message: only a @DEFAULT can be overridden.
+func unknownVars(resolution *resolver.Resolution, names []string) error {
+	defaults := make(map[string]bool)
+	bound := make(map[string]*resolver.Declaration)
+	for _, decl := range resolution.Declarations {
+		switch decl.Kind {
+		case resolver.Default:
+			defaults[decl.Name.Lexeme] = true
+		case resolver.Variable, resolver.Constant:
+			if bound[decl.Name.Lexeme] == nil {
+				bound[decl.Name.Lexeme] = decl
+			}
+		}
+	}
+	var errs []error
+	for _, name := range names {
+		if defaults[name] || len(resolution.Predeclared[name].References) > 0 {
+			continue
+		}
+		if decl := bound[name]; decl != nil {
+			directive := "@SET"
+			if decl.Kind == resolver.Constant {
+				directive = "@CONST"
+			}
+			errs = append(errs, fmt.Errorf("cannot override %s %s (line %d) with build variable '%s': declare it with @DEFAULT %s to let it be set",
+				directive, name, decl.Name.Line, name, name))
+			continue
+		}
+		errs = append(errs, fmt.Errorf("unknown build variable '%s': the file has no @DEFAULT %s and never reads it", name, name))
+	}
+	return errors.Join(errs...)
+}
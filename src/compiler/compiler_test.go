@@ -0,0 +1,67 @@
+package compiler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	compileError "docklett/compiler/error"
+	"docklett/compiler/evaluator"
+)
+
+func TestRunContext_SandboxBoundsConstantFolding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "huge.dock")
+	source := "@IF len(range(0, 200000000)) > 0\nRUN echo big\n@END\n"
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c := NewCompiler()
+	c.Limits = evaluator.SandboxLimits
+	start := time.Now()
+	err := c.RunContext(ctx, path)
+	var limit *compileError.LimitError
+	if !errors.As(err, &limit) {
+		t.Fatalf("error = %v, want a LimitError", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("compilation took %v, want it to stop at the collection limit", elapsed)
+	}
+	if len(c.Warnings) != 0 {
+		t.Errorf("warnings = %v, want none: the condition is too large to evaluate", c.Warnings)
+	}
+}
+
+func TestRunContext_UnknownVars(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"RUN echo hi\n", "unknown build variable 'MODE': the file has no @DEFAULT MODE and never reads it"},
+		{"@SET MODE = \"dev\"\nRUN echo ${MODE}\n",
+			"cannot override @SET MODE (line 1) with build variable 'MODE': declare it with @DEFAULT MODE to let it be set"},
+		{"@CONST MODE = \"dev\"\n",
+			"cannot override @CONST MODE (line 1) with build variable 'MODE': declare it with @DEFAULT MODE to let it be set"},
+		{"@DEFAULT MODE = \"dev\"\n", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.source, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "vars.dock")
+			if err := os.WriteFile(path, []byte(tc.source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			c := NewCompiler()
+			c.Vars = map[string]any{"MODE": "prod"}
+			err := c.Run(path)
+			if tc.want == "" && err != nil || tc.want != "" && (err == nil || err.Error() != tc.want) {
+				t.Errorf("error = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Package cst provides a lossless concrete syntax tree for Docklett files.
+
+The regular pipeline throws away everything the parser does not need: comments, blank lines,
+indentation and line continuations. Tools that rewrite Docklett files (formatters, refactorings)
+need all of it, so the CST keeps the scanner running in lossless mode:
+
+	Source → Scanner{Lossless: true} → Tokens with trivia → Parser → File{Statements, Tokens}
+
+Every token carries the trivia around it:
+
+	LeadingTrivia:  blank lines, comment lines and spacing written before the token
+	TrailingTrivia: spacing and a comment after the last token of a line
+
+Because AST nodes store copies of their tokens, each node keeps the trivia of its own tokens,
+e.g. the comment above a RUN instruction is in DockerStatement.Keyword.LeadingTrivia.
+The File also keeps the full token stream (including NLINE and EOF) so that
+
+	cst.Parse(name, src).Source() == src
+
+holds byte for byte.
+*/
+package cst
+
+import (
+	"docklett/compiler/ast"
+	"docklett/compiler/parser"
+	"docklett/compiler/scanner"
+	"docklett/compiler/token"
+	"sort"
+	"strings"
+)
+
+// File is the concrete syntax tree of one Docklett source file.
+type File struct {
+	Name       string          // file name used in token positions
+	Statements []ast.Statement // top-level statements, tokens on nodes carry their trivia
+	Tokens     []token.Token   // complete token stream, ending with EOF
+}
+
+// Parse scans source in lossless mode and parses it into a File.
+func Parse(name string, source string) (*File, error) {
+	s := &scanner.Scanner{SourceName: name, Source: source, Lossless: true}
+	if err := s.ScanSource(); err != nil {
+		return nil, err
+	}
+
+	p := &parser.Parser{}
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Name: name, Statements: statements, Tokens: s.Tokens}, nil
+}
+
+// Source reproduces the original source text.
+func (f *File) Source() string {
+	var sb strings.Builder
+	for _, tok := range f.Tokens {
+		writeToken(&sb, tok)
+	}
+	return sb.String()
+}
+
+// NodeSource returns the original text of node, from its first token to its last,
+// including trivia between those tokens but not the node's outer leading or trailing trivia.
+func (f *File) NodeSource(node ast.Node) string {
+	first := f.indexAt(node.Pos())
+	last := f.indexEndingAt(node.End())
+	if first < 0 || last < first {
+		return ""
+	}
+
+	var sb strings.Builder
+	for i := first; i <= last; i++ {
+		tok := f.Tokens[i]
+		if i > first {
+			writeTrivia(&sb, tok.LeadingTrivia)
+		}
+		sb.WriteString(tok.Lexeme)
+		if i < last {
+			writeTrivia(&sb, tok.TrailingTrivia)
+		}
+	}
+	return sb.String()
+}
+
+// TokenAt returns the token starting at pos.
+func (f *File) TokenAt(pos token.Position) (token.Token, bool) {
+	if i := f.indexAt(pos); i >= 0 {
+		return f.Tokens[i], true
+	}
+	return token.Token{}, false
+}
+
+// TokenEndingAt returns the token whose lexeme ends at pos, such as the last token of a node.
+func (f *File) TokenEndingAt(pos token.Position) (token.Token, bool) {
+	if i := f.indexEndingAt(pos); i >= 0 {
+		return f.Tokens[i], true
+	}
+	return token.Token{}, false
+}
+
+// EOF returns the end-of-file token, whose leading trivia holds whatever follows the last statement.
+func (f *File) EOF() token.Token {
+	return f.Tokens[len(f.Tokens)-1]
+}
+
+// Comments returns the text of every comment in a trivia list, in source order.
+func Comments(trivia []token.Trivia) []string {
+	var comments []string
+	for _, t := range trivia {
+		if t.Kind == token.COMMENT {
+			comments = append(comments, t.Text)
+		}
+	}
+	return comments
+}
+
+// BlankLines counts the empty lines in a trivia list, ignoring line breaks of comment lines.
+func BlankLines(trivia []token.Trivia) int {
+	blank := 0
+	afterComment := false
+	for _, t := range trivia {
+		switch t.Kind {
+		case token.COMMENT:
+			afterComment = true
+		case token.LINE_BREAK:
+			if !afterComment {
+				blank++
+			}
+			afterComment = false
+		}
+	}
+	return blank
+}
+
+func (f *File) indexAt(pos token.Position) int {
+	i := sort.Search(len(f.Tokens), func(i int) bool {
+		return !before(f.Tokens[i].Position, pos)
+	})
+	for ; i < len(f.Tokens) && f.Tokens[i].Position == pos; i++ {
+		// zero-width tokens (empty Docker args) share their position with the next token
+		if f.Tokens[i].Lexeme != "" || f.Tokens[i].Type == token.EOF {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *File) indexEndingAt(pos token.Position) int {
+	for i := len(f.Tokens) - 1; i >= 0; i-- {
+		tok := f.Tokens[i]
+		if tok.Lexeme != "" && tok.End() == pos {
+			return i
+		}
+		if before(tok.Position, pos) && tok.Lexeme != "" && before(tok.End(), pos) {
+			break
+		}
+	}
+	return -1
+}
+
+func before(a, b token.Position) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Col < b.Col
+}
+
+func writeToken(sb *strings.Builder, tok token.Token) {
+	writeTrivia(sb, tok.LeadingTrivia)
+	sb.WriteString(tok.Lexeme)
+	writeTrivia(sb, tok.TrailingTrivia)
+}
+
+func writeTrivia(sb *strings.Builder, trivia []token.Trivia) {
+	for _, t := range trivia {
+		sb.WriteString(t.Text)
+	}
+}
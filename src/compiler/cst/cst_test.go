@@ -0,0 +1,97 @@
+package cst
+
+import (
+	"strings"
+	"testing"
+
+	"docklett/compiler/ast"
+	"docklett/compiler/token"
+)
+
+var roundTripSources = map[string]string{
+	"empty":                    "",
+	"only comments":            "# header\n\n# another\n",
+	"dockerfile":               "FROM python:3.12-slim\nWORKDIR /app\nCOPY requirements.txt ./\nRUN pip install -r requirements.txt\nCMD [\"python\", \"app.py\"]\n",
+	"no final newline":         "FROM alpine\nRUN echo done",
+	"spacing":                  "  FROM   alpine:3.19   \n\t@SET   x   =   1 +2   \n",
+	"comments and blank lines": "# build file\n\n\nFROM alpine\n\n# install\n@IF MODE == \"prod\"   # production only\n  RUN apk add curl\n\n@ELSE\n  # dev tools\n  RUN apk add vim\n@END # done\n\n# trailing comment\n",
+	"continuations":            "RUN apt-get update \\\n    && apt-get install -y \\\n       curl   \n@SET pkgs = [\"curl\", \\\n    \"git\"]\n",
+	"crlf":                     "FROM alpine\r\n@SET x = 1\r\nRUN echo ${x}\r\n",
+	"unicode":                  "# café ☕\n@SET name = \"naïve\"\nRUN echo ${name} # ünïcode\n",
+	"for loop":                 "@FOR pkg IN [\"curl\", \"git\"]\n    RUN apk add ${pkg}\n@END\n",
+	"empty args":               "RUN\nFROM alpine\n",
+}
+
+func TestParse_RoundTrip(t *testing.T) {
+	for name, source := range roundTripSources {
+		t.Run(name, func(t *testing.T) {
+			file, err := Parse("test.dock", source)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got := file.Source(); got != source {
+				t.Errorf("round trip mismatch:\n got  %q\n want %q", got, source)
+			}
+		})
+	}
+}
+
+func TestParse_TriviaOnNodes(t *testing.T) {
+	source := "FROM alpine\n\n# install curl\nRUN apk add curl   # inline is args\n@SET x = 1 # one\n@IF x == 1\n  RUN echo one\n# before else\n@ELSE\n  RUN echo other\n@END\n# the end\n"
+	file, err := Parse("test.dock", source)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	run := file.Statements[1].(*ast.DockerStatement)
+	if got := Comments(run.Keyword.LeadingTrivia); len(got) != 1 || got[0] != "# install curl" {
+		t.Errorf("comment above RUN: got %q", got)
+	}
+	if got := BlankLines(run.Keyword.LeadingTrivia); got != 1 {
+		t.Errorf("blank lines above RUN: got %d, want 1", got)
+	}
+	if run.Args != "apk add curl   # inline is args" {
+		t.Errorf("docker args changed: %q", run.Args)
+	}
+
+	decl := file.Statements[2].(*ast.VariableDeclarationStatement)
+	last, ok := file.TokenEndingAt(decl.End())
+	if !ok || len(Comments(last.TrailingTrivia)) != 1 || Comments(last.TrailingTrivia)[0] != "# one" {
+		t.Errorf("trailing comment of @SET: got %+v", last.TrailingTrivia)
+	}
+
+	ifStmt := file.Statements[3].(*ast.IfStatement)
+	elseBlock := ifStmt.ElseBranch.(*ast.BlockStatement)
+	if got := Comments(elseBlock.Open.LeadingTrivia); len(got) != 1 || got[0] != "# before else" {
+		t.Errorf("comment above @ELSE: got %q", got)
+	}
+	if got := Comments(file.EOF().LeadingTrivia); len(got) != 1 || got[0] != "# the end" {
+		t.Errorf("comment at end of file: got %q", got)
+	}
+
+	if got := file.NodeSource(ifStmt.Condition); got != "x == 1" {
+		t.Errorf("NodeSource(condition): got %q", got)
+	}
+}
+
+func TestParse_DirectiveContinuation(t *testing.T) {
+	file, err := Parse("test.dock", "@SET pkgs = [\"curl\", \\\n  \"git\"]\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	array := file.Statements[0].(*ast.VariableDeclarationStatement).Initializer.(*ast.ArrayLiteralExpression)
+	if len(array.Elements) != 2 {
+		t.Fatalf("got %d elements, want 2", len(array.Elements))
+	}
+	second, _ := file.TokenAt(array.Elements[1].Pos())
+	continued := false
+	for _, trivia := range second.LeadingTrivia {
+		continued = continued || trivia.Kind == token.CONTINUATION
+	}
+	if !continued {
+		t.Errorf("continuation trivia: got %+v", second.LeadingTrivia)
+	}
+	if !strings.Contains(file.Source(), "\\\n") {
+		t.Errorf("continuation lost from source")
+	}
+}
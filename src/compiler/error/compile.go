@@ -8,15 +8,26 @@ and the Docker engine will be handling execution. As such all errors in compiler
 Error Types:
   - ScanError: Lexical analysis errors (unknown characters, malformed tokens)
   - ParseError: Syntax analysis errors (unexpected tokens, grammar violations)
+  - RedefinitionError: A binding that conflicts with an earlier declaration (@CONST, strict mode)
+  - UndefinedVariableError: A reference to a variable that no reachable scope binds
+  - EvaluationError: An expression that cannot be evaluated (type mismatch, division by zero, zero range step)
+  - TypeError: A static type mismatch found by the type checker, covering the exact span of the offending node
+  - LimitError: A resource budget of the compilation that was used up (iterations, deadline, ...)
 
 EXAMPLES:
 
 	ScanError:  Unknown character '@#' at line 5
 	ParseError: Expected ')' after expression at line 10
+	RedefinitionError: cannot redefine constant 'BASE' (previously declared at line 2, column 8)
+	UndefinedVariableError: [line 7] undefined variable 'VERSON'
+	EvaluationError: [line 9] range step cannot be zero
+	TypeError: [line 4] cannot compare string with int (line 4, column 5-20)
+	LimitError: [line 3] compilation exceeded the limit of 1000 Docker instructions
 */
 package error
 
 import (
+	"docklett/compiler/ast"
 	"docklett/compiler/token"
 	"fmt"
 )
@@ -44,10 +55,7 @@ func (e *ScanError) GetLine() int {
 }
 
 func (e *ScanError) GetLocation() string {
-	if e.File != "" {
-		return fmt.Sprintf("file %s, line %d, column %d", e.File, e.Line, e.Column)
-	}
-	return fmt.Sprintf("line %d, column %d", e.Line, e.Column)
+	return formatLocation(token.Position{Line: e.Line, Col: e.Column, File: e.File})
 }
 
 func NewScanError(line, column int, file, message string) *ScanError {
@@ -89,33 +97,178 @@ func NewParseError(tok token.Token, message string) *ParseError {
 
 // TranslatorError represents AST-to-LLB translation errors
 type TranslatorError struct {
-	Line    int
+	Node    ast.Node // statement or expression being translated, nil when unknown
 	Message string
 }
 
 func (e *TranslatorError) Error() string {
-	if e.Line > 0 {
-		return fmt.Sprintf("Compile Error: [line %d] %s", e.Line, e.Message)
+	if line := e.GetLine(); line > 0 {
+		return fmt.Sprintf("Compile Error: [line %d] %s", line, e.Message)
 	}
 	return fmt.Sprintf("Compile Error: %s", e.Message)
 }
 
 func (e *TranslatorError) GetLine() int {
-	return e.Line
+	return nodePosition(e.Node).Line
 }
 
 func (e *TranslatorError) GetLocation() string {
-	return fmt.Sprintf("line %d", e.Line)
+	return formatLocation(nodePosition(e.Node))
 }
 
-func NewTranslatorError(line int, message string) *TranslatorError {
+func NewTranslatorError(node ast.Node, message string) *TranslatorError {
 	return &TranslatorError{
-		Line:    line,
+		Node:    node,
 		Message: message,
 	}
 }
 
-// PanicTranslatorError panics with a translator compile error
-func PanicTranslatorError(line int, message string) {
-	panic(NewTranslatorError(line, message))
+// RedefinitionError reports a declaration or assignment that conflicts with an earlier binding.
+// Both positions are kept so tools can point at the new name and at the original declaration.
+type RedefinitionError struct {
+	Name     token.Token // identifier being redefined or assigned
+	Previous token.Token // identifier of the earlier declaration
+	Message  string
+}
+
+func (e *RedefinitionError) Error() string {
+	return fmt.Sprintf("Compile Error: [line %d] %s (previously declared at line %d, column %d)",
+		e.Name.Position.Line, e.Message, e.Previous.Position.Line, e.Previous.Position.Col)
+}
+
+func (e *RedefinitionError) GetLine() int {
+	return e.Name.Position.Line
+}
+
+func (e *RedefinitionError) GetLocation() string {
+	return formatLocation(e.Name.Position)
+}
+
+func NewRedefinitionError(name, previous token.Token, message string) *RedefinitionError {
+	return &RedefinitionError{
+		Name:     name,
+		Previous: previous,
+		Message:  message,
+	}
+}
+
+// UndefinedVariableError reports a reference to a variable that is not bound in any reachable scope.
+type UndefinedVariableError struct {
+	Name token.Token // identifier that referenced the variable
+}
+
+func (e *UndefinedVariableError) Error() string {
+	return fmt.Sprintf("Compile Error: [line %d] undefined variable '%s'", e.Name.Position.Line, e.Name.Lexeme)
+}
+
+func (e *UndefinedVariableError) GetLine() int {
+	return e.Name.Position.Line
+}
+
+func (e *UndefinedVariableError) GetLocation() string {
+	return formatLocation(e.Name.Position)
+}
+
+func NewUndefinedVariableError(name token.Token) *UndefinedVariableError {
+	return &UndefinedVariableError{Name: name}
+}
+
+// EvaluationError represents a failure while evaluating an expression at compile time
+type EvaluationError struct {
+	Node    ast.Node // expression that failed
+	Message string
+}
+
+func (e *EvaluationError) Error() string {
+	if line := e.GetLine(); line > 0 {
+		return fmt.Sprintf("Compile Error: [line %d] %s", line, e.Message)
+	}
+	return fmt.Sprintf("Compile Error: %s", e.Message)
+}
+
+func (e *EvaluationError) GetLine() int {
+	return nodePosition(e.Node).Line
+}
+
+func (e *EvaluationError) GetLocation() string {
+	return formatLocation(nodePosition(e.Node))
+}
+
+func NewEvaluationError(node ast.Node, message string) *EvaluationError {
+	return &EvaluationError{
+		Node:    node,
+		Message: message,
+	}
+}
+
+// TypeError represents a static type mismatch. The whole span of Node is reported, not just its start.
+type TypeError struct {
+	Node    ast.Node // expression, annotation or statement with the wrong type
+	Message string
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("Compile Error: [line %d] %s", e.GetLine(), e.Message)
+}
+
+func (e *TypeError) GetLine() int {
+	return nodePosition(e.Node).Line
+}
+
+// GetLocation reports the span: "line 4, column 5-20", or "line 4, column 5 - line 6, column 2" across lines.
+func (e *TypeError) GetLocation() string {
+	if e.Node == nil {
+		return formatLocation(token.Position{})
+	}
+	pos, end := e.Node.Pos(), e.Node.End()
+	location := fmt.Sprintf("line %d, column %d-%d", pos.Line, pos.Col, end.Col)
+	if end.Line != pos.Line {
+		location = fmt.Sprintf("line %d, column %d - line %d, column %d", pos.Line, pos.Col, end.Line, end.Col)
+	}
+	if pos.File != "" {
+		return fmt.Sprintf("file %s, %s", pos.File, location)
+	}
+	return location
+}
+
+func NewTypeError(node ast.Node, message string) *TypeError {
+	return &TypeError{
+		Node:    node,
+		Message: message,
+	}
+}
+
+// LimitError reports a resource budget that a compilation used up, so a service compiling untrusted
+// files can tell it apart from a mistake in the file. Err is the cause, if any, e.g. context.DeadlineExceeded.
+type LimitError struct {
+	Node    ast.Node // statement or expression that went over the budget
+	Message string
+	Err     error
+}
+
+func (e *LimitError) Error() string {
+	if line := e.GetLine(); line > 0 {
+		return fmt.Sprintf("Compile Error: [line %d] %s", line, e.Message)
+	}
+	return fmt.Sprintf("Compile Error: %s", e.Message)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+func (e *LimitError) GetLine() int {
+	return nodePosition(e.Node).Line
+}
+
+func (e *LimitError) GetLocation() string {
+	return formatLocation(nodePosition(e.Node))
+}
+
+func NewLimitError(node ast.Node, message string, err error) *LimitError {
+	return &LimitError{
+		Node:    node,
+		Message: message,
+		Err:     err,
+	}
 }
@@ -9,7 +9,6 @@ Error Types:
 EXAMPLES:
 
 	Runtime Error: [line 10] division by zero
-	Runtime Error: [line 15] undefined variable 'x'
 	Runtime Error: [line 20] subtraction operation requires number, got string
 */
 package error
@@ -23,17 +22,17 @@ import (
 type RuntimeError interface {
 	error
 	GetLine() int
-	GetExpression() ast.Expression
+	GetNode() ast.Node
 }
 
 // InterpreterError represents runtime execution errors
 type InterpreterError struct {
-	Expression ast.Expression
-	Message    string
+	Node    ast.Node // expression or statement that failed
+	Message string
 }
 
 func (e *InterpreterError) Error() string {
-	line := getExpressionLine(e.Expression)
+	line := e.GetLine()
 	if line > 0 {
 		return fmt.Sprintf("Runtime Error: [line %d] %s", line, e.Message)
 	}
@@ -41,48 +40,33 @@ func (e *InterpreterError) Error() string {
 }
 
 func (e *InterpreterError) GetLine() int {
-	return getExpressionLine(e.Expression)
+	return nodePosition(e.Node).Line
 }
 
-func (e *InterpreterError) GetExpression() ast.Expression {
-	return e.Expression
+func (e *InterpreterError) GetNode() ast.Node {
+	return e.Node
 }
 
-func NewInterpreterError(expr ast.Expression, message string) *InterpreterError {
+func NewInterpreterError(node ast.Node, message string) *InterpreterError {
 	return &InterpreterError{
-		Expression: expr,
-		Message:    message,
+		Node:    node,
+		Message: message,
 	}
 }
 
-// NewRuntimeErrorFromToken creates a runtime error from a token context (for use in environment)
-func NewRuntimeErrorFromToken(tok token.Token, message string) error {
-	line := tok.Position.Line
-	if line > 0 {
-		return fmt.Errorf("Runtime Error: [line %d] %s", line, message)
+// nodePosition returns where a node starts, or the zero position when no node is attached
+func nodePosition(node ast.Node) token.Position {
+	if node == nil {
+		return token.Position{}
 	}
-	return fmt.Errorf("Runtime Error: %s", message)
-}
-
-// PanicRuntimeError panics with a runtime error
-func PanicRuntimeError(tok token.Token, message string) {
-	panic(NewRuntimeErrorFromToken(tok, message))
+	return node.Pos()
 }
 
-// getExpressionLine extracts line number from expression's first token
-func getExpressionLine(expr ast.Expression) int {
-	switch e := expr.(type) {
-	case *ast.LiteralExpression:
-		return e.Token.Position.Line
-	case *ast.UnaryExpression:
-		return e.Operator.Position.Line
-	case *ast.BinaryExpression:
-		return e.Operator.Position.Line
-	case *ast.GroupingExpression:
-		return getExpressionLine(e.Expression)
-	case *ast.VariableExpression:
-		return e.Name.Position.Line
-	default:
-		return 0
+// formatLocation prints a position as "line 3, column 5", prefixed with "file app.dock, " when the
+// source has a name.
+func formatLocation(pos token.Position) string {
+	if pos.File != "" {
+		return fmt.Sprintf("file %s, line %d, column %d", pos.File, pos.Line, pos.Col)
 	}
+	return fmt.Sprintf("line %d, column %d", pos.Line, pos.Col)
 }
@@ -0,0 +1,749 @@
+/*
+Package evaluator evaluates expression AST nodes to values at compile time.
+It is the single implementation of expression semantics, shared by the Interpreter and the Translator,
+so both agree on every operator. Each Visit method handles one expression type, delegating to child
+expressions as needed.
+
+TYPE COERCION RULES:
+ 1. Numeric operations: int with int stays int (2 + 3 → 5), anything with a float is float64
+    (3 + 2.5 → 5.5); "/" always divides as floats (7 / 2 → 3.5), "//" floors (7 // 2 → 3,
+    -7 // 2 → -4) and "%" takes the sign of the divisor (-7 % 3 → 2). Int results that do not fit
+    in 64 bits and division or modulo by zero are errors
+ 2. String concatenation: + operator only ("hello" + " world")
+ 3. Equality: works across all types (5 == 5.0 → true, "5" == 5 → false), see value.Equal
+ 4. Comparison: numbers, strings ("a" < "b" uses lexicographic order) and versions
+    (semver("10.0.0") > "9.1.0" uses version precedence, parsing a string operand as a version)
+ 5. Logical operators: short-circuit on truthiness, see value.Truthy; "??" gives its right operand
+    when the left one is nil or an undefined variable (CUDA ?? "12.2"); "and" and "or" are
+    other spellings of "&&" and "||"
+ 6. Negation (!): booleans only
+ 7. Membership (in): an element of an array by equality (ARCH in ["amd64", "arm64"]), a key of a
+    map, or a substring of a string ("alpine" in IMAGE)
+
+RANGES:
+range(start, stop[, step]) produces the ints from start up to, not including, stop:
+
+	range(0, 3)       → [0, 1, 2]
+	range(5, 0, -2)   → [5, 3, 1]
+	range(3, 0)       → []            (positive default step never reaches stop)
+	range(0, 3, 0)    → error: range step cannot be zero
+
+CALLS:
+Built-in functions (upper, replace, format, ...) are looked up in package builtin. Arguments are
+evaluated left to right; arity and argument type errors are reported at the call:
+
+	upper(1)          → error: upper() argument 1 must be string, got int
+
+Impure built-ins such as read_file run against Host; with a nil Host they are errors.
+defined(NAME) is the exception to argument evaluation: it looks NAME up and never fails on it.
+
+COMPREHENSIONS:
+[expr for x in items if cond] evaluates items once, then expr for every item that passes cond, with x
+bound in a scope of its own that is discarded afterwards. Like @FOR, a comprehension stops with an
+error after MaxIterations items:
+
+	[p + "-dev" for p in ["a", "b"]]        → ["a-dev", "b-dev"]
+	[i * i for i in range(0, 5) if i > 2]   → [9, 16]
+	[k + "=" + v for k, v in {"a": "1"}]    → ["a=1"]
+
+BUILD VARIABLES:
+Vars holds the values given on the command line. A @DEFAULT declaration whose name is in Vars binds
+that value and skips its initializer; its type annotation still applies.
+
+MEMBERS:
+Versions expose their components: semver("1.2.3-rc.1").minor → 2, .prerelease → "rc.1".
+
+USAGE:
+Embed an Evaluator to get the ExpressionVisitor methods, and point Scope at the innermost scope:
+
+	type Translator struct {
+		*evaluator.Evaluator
+	}
+*/
+package evaluator
+
+import (
+	"context"
+	"docklett/compiler/ast"
+	"docklett/compiler/builtin"
+	compileError "docklett/compiler/error"
+	"docklett/compiler/scope"
+	"docklett/compiler/token"
+	"docklett/compiler/types"
+	"docklett/compiler/value"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Compile-time check to ensure Evaluator implements ExpressionVisitor
+var _ ast.ExpressionVisitor = (*Evaluator)(nil)
+
+// DefaultMaxIterations is the iteration limit of a @FOR loop or comprehension unless changed.
+const DefaultMaxIterations = 10000
+
+// Evaluator resolves variables in Scope, which its owner replaces while entering and leaving blocks.
+type Evaluator struct {
+	Scope         *scope.Scope
+	Host          *builtin.Host  // build context for impure built-ins, nil to disallow them
+	Vars          map[string]any // build variables that override @DEFAULT initializers
+	MaxIterations int            // items a single loop or comprehension may go through, and the length of a range
+	Limits        Limits         // budgets of the whole compilation, see Limits
+	// Context is checked on every loop iteration; its end stops the compilation. Nil never ends.
+	// It is a field because evaluation runs through Visit methods that cannot take one.
+	Context context.Context
+
+	iterations int // loop items so far, for Limits.MaxTotalIterations
+	depth      int // current nesting, for Limits.MaxDepth
+}
+
+func New(s *scope.Scope) *Evaluator {
+	return &Evaluator{Scope: s, MaxIterations: DefaultMaxIterations}
+}
+
+// Evaluate computes the value of expr, within Limits.
+func (e *Evaluator) Evaluate(expr ast.Expression) (any, error) {
+	if err := e.Enter(expr); err != nil {
+		return nil, err
+	}
+	defer e.Leave()
+	val, err := expr.Accept(e)
+	if err != nil {
+		return nil, err
+	}
+	return val, e.CheckSize(expr, val)
+}
+
+// Declare evaluates a @SET, @CONST or @DEFAULT initializer and binds the result in Scope.
+// A type annotation is enforced on the value, so "any" values the type checker could not see still fail here.
+func (e *Evaluator) Declare(stmt *ast.VariableDeclarationStatement) error {
+	var val any = nil
+	override, overridden := e.Vars[stmt.Name.Lexeme]
+	overridden = overridden && stmt.IsDefault()
+	if overridden {
+		val = types.BuildVariable(stmt, override)
+	} else if stmt.Initializer != nil {
+		var err error
+		val, err = e.Evaluate(stmt.Initializer)
+		if err != nil {
+			return err
+		}
+	}
+
+	if stmt.Type != nil {
+		declared, err := types.FromAnnotation(stmt.Type)
+		if err != nil {
+			return compileError.NewTypeError(stmt.Type, err.Error())
+		}
+		if overridden && !types.Conforms(val, declared) {
+			return compileError.NewTypeError(stmt.Type, fmt.Sprintf("cannot use %s value %s of build variable '%s' as %s",
+				types.Of(val), value.Stringify(val), stmt.Name.Lexeme, declared))
+		}
+		if stmt.Initializer != nil && !types.Conforms(val, declared) {
+			return compileError.NewTypeError(stmt.Initializer, fmt.Sprintf("cannot use %s value %s as %s in declaration of '%s'",
+				types.Of(val), value.Stringify(val), declared, stmt.Name.Lexeme))
+		}
+	}
+	return e.Scope.Declare(stmt.Name, val, stmt.IsConstant())
+}
+
+// VisitLiteralExpr evaluates a literal expression by returning its stored value.
+// This is the terminal case in expression evaluation - no further recursion needed.
+func (e *Evaluator) VisitLiteralExpr(literal *ast.LiteralExpression) (any, error) {
+	return literal.Value, nil
+}
+
+// VisitVariableExpr evaluates a variable reference by looking it up in the scope chain.
+// Undefined variables return an UndefinedVariableError carrying the identifier.
+//
+//	Source: x + 5  (where @SET x = 10 was executed earlier)
+//	Evaluate: Get("x") → 10
+func (e *Evaluator) VisitVariableExpr(variable *ast.VariableExpression) (any, error) {
+	return e.Scope.Get(variable.Name)
+}
+
+// VisitUnaryExpr evaluates unary operators (prefix operators) by evaluating operand then applying operator.
+//
+//	! (NEGATE): boolean negation, requires boolean operand
+//	- (SUBTRACT): numeric negation, requires int or float64 operand
+func (e *Evaluator) VisitUnaryExpr(unary *ast.UnaryExpression) (any, error) {
+	right, err := e.Evaluate(unary.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch unary.Operator.Type {
+	case token.NEGATE:
+		b, ok := right.(bool)
+		if !ok {
+			return nil, compileError.NewEvaluationError(unary, fmt.Sprintf("negate operation requires bool, got %s", value.TypeName(right)))
+		}
+		return !b, nil
+
+	case token.SUBTRACT:
+		switch v := right.(type) {
+		case int:
+			if v == math.MinInt {
+				return nil, compileError.NewEvaluationError(unary, fmt.Sprintf("integer overflow: -(%d)", v))
+			}
+			return -v, nil
+		case float64:
+			return -v, nil
+		default:
+			return nil, compileError.NewEvaluationError(unary, fmt.Sprintf("subtraction operation requires number, got %s", value.TypeName(right)))
+		}
+
+	default:
+		return nil, compileError.NewEvaluationError(unary, fmt.Sprintf("unknown unary operator: %v", unary.Operator.Lexeme))
+	}
+}
+
+// VisitGroupingExpr evaluates a grouped expression by evaluating its wrapped expression.
+// Grouping exists only for precedence control during parsing - evaluation just unwraps it.
+func (e *Evaluator) VisitGroupingExpr(grouping *ast.GroupingExpression) (any, error) {
+	return e.Evaluate(grouping.Expression)
+}
+
+// VisitBinaryExpr evaluates binary operators by evaluating both operands then applying the operator.
+//
+// Type Dispatch Priority:
+//  1. == and != → value.Equal, for any pair of types
+//  2. Both operands int → executeInt(); both numeric → executeFloat() (promotes to float64)
+//  3. Both operands string → executeString()
+//  4. Otherwise → Error: "mismatched or unsupported types"
+//
+// Examples:
+//
+//	3 + 2               → 5
+//	"hello" + " world"  → "hello world"
+//	5 + "hello"         → Error: "mismatched or unsupported types: int and string"
+func (e *Evaluator) VisitBinaryExpr(binary *ast.BinaryExpression) (any, error) {
+	left, err := e.Evaluate(binary.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.Evaluate(binary.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	op := binary.Operator.Type
+	switch op {
+	case token.EQUAL:
+		return value.Equal(left, right), nil
+	case token.UNEQUAL:
+		return !value.Equal(left, right), nil
+	case token.IN:
+		return contains(binary, left, right)
+	}
+
+	_, lVersion := left.(value.Version)
+	_, rVersion := right.(value.Version)
+	if lVersion || rVersion {
+		return executeVersion(binary, left, right)
+	}
+
+	lInt, lOk := left.(int)
+	rInt, rOk := right.(int)
+	if lOk && rOk {
+		return executeInt(binary, lInt, rInt)
+	}
+	lNum, lOk := value.ToFloat(left)
+	rNum, rOk := value.ToFloat(right)
+	// if either is float, implicitly cast result to float
+	if lOk && rOk {
+		return executeFloat(binary, lNum, rNum)
+	}
+
+	// only operate on both string operands
+	lStr, lOk := left.(string)
+	rStr, rOk := right.(string)
+	if lOk && rOk {
+		return executeString(binary, lStr, rStr, op)
+	}
+
+	return nil, compileError.NewEvaluationError(binary, fmt.Sprintf("mismatched or unsupported types for '%s': %s and %s",
+		binary.Operator.Lexeme, value.TypeName(left), value.TypeName(right)))
+}
+
+// contains reports whether left is in right, see rule 7 of the package doc.
+func contains(binary *ast.BinaryExpression, left, right any) (any, error) {
+	switch r := right.(type) {
+	case []any:
+		for _, elem := range r {
+			if value.Equal(left, elem) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]any:
+		if key, ok := left.(string); ok {
+			_, found := r[key]
+			return found, nil
+		}
+	case string:
+		if text, ok := left.(string); ok {
+			return strings.Contains(r, text), nil
+		}
+	default:
+		return nil, compileError.NewEvaluationError(binary,
+			fmt.Sprintf("'in' needs an array, map or string on the right, got %s", value.TypeName(right)))
+	}
+	return nil, compileError.NewEvaluationError(binary,
+		fmt.Sprintf("'in' %s needs a string on the left, got %s", value.TypeName(right), value.TypeName(left)))
+}
+
+// executeInt applies an operator to two ints. Arithmetic stays exact: a result that does not fit in an
+// int is an error instead of wrapping around, and only "/" leaves the integers.
+func executeInt(binary *ast.BinaryExpression, l int, r int) (any, error) {
+	overflow := func() (any, error) {
+		return nil, compileError.NewEvaluationError(binary, fmt.Sprintf("integer overflow: %d %s %d", l, binary.Operator.Lexeme, r))
+	}
+	switch binary.Operator.Type {
+	case token.ADD:
+		sum := l + r
+		if (r > 0 && sum < l) || (r < 0 && sum > l) {
+			return overflow()
+		}
+		return sum, nil
+	case token.SUBTRACT:
+		difference := l - r
+		if (r < 0 && difference < l) || (r > 0 && difference > l) {
+			return overflow()
+		}
+		return difference, nil
+	case token.MULTI:
+		product := l * r
+		if l != 0 && (product/l != r || (l == -1 && r == math.MinInt)) {
+			return overflow()
+		}
+		return product, nil
+	case token.DIVIDE:
+		if r == 0 {
+			return nil, compileError.NewEvaluationError(binary, "division by zero")
+		}
+		return float64(l) / float64(r), nil
+	case token.FLOOR_DIV:
+		if r == 0 {
+			return nil, compileError.NewEvaluationError(binary, "division by zero")
+		}
+		if l == math.MinInt && r == -1 {
+			return overflow()
+		}
+		quotient := l / r
+		if l%r != 0 && (l < 0) != (r < 0) {
+			quotient-- // Go truncates towards zero, floor division rounds down
+		}
+		return quotient, nil
+	case token.MODULO:
+		if r == 0 {
+			return nil, compileError.NewEvaluationError(binary, "modulo by zero")
+		}
+		remainder := l % r
+		if remainder != 0 && (remainder < 0) != (r < 0) {
+			remainder += r
+		}
+		return remainder, nil
+	case token.GREATER:
+		return l > r, nil
+	case token.GTE:
+		return l >= r, nil
+	case token.LESS:
+		return l < r, nil
+	case token.LTE:
+		return l <= r, nil
+	}
+	return nil, compileError.NewEvaluationError(binary, fmt.Sprintf("unrecognized numeric operator %s", token.TokenTypeNames[binary.Operator.Type]))
+}
+
+// executeFloat applies an operator to two numbers of which at least one is a float64.
+// "//" and "%" round like their int versions; an infinite result is an overflow error.
+func executeFloat(binary *ast.BinaryExpression, l float64, r float64) (any, error) {
+	var result float64
+	switch binary.Operator.Type {
+	case token.ADD:
+		result = l + r
+	case token.SUBTRACT:
+		result = l - r
+	case token.MULTI:
+		result = l * r
+	case token.DIVIDE, token.FLOOR_DIV:
+		if r == 0.0 {
+			return nil, compileError.NewEvaluationError(binary, "division by zero")
+		}
+		result = l / r
+		if binary.Operator.Type == token.FLOOR_DIV {
+			result = math.Floor(result)
+		}
+	case token.MODULO:
+		if r == 0.0 {
+			return nil, compileError.NewEvaluationError(binary, "modulo by zero")
+		}
+		result = math.Mod(l, r)
+		if result != 0 && (result < 0) != (r < 0) {
+			result += r
+		}
+	default:
+		return compareFloat(binary, l, r)
+	}
+	if math.IsInf(result, 0) {
+		return nil, compileError.NewEvaluationError(binary, fmt.Sprintf("floating-point overflow: %v %s %v", l, binary.Operator.Lexeme, r))
+	}
+	return result, nil
+}
+
+func compareFloat(binary *ast.BinaryExpression, l float64, r float64) (any, error) {
+	switch binary.Operator.Type {
+	case token.GREATER:
+		return l > r, nil
+	case token.GTE:
+		return l >= r, nil
+	case token.LESS:
+		return l < r, nil
+	case token.LTE:
+		return l <= r, nil
+	}
+	return nil, compileError.NewEvaluationError(binary, fmt.Sprintf("unrecognized numeric operator %s", token.TokenTypeNames[binary.Operator.Type]))
+}
+
+func executeString(expr ast.Expression, l string, r string, op token.TokenType) (any, error) {
+	switch op {
+	case token.ADD:
+		return l + r, nil // Concatenation
+	// comparing string base on lexicographic order
+	case token.GREATER:
+		return l > r, nil
+	case token.GTE:
+		return l >= r, nil
+	case token.LESS:
+		return l < r, nil
+	case token.LTE:
+		return l <= r, nil
+	}
+	return nil, compileError.NewEvaluationError(expr, fmt.Sprintf("invalid string operator %s", token.TokenTypeNames[op]))
+}
+
+// executeVersion compares two versions, or a version and a string holding one, by precedence.
+func executeVersion(binary *ast.BinaryExpression, left, right any) (any, error) {
+	l, lOk := value.ToVersion(left)
+	r, rOk := value.ToVersion(right)
+	if !lOk || !rOk {
+		for _, operand := range []any{left, right} {
+			if text, ok := operand.(string); ok {
+				if _, err := value.ParseVersion(text); err != nil {
+					return nil, compileError.NewEvaluationError(binary, fmt.Sprintf("cannot compare semver with string: %v", err))
+				}
+			}
+		}
+		return nil, compileError.NewEvaluationError(binary, fmt.Sprintf("mismatched or unsupported types for '%s': %s and %s",
+			binary.Operator.Lexeme, value.TypeName(left), value.TypeName(right)))
+	}
+
+	c := l.Compare(r)
+	switch binary.Operator.Type {
+	case token.GREATER:
+		return c > 0, nil
+	case token.GTE:
+		return c >= 0, nil
+	case token.LESS:
+		return c < 0, nil
+	case token.LTE:
+		return c <= 0, nil
+	}
+	return nil, compileError.NewEvaluationError(binary, fmt.Sprintf("invalid semver operator %s", token.TokenTypeNames[binary.Operator.Type]))
+}
+
+// VisitLogicalExpr implements short-circuit evaluation for and/or/?? operators.
+// Returns the determining operand's value, not a coerced boolean.
+//
+//	"or"  → returns left if truthy, otherwise evaluates and returns right
+//	"and" → returns left if falsy, otherwise evaluates and returns right
+//	"??"  → returns left unless it is nil or an undefined variable, otherwise evaluates and returns right
+func (e *Evaluator) VisitLogicalExpr(logical *ast.LogicalExpression) (any, error) {
+	if logical.Operator.Type == token.COALESCE {
+		left, err := e.optional(logical.Left)
+		if err != nil || left != nil {
+			return left, err
+		}
+		return e.Evaluate(logical.Right)
+	}
+
+	left, err := e.Evaluate(logical.Left)
+	if err != nil {
+		return nil, err
+	}
+
+	if logical.Operator.Type == token.OR {
+		if value.Truthy(left) {
+			return left, nil
+		}
+	} else {
+		if !value.Truthy(left) {
+			return left, nil
+		}
+	}
+
+	return e.Evaluate(logical.Right)
+}
+
+// optional evaluates expr, except that a variable that is not bound is nil instead of an error.
+func (e *Evaluator) optional(expr ast.Expression) (any, error) {
+	if variable, ok := expr.(*ast.VariableExpression); ok {
+		val, _ := e.Scope.Lookup(variable.Name.Lexeme)
+		return val, nil
+	}
+	return e.Evaluate(expr)
+}
+
+// VisitAssignmentExpr evaluates the value and updates the nearest binding of the name.
+// Returns the assigned value (enables chained assignments: a = b = c).
+func (e *Evaluator) VisitAssignmentExpr(assignment *ast.AssignmentExpression) (any, error) {
+	val, err := e.Evaluate(assignment.Value)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.Scope.Assign(assignment.Name, val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// VisitArrayLiteralExpr evaluates every element in order and returns them as []any.
+func (e *Evaluator) VisitArrayLiteralExpr(array *ast.ArrayLiteralExpression) (any, error) {
+	elements := make([]any, 0, len(array.Elements))
+	for _, elemExpr := range array.Elements {
+		elem, err := e.Evaluate(elemExpr)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, elem)
+	}
+	return elements, nil
+}
+
+// VisitComprehensionExpr builds an array from the items of an array, range or map. The targets
+// live in a child scope, so they never leak into or clobber the enclosing scope.
+func (e *Evaluator) VisitComprehensionExpr(comprehension *ast.ComprehensionExpression) (any, error) {
+	iterVal, err := e.Evaluate(comprehension.Iterable)
+	if err != nil {
+		return nil, err
+	}
+	// the item count is known up front, so an oversized iterable fails before the items are gathered
+	array, isArray := iterVal.([]any)
+	entries, isMap := iterVal.(map[string]any)
+	if !isArray && !isMap {
+		return nil, compileError.NewEvaluationError(comprehension.Iterable,
+			fmt.Sprintf("comprehension iterable must be an array, range or map, got %s", value.TypeName(iterVal)))
+	}
+	if n := len(array) + len(entries); n > e.MaxIterations {
+		return nil, compileError.NewEvaluationError(comprehension,
+			fmt.Sprintf("comprehension exceeded maximum iteration limit (%d)", e.MaxIterations))
+	}
+	for _, target := range comprehension.Targets {
+		if previous, isConstant := e.Scope.Constant(target.Lexeme); isConstant {
+			return nil, compileError.NewRedefinitionError(target, previous,
+				fmt.Sprintf("loop variable cannot shadow constant '%s'", target.Lexeme))
+		}
+	}
+	var keys, items []any
+	if isArray {
+		items = array
+	} else {
+		for _, key := range value.SortedKeys(entries) {
+			keys, items = append(keys, key), append(items, entries[key])
+		}
+	}
+
+	previous := e.Scope
+	e.Scope = scope.New(previous)
+	defer func() { e.Scope = previous }()
+
+	result := []any{}
+	for i := range items {
+		if err := e.Iterate(comprehension); err != nil {
+			return nil, err
+		}
+		var key any = i
+		if isMap {
+			key = keys[i]
+		}
+		if len(comprehension.Targets) == 1 {
+			// a single target is the element of an array and the key of a map
+			if isMap {
+				e.Scope.Define(comprehension.Targets[0].Lexeme, key)
+			} else {
+				e.Scope.Define(comprehension.Targets[0].Lexeme, items[i])
+			}
+		} else {
+			e.Scope.Define(comprehension.Targets[0].Lexeme, key)
+			e.Scope.Define(comprehension.Targets[1].Lexeme, items[i])
+		}
+		if comprehension.Filter != nil {
+			keep, err := e.Evaluate(comprehension.Filter)
+			if err != nil {
+				return nil, err
+			}
+			if !value.Truthy(keep) {
+				continue
+			}
+		}
+		elem, err := e.Evaluate(comprehension.Element)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, elem)
+	}
+	return result, nil
+}
+
+// VisitMapLiteralExpr evaluates every entry in order and returns them as map[string]any.
+// Keys must be strings, and each key may appear only once.
+func (e *Evaluator) VisitMapLiteralExpr(mapLiteral *ast.MapLiteralExpression) (any, error) {
+	entries := make(map[string]any, len(mapLiteral.Keys))
+	for i, keyExpr := range mapLiteral.Keys {
+		key, err := e.Evaluate(keyExpr)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, compileError.NewEvaluationError(keyExpr, fmt.Sprintf("map key must be a string, got %s", value.TypeName(key)))
+		}
+		if _, duplicate := entries[name]; duplicate {
+			return nil, compileError.NewEvaluationError(keyExpr, fmt.Sprintf("duplicate map key %q", name))
+		}
+		val, err := e.Evaluate(mapLiteral.Values[i])
+		if err != nil {
+			return nil, err
+		}
+		entries[name] = val
+	}
+	return entries, nil
+}
+
+// VisitRangeExpr evaluates range(start, stop[, step]) into the []any of ints it produces.
+// All arguments must be whole numbers; the step defaults to 1 and cannot be zero.
+func (e *Evaluator) VisitRangeExpr(rangeExpr *ast.RangeExpression) (any, error) {
+	start, err := e.rangeArgument(rangeExpr.Start, "start")
+	if err != nil {
+		return nil, err
+	}
+	stop, err := e.rangeArgument(rangeExpr.Stop, "stop")
+	if err != nil {
+		return nil, err
+	}
+	step := 1
+	if rangeExpr.Step != nil {
+		step, err = e.rangeArgument(rangeExpr.Step, "step")
+		if err != nil {
+			return nil, err
+		}
+		if step == 0 {
+			return nil, compileError.NewEvaluationError(rangeExpr.Step, "range step cannot be zero")
+		}
+	}
+
+	// the length is known up front, so an oversized range fails before it allocates; one longer than
+	// a loop may run fails too, so a range never builds a huge array even without collection limits
+	n := rangeLength(start, stop, step)
+	if limit := e.Limits.MaxCollectionLength; limit > 0 && n > uint64(limit) {
+		return nil, compileError.NewLimitError(rangeExpr,
+			fmt.Sprintf("range of %d elements exceeds the limit of %d elements", n, limit), nil)
+	}
+	if n > uint64(e.MaxIterations) {
+		return nil, compileError.NewLimitError(rangeExpr,
+			fmt.Sprintf("range of %d elements exceeds the iteration limit of %d", n, e.MaxIterations), nil)
+	}
+	elements := []any{}
+	for k := uint64(0); k < n; k++ {
+		elements = append(elements, start+int(k)*step)
+	}
+	return elements, nil
+}
+
+// rangeLength counts the ints range(start, stop, step) produces, without overflowing near the int limits.
+func rangeLength(start, stop, step int) uint64 {
+	switch {
+	case step > 0 && start < stop:
+		return (uint64(stop)-uint64(start)-1)/uint64(step) + 1
+	case step < 0 && start > stop:
+		return (uint64(start)-uint64(stop)-1)/(-uint64(step)) + 1
+	}
+	return 0
+}
+
+func (e *Evaluator) rangeArgument(expr ast.Expression, name string) (int, error) {
+	val, err := e.Evaluate(expr)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := value.ToInt(val)
+	if !ok {
+		return 0, compileError.NewEvaluationError(expr, fmt.Sprintf("range %s must be a whole number, got %s %s",
+			name, value.TypeName(val), value.Stringify(val)))
+	}
+	return n, nil
+}
+
+// VisitCallExpr evaluates the arguments and calls the named built-in.
+func (e *Evaluator) VisitCallExpr(call *ast.CallExpression) (any, error) {
+	f, ok := builtin.Lookup(call.Callee.Lexeme)
+	if !ok {
+		return nil, compileError.NewEvaluationError(call, fmt.Sprintf("undefined function '%s'", call.Callee.Lexeme))
+	}
+	if f.ByName {
+		name, err := variableName(f, call)
+		if err != nil {
+			return nil, err
+		}
+		_, ok := e.Scope.Lookup(name.Lexeme)
+		return ok, nil
+	}
+	args := make([]any, 0, len(call.Arguments))
+	for _, argExpr := range call.Arguments {
+		arg, err := e.Evaluate(argExpr)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	result, err := builtin.CallWithin(e.Host, f, args, e.Limits.MaxStringLength)
+	if errors.Is(err, builtin.ErrDisabled) || errors.Is(err, builtin.ErrTooLong) {
+		return nil, compileError.NewLimitError(call, err.Error(), err)
+	}
+	if err != nil {
+		return nil, compileError.NewEvaluationError(call, err.Error())
+	}
+	return result, nil
+}
+
+// variableName returns the name passed to a built-in that takes one, such as defined(NAME).
+func variableName(f *builtin.Function, call *ast.CallExpression) (token.Token, error) {
+	if err := f.CheckArity(len(call.Arguments)); err != nil {
+		return token.Token{}, compileError.NewEvaluationError(call, err.Error())
+	}
+	variable, ok := call.Arguments[0].(*ast.VariableExpression)
+	if !ok {
+		return token.Token{}, compileError.NewEvaluationError(call.Arguments[0],
+			fmt.Sprintf("%s() takes a variable name, e.g. %s(CUDA_VERSION)", f.Name, f.Name))
+	}
+	return variable.Name, nil
+}
+
+// VisitMemberExpr reads a component of a version: .major, .minor, .patch or .prerelease.
+func (e *Evaluator) VisitMemberExpr(member *ast.MemberExpression) (any, error) {
+	object, err := e.Evaluate(member.Object)
+	if err != nil {
+		return nil, err
+	}
+	version, ok := object.(value.Version)
+	if !ok {
+		return nil, compileError.NewEvaluationError(member, fmt.Sprintf("%s has no component '%s'", value.TypeName(object), member.Name.Lexeme))
+	}
+	component, ok := version.Field(member.Name.Lexeme)
+	if !ok {
+		return nil, compileError.NewEvaluationError(member, fmt.Sprintf("semver has no component '%s', want major, minor, patch or prerelease", member.Name.Lexeme))
+	}
+	return component, nil
+}
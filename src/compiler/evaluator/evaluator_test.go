@@ -0,0 +1,284 @@
+package evaluator_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"docklett/compiler/ast"
+	compileError "docklett/compiler/error"
+	"docklett/compiler/interpreter"
+	"docklett/compiler/parser"
+	"docklett/compiler/scanner"
+	"docklett/compiler/scope"
+	"docklett/compiler/translator"
+)
+
+func parseSource(t *testing.T, source string) []ast.Statement {
+	t.Helper()
+
+	s := scanner.Scanner{SourceName: "test.dock", Source: source}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan source: %v", err)
+	}
+	var p parser.Parser
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return statements
+}
+
+// evaluateBoth evaluates "@SET result = <expr>" with the Interpreter and the Translator.
+func evaluateBoth(t *testing.T, prelude, expr string) (interpreted, translated any, interpretErr, translateErr error) {
+	t.Helper()
+	statements := parseSource(t, prelude+"@SET result = "+expr+"\n")
+
+	i := interpreter.NewInterpreter()
+	if interpretErr = i.Interpret(statements); interpretErr == nil {
+		interpreted, _ = i.Scope.Lookup("result")
+	}
+
+	tr := translator.NewTranslator()
+	if translateErr = tr.Translate(statements); translateErr == nil {
+		translated, _ = tr.Scope.Lookup("result")
+	}
+	return
+}
+
+func TestEvaluate_InterpreterAndTranslatorAgree(t *testing.T) {
+	prelude := "@SET MODE = \"prod\"\n@SET N = 3\n@SET PKGS = [\"curl\", \"git\"]\n@SET NOTHING\n"
+	tests := []struct {
+		name string
+		expr string
+		want any
+	}{
+		{"int literal", "42", 42},
+		{"float literal", "1.5", 1.5},
+		{"string literal", `"alpine"`, "alpine"},
+		{"bool literal", "TRUE", true},
+		{"variable", "MODE", "prod"},
+		{"negate", "!FALSE", true},
+		{"unary minus", "-N", -3},
+		{"grouping", "(1 + 2) * 3", 9},
+		{"precedence", "1 + 2 * 3", 7},
+		{"division", "7 / 2", 3.5},
+		{"whole division stays float", "6 / 2", 3.0},
+		{"int and float", "N + 0.5", 3.5},
+		{"floor division", "7 // 2", 3},
+		{"floor division rounds down", "-7 // 2", -4},
+		{"float floor division", "7.5 // 2", 3.0},
+		{"modulo", "7 % 3", 1},
+		{"modulo takes the divisor's sign", "-7 % 3", 2},
+		{"float modulo", "7.5 % 2", 1.5},
+		{"modulo binds like multiplication", "1 + 10 % 4 * 2", 5},
+		{"large ints stay exact", "9007199254740993 + 0", 9007199254740993},
+		{"large ints compare exactly", "9007199254740993 == 9007199254740992", false},
+		{"large ints differ", "9007199254740993 != 9007199254740992", true},
+		{"large ints equal", "9007199254740993 == 9007199254740992 + 1", true},
+		{"large int in array", "9007199254740993 in [9007199254740992]", false},
+		{"large ints in arrays", "[9007199254740993] == [9007199254740992]", false},
+		{"int and whole float", "9007199254740992 == 9007199254740992.0", true},
+		{"concatenation", `MODE + "-slim"`, "prod-slim"},
+		{"number equality across types", "5 == 5.0", true},
+		{"string equality", `MODE == "prod"`, true},
+		{"mixed type equality", `"5" == 5`, false},
+		{"nil equality", `"x" != NOTHING`, true},
+		{"array equality", `PKGS == ["curl", "git"]`, true},
+		{"number comparison", "N >= 3", true},
+		{"string comparison", `"a" < "b"`, true},
+		{"string comparison or equal", `"b" <= "b"`, true},
+		{"and short-circuit", `FALSE && MISSING`, false},
+		{"and returns right", `MODE && N`, 3},
+		{"or short-circuit", `TRUE || MISSING`, true},
+		{"and, or spelled out", `FALSE or MODE == "prod" and N > 2`, true},
+		{"in array", `"git" in PKGS`, true},
+		{"in array by equality", "3.0 in [1, N]", true},
+		{"not in array", `!("bash" in PKGS)`, true},
+		{"in map keys", `"mode" in {"mode": 1} && !(MODE in {"mode": 1})`, true},
+		{"in string", `"ro" in MODE`, true},
+		{"in binds like comparison", `"curl" in PKGS == "bash" in PKGS`, false},
+		{"array", "[1, MODE, [N]]", []any{1, "prod", []any{3}}},
+		{"empty array", "[]", []any{}},
+		{"range", "range(0, 3)", []any{0, 1, 2}},
+		{"range with step", "range(0, 10, 4)", []any{0, 4, 8}},
+		{"range negative step", "range(5, 0, -2)", []any{5, 3, 1}},
+		{"range never reaching stop", "range(3, 0)", []any{}},
+		{"range of whole floats", "range(0, N * 1.0)", []any{0, 1, 2}},
+		{"assignment", "N = N + 1", 4},
+		{"call", `upper(MODE) + "-" + pad(N, 2, "0")`, "PROD-03"},
+		{"nested calls", `split(replace("a/b", "/", ","), ",")`, []any{"a", "b"}},
+		{"map", `{"mode": MODE, "n": N}`, map[string]any{"mode": "prod", "n": 3}},
+		{"empty map", "{}", map[string]any{}},
+		{"map equality ignores order", `{"a": 1, "b": 2} == {"b": 2, "a": 1}`, true},
+		{"collections", `join(sort(concat(PKGS, ["bash"])), " ")`, "bash curl git"},
+		{"map built-ins", `keys({"b": 1, "a": N})`, []any{"a", "b"}},
+		{"chunk", "chunk(range(0, 5), 2)", []any{[]any{0, 1}, []any{2, 3}, []any{4}}},
+		{"semver numeric order", `semver("10.0.0") > semver("9.1.0")`, true},
+		{"semver against string", `semver("v18.19.0") >= "18.0.0"`, true},
+		{"semver pre-release order", `semver("1.0.0-beta.11") > "1.0.0-beta.2"`, true},
+		{"semver release after pre-release", `"1.0.0-rc.1" < semver("1.0.0")`, true},
+		{"semver equality ignores build", `semver("1.2.3+ci.4") == "1.2.3"`, true},
+		{"semver component", `semver("1.2.3-rc.1").minor * 10`, 20},
+		{"semver pre-release component", `semver("1.2.3-rc.1").prerelease`, "rc.1"},
+		{"member binds tighter than minus", `-semver("4.0.0").major`, -4},
+		{"semver interpolates as text", `"node:" + format("%s", semver("v20.1.0"))`, "node:20.1.0"},
+		{"satisfies", `satisfies(semver("1.4.2"), "^1.2") && !satisfies("2.0.0", "^1.2")`, true},
+		{"comprehension", `["lib" + p + "-dev" for p in PKGS if p != "curl"]`, []any{"libgit-dev"}},
+		{"comprehension over range", "[i for i in range(0, 10) if i > N * 2]", []any{7, 8, 9}},
+		{"comprehension over map keys", `[k for k in {"b": 1, "a": 2}]`, []any{"a", "b"}},
+		{"comprehension over map entries", `[k + "=" + v for k, v in {"os": MODE}]`, []any{"os=prod"}},
+		{"comprehension with index", `[format("%d:%s", i, p) for i, p in PKGS]`, []any{"0:curl", "1:git"}},
+		{"nested comprehension", "[[x for x in range(0, n)] for n in range(1, 3)]", []any{[]any{0}, []any{0, 1}}},
+		{"comprehension target shadows", "[MODE for MODE in [1]] == [1] && MODE", "prod"},
+		{"empty comprehension", "[p for p in PKGS if FALSE]", []any{}},
+		{"coalesce undefined", `CUDA ?? "12.2"`, "12.2"},
+		{"coalesce nil", "NOTHING ?? N", 3},
+		{"coalesce keeps falsy values", "FALSE ?? TRUE", false},
+		{"coalesce defined", `MODE ?? "dev"`, "prod"},
+		{"coalesce chain", "CUDA ?? ROCM ?? MODE", "prod"},
+		{"coalesce binds loosest", "CUDA ?? N + 1", 4},
+		{"coalesce skips right operand", "N ?? VERSON", 3},
+		{"defined", "defined(MODE) && !defined(CUDA)", true},
+		{"defined nil variable", "defined(NOTHING)", true},
+		{"defined comprehension target", "[defined(p) for p in [1]]", []any{true}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			interpreted, translated, iErr, tErr := evaluateBoth(t, prelude, tc.expr)
+			if iErr != nil || tErr != nil {
+				t.Fatalf("interpreter error: %v, translator error: %v", iErr, tErr)
+			}
+			if !reflect.DeepEqual(interpreted, tc.want) {
+				t.Errorf("interpreter: %s = %#v, want %#v", tc.expr, interpreted, tc.want)
+			}
+			if !reflect.DeepEqual(translated, interpreted) {
+				t.Errorf("translator: %s = %#v, interpreter %#v", tc.expr, translated, interpreted)
+			}
+		})
+	}
+}
+
+func TestEvaluate_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		message string
+		line    int
+	}{
+		{"division by zero", "1 / 0", "division by zero", 1},
+		{"floor division by zero", "1 // 0", "division by zero", 1},
+		{"float division by zero", "1.5 / 0", "division by zero", 1},
+		{"modulo by zero", "1 % 0", "modulo by zero", 1},
+		{"addition overflow", "9223372036854775807 + 1", "integer overflow: 9223372036854775807 + 1", 1},
+		{"subtraction overflow", "-9223372036854775807 - 2", "integer overflow: -9223372036854775807 - 2", 1},
+		{"multiplication overflow", "4294967296 * 4294967296", "integer overflow: 4294967296 * 4294967296", 1},
+		{"negation overflow", "-(-9223372036854775807 - 1)", "integer overflow: -(-9223372036854775808)", 1},
+		{"mismatched types", `1 + "a"`, "mismatched or unsupported types for '+': int and string", 1},
+		{"negate non-bool", `!"a"`, "negate operation requires bool, got string", 1},
+		{"minus string", `-"a"`, "subtraction operation requires number, got string", 1},
+		{"string subtraction", `"a" - "b"`, "invalid string operator SUBTRACT", 1},
+		{"zero step", "range(0, 3, 0)", "range step cannot be zero", 1},
+		{"fractional bound", "range(0, 2.5)", "range stop must be a whole number, got float 2.5", 1},
+		{"non-number bound", `range("a", 3)`, "range start must be a whole number, got string a", 1},
+		{"undefined function", `uper("a")`, "undefined function 'uper'", 1},
+		{"call arity", `upper("a", "b")`, "upper() takes 1 argument, got 2", 1},
+		{"call argument type", `lower(1)`, "lower() argument 1 must be string, got int", 1},
+		{"map key type", `{1: "a"}`, "map key must be a string, got int", 1},
+		{"duplicate map key", `{"a": 1, "a": 2}`, `duplicate map key "a"`, 1},
+		{"semver against invalid string", `semver("1.2.3") > "latest"`,
+			`cannot compare semver with string: invalid semantic version "latest": want MAJOR.MINOR.PATCH`, 1},
+		{"semver arithmetic", `semver("1.2.3") + 1`, "mismatched or unsupported types for '+': semver and int", 1},
+		{"unknown semver component", `semver("1.2.3").build`, "semver has no component 'build', want major, minor, patch or prerelease", 1},
+		{"component of string", `"1.2.3".major`, "string has no component 'major'", 1},
+		{"defined of a value", `defined("MODE")`, "defined() takes a variable name, e.g. defined(CUDA_VERSION)", 1},
+		{"defined arity", "defined()", "defined() takes 1 argument, got 0", 1},
+		{"in number", "1 in 2", "'in' needs an array, map or string on the right, got int", 1},
+		{"int in string", `1 in "123"`, "'in' string needs a string on the left, got int", 1},
+		{"collection error", `sort([1, "a"])`, "sort() needs an array of only numbers, only strings or only versions", 1},
+		{"comprehension over string", `[c for c in "abc"]`, "comprehension iterable must be an array, range or map, got string", 1},
+		{"comprehension limit", "[i for i in concat(range(0, 10000), [0])]", "comprehension exceeded maximum iteration limit (10000)", 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, iErr, tErr := evaluateBoth(t, "", tc.expr)
+			for component, err := range map[string]error{"interpreter": iErr, "translator": tErr} {
+				var evalErr *compileError.EvaluationError
+				if !errors.As(err, &evalErr) {
+					t.Fatalf("%s: error = %v, want *EvaluationError", component, err)
+				}
+				if evalErr.Message != tc.message || evalErr.GetLine() != tc.line {
+					t.Errorf("%s: error = %q at line %d, want %q at line %d",
+						component, evalErr.Message, evalErr.GetLine(), tc.message, tc.line)
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluate_RangeLongerThanLoopLimit(t *testing.T) {
+	// fails before the elements are built, without collection limits
+	_, _, iErr, tErr := evaluateBoth(t, "", "len(range(0, 3000000000))")
+	for component, err := range map[string]error{"interpreter": iErr, "translator": tErr} {
+		var limit *compileError.LimitError
+		if !errors.As(err, &limit) || limit.Message != "range of 3000000000 elements exceeds the iteration limit of 10000" {
+			t.Errorf("%s: error = %v, want a LimitError for the range", component, err)
+		}
+	}
+}
+
+func TestEvaluate_UndefinedVariable(t *testing.T) {
+	// only a name directly left of ?? may be undefined
+	_, _, iErr, tErr := evaluateBoth(t, "", "(1 + VERSON) ?? 0")
+	for component, err := range map[string]error{"interpreter": iErr, "translator": tErr} {
+		var undefined *compileError.UndefinedVariableError
+		if !errors.As(err, &undefined) || undefined.Name.Lexeme != "VERSON" {
+			t.Errorf("%s: error = %v, want undefined variable 'VERSON'", component, err)
+		}
+	}
+}
+
+func TestEvaluate_ComprehensionTargetsDoNotLeak(t *testing.T) {
+	statements := parseSource(t, "@SET doubled = [x * 2 for x in [1, 2]]\n@SET x = 5\n@SET n = [k for k in [1]]\n")
+	i := interpreter.NewInterpreter()
+	if err := i.Interpret(statements); err != nil {
+		t.Fatalf("interpret: %v", err)
+	}
+	if _, found := i.Scope.Lookup("k"); found {
+		t.Error("comprehension target k is visible after the comprehension")
+	}
+	if x, _ := i.Scope.Lookup("x"); x != 5 {
+		t.Errorf("x = %v, want 5", x)
+	}
+
+	_, _, iErr, tErr := evaluateBoth(t, "@CONST C = 1\n", "[C for C in [2]]")
+	for component, err := range map[string]error{"interpreter": iErr, "translator": tErr} {
+		var redefinition *compileError.RedefinitionError
+		if !errors.As(err, &redefinition) {
+			t.Errorf("%s: error = %v, want *RedefinitionError", component, err)
+		}
+	}
+}
+
+func TestEvaluate_ScopeIsReplaceable(t *testing.T) {
+	outer := scope.New(nil)
+	outer.Define("x", 1)
+	inner := scope.New(outer)
+	inner.Define("x", 2)
+
+	statements := parseSource(t, "x\n")
+	expr := statements[0].(*ast.ExpressionStatement).Expression
+
+	i := interpreter.NewInterpreter()
+	for _, tc := range []struct {
+		scope *scope.Scope
+		want  any
+	}{{outer, 1}, {inner, 2}} {
+		i.Scope = tc.scope
+		if got, err := i.Evaluate(expr); err != nil || got != tc.want {
+			t.Errorf("Evaluate(x) = %v, %v, want %v", got, err, tc.want)
+		}
+	}
+}
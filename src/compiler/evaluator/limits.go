@@ -0,0 +1,113 @@
+/*
+Resource limits for compiling files from an untrusted source, e.g. in a shared CI service.
+
+A Docklett file runs at compile time, so without limits one file can keep a compiler busy or exhaust
+its memory: nested loops multiply, "s = s + s" doubles a string on every pass, range(0, N) allocates
+N ints. Limits bound each of these for the whole compilation, and Context bounds the wall-clock time:
+
+	MaxTotalIterations    @FOR and comprehension items, every pass of a nested loop counted
+	MaxDepth              nesting of blocks and sub-expressions being evaluated
+	MaxInstructions       Docker instructions emitted (enforced by the Translator)
+	MaxStringLength       bytes in any string value or interpolated instruction
+	MaxCollectionLength   elements in any array or map value
+	NoFiles, NoEnv        the filesystem built-ins and env() are errors, see builtin.Capability
+
+A zero field means no limit, so the zero Limits is the unrestricted default of a local build. The
+per-loop MaxIterations of an Evaluator applies either way.
+
+Every exceeded budget is a *compileError.LimitError at the node that went over it. When Context
+ends, the error wraps ctx.Err(), so errors.Is(err, context.DeadlineExceeded) holds.
+*/
+package evaluator
+
+import (
+	"context"
+	"docklett/compiler/ast"
+	compileError "docklett/compiler/error"
+	"errors"
+	"fmt"
+)
+
+// Limits bound the resources one compilation may use; zero fields are unlimited.
+type Limits struct {
+	MaxTotalIterations  int
+	MaxDepth            int
+	MaxInstructions     int
+	MaxStringLength     int
+	MaxCollectionLength int
+	NoFiles             bool
+	NoEnv               bool
+}
+
+// SandboxLimits are limits for files from an untrusted source: generous for real Dockerfiles, with
+// no access to the build context or the environment.
+var SandboxLimits = Limits{
+	MaxTotalIterations:  100000,
+	MaxDepth:            200,
+	MaxInstructions:     5000,
+	MaxStringLength:     1 << 20,
+	MaxCollectionLength: 100000,
+	NoFiles:             true,
+	NoEnv:               true,
+}
+
+// Iterate counts one item of a @FOR loop or comprehension against MaxTotalIterations and checks
+// that Context has not ended.
+func (e *Evaluator) Iterate(node ast.Node) error {
+	e.iterations++
+	if limit := e.Limits.MaxTotalIterations; limit > 0 && e.iterations > limit {
+		return compileError.NewLimitError(node,
+			fmt.Sprintf("compilation exceeded the limit of %d loop iterations in total", limit), nil)
+	}
+	return e.CheckContext(node)
+}
+
+// CheckContext reports a Context that was canceled or went past its deadline.
+func (e *Evaluator) CheckContext(node ast.Node) error {
+	if e.Context == nil {
+		return nil
+	}
+	err := e.Context.Err()
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return compileError.NewLimitError(node, "compilation exceeded its deadline", err)
+	}
+	return compileError.NewLimitError(node, "compilation was canceled", err)
+}
+
+// Enter counts one more level of nesting at node against MaxDepth; every successful Enter must be
+// followed by a Leave.
+func (e *Evaluator) Enter(node ast.Node) error {
+	if limit := e.Limits.MaxDepth; limit > 0 && e.depth >= limit {
+		return compileError.NewLimitError(node, fmt.Sprintf("compilation exceeded the nesting limit of %d", limit), nil)
+	}
+	e.depth++
+	return nil
+}
+
+// Leave undoes the last Enter.
+func (e *Evaluator) Leave() {
+	e.depth--
+}
+
+// CheckSize reports a string or collection larger than the limits allow.
+func (e *Evaluator) CheckSize(node ast.Node, val any) error {
+	switch v := val.(type) {
+	case string:
+		return e.checkLength(node, len(v), e.Limits.MaxStringLength, "string of %d bytes exceeds the limit of %d bytes")
+	case []any:
+		return e.checkLength(node, len(v), e.Limits.MaxCollectionLength, "array of %d elements exceeds the limit of %d elements")
+	case map[string]any:
+		return e.checkLength(node, len(v), e.Limits.MaxCollectionLength, "map of %d entries exceeds the limit of %d entries")
+	}
+	return nil
+}
+
+func (e *Evaluator) checkLength(node ast.Node, length, limit int, format string) error {
+	if limit > 0 && length > limit {
+		return compileError.NewLimitError(node, fmt.Sprintf(format, length, limit), nil)
+	}
+	return nil
+}
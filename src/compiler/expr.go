@@ -0,0 +1,150 @@
+package compiler
+
+import (
+	"context"
+	"docklett/compiler/ast"
+	"docklett/compiler/evaluator"
+	"docklett/compiler/parser"
+	"docklett/compiler/scope"
+	"docklett/compiler/token"
+	"docklett/compiler/value"
+	"fmt"
+	"math"
+)
+
+// CompiledExpr is a Docklett expression scanned and parsed once, to be evaluated against many sets
+// of variables. It is never modified after CompileExpr, so Eval may run on any number of goroutines
+// at once.
+//
+//	cond, err := compiler.CompileExpr(`MODE == "prod" and ARCH in ["amd64", "arm64"]`)
+//	...
+//	ok, err := cond.Eval(map[string]any{"MODE": "prod", "ARCH": "amd64"}) // true
+type CompiledExpr struct {
+	source string
+	expr   ast.Expression
+}
+
+// CompileExpr parses source as a single expression, with the operators and built-ins of a
+// Docklett file. Syntax errors are compileError.ScanError or ParseError values with the line and
+// column in source.
+func CompileExpr(source string) (*CompiledExpr, error) {
+	expr, err := parser.ParseExpression(source, token.Position{Line: 1, Col: 1})
+	if err != nil {
+		return nil, err
+	}
+	return &CompiledExpr{source: source, expr: expr}, nil
+}
+
+// Eval parses and evaluates source in one step, see CompiledExpr.Eval.
+func Eval(source string, vars map[string]any) (any, error) {
+	compiled, err := CompileExpr(source)
+	if err != nil {
+		return nil, err
+	}
+	return compiled.Eval(vars)
+}
+
+// String returns the source the expression was compiled from.
+func (c *CompiledExpr) String() string {
+	return c.source
+}
+
+// Eval evaluates the expression with vars as its only variables and returns a bool, int, float64,
+// string, []any, map[string]any, value.Version or nil.
+//
+// Variables may also hold other Go integer and float types, []string and map[string]string; they
+// are converted to Docklett values first. Evaluation errors, such as an undefined variable, are
+// compileError values whose GetLocation points into the expression. Built-ins that read files or
+// the environment are not available.
+//
+// Eval runs with the zero evaluator.Limits: only the per-loop iteration limit applies, so strings and
+// arrays may grow to the built-in maximum. Use EvalContext to evaluate expressions from an untrusted
+// source.
+func (c *CompiledExpr) Eval(vars map[string]any) (any, error) {
+	return c.EvalContext(context.Background(), vars, evaluator.Limits{})
+}
+
+// EvalContext evaluates the expression like Eval within limits, stopping with a LimitError once ctx
+// is canceled or its deadline passes, e.g. with evaluator.SandboxLimits for conditions users write.
+func (c *CompiledExpr) EvalContext(ctx context.Context, vars map[string]any, limits evaluator.Limits) (any, error) {
+	global := scope.New(nil)
+	for name, val := range vars {
+		converted, err := fromGo(val)
+		if err != nil {
+			return nil, fmt.Errorf("variable %s: %w", name, err)
+		}
+		global.Define(name, converted)
+	}
+	// a new Evaluator per call: it keeps its scope and loop counters while it runs
+	e := evaluator.New(global)
+	e.Limits, e.Context = limits, ctx
+	return e.Evaluate(c.expr)
+}
+
+// fromGo converts a Go value to the Docklett value with the same meaning.
+func fromGo(val any) (any, error) {
+	switch v := val.(type) {
+	case nil, bool, int, float64, string, value.Version:
+		return v, nil
+	case int8:
+		return int(v), nil
+	case int16:
+		return int(v), nil
+	case int32:
+		return int(v), nil
+	case int64:
+		return int(v), nil
+	case uint8:
+		return int(v), nil
+	case uint16:
+		return int(v), nil
+	case uint32:
+		return int(v), nil
+	case uint:
+		return fromUint(uint64(v))
+	case uint64:
+		return fromUint(v)
+	case float32:
+		return float64(v), nil
+	case []string:
+		elements := make([]any, len(v))
+		for i, elem := range v {
+			elements[i] = elem
+		}
+		return elements, nil
+	case map[string]string:
+		entries := make(map[string]any, len(v))
+		for key, elem := range v {
+			entries[key] = elem
+		}
+		return entries, nil
+	case []any:
+		elements := make([]any, len(v))
+		for i, elem := range v {
+			converted, err := fromGo(elem)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = converted
+		}
+		return elements, nil
+	case map[string]any:
+		entries := make(map[string]any, len(v))
+		for key, elem := range v {
+			converted, err := fromGo(elem)
+			if err != nil {
+				return nil, err
+			}
+			entries[key] = converted
+		}
+		return entries, nil
+	}
+	return nil, fmt.Errorf("%T is not a Docklett value", val)
+}
+
+func fromUint(v uint64) (any, error) {
+	if v > math.MaxInt {
+		return nil, fmt.Errorf("%d does not fit in an int", v)
+	}
+	return int(v), nil
+}
@@ -0,0 +1,134 @@
+package compiler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	compileError "docklett/compiler/error"
+	"docklett/compiler/evaluator"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]any{
+		"MODE":     "prod",
+		"ARCH":     "amd64",
+		"REPLICAS": int64(3),
+		"TAGS":     []string{"latest", "stable"},
+		"LABELS":   map[string]string{"team": "build"},
+		"PORTS":    []any{uint16(80), 443},
+	}
+	tests := []struct {
+		source string
+		want   any
+	}{
+		{`MODE == "prod" and ARCH in ["amd64"]`, true},
+		{`MODE == "dev" or ARCH in ["arm64", "riscv64"]`, false},
+		{`MODE == "dev" || "stable" in TAGS`, true},
+		{`MODE != "dev" && !("beta" in TAGS)`, true},
+		{`"team" in LABELS and "owner" in LABELS`, false},
+		{`"md" in ARCH`, true},
+		{`REPLICAS * 2 + 1`, 7},
+		{`80 in PORTS and 8080 in PORTS`, false},
+		{`upper(MODE) + "-" + join(TAGS, ",")`, "PROD-latest,stable"},
+		{`[t for t in TAGS if t != "latest"]`, []any{"stable"}},
+		{`CUDA ?? "12.2"`, "12.2"},
+		{`semver("20.11.0") >= "18.0.0"`, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.source, func(t *testing.T) {
+			got, err := Eval(tc.source, vars)
+			if err != nil || !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Eval(%s) = %#v, %v, want %#v", tc.source, got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestEval_Errors(t *testing.T) {
+	tests := []struct {
+		source   string
+		vars     map[string]any
+		location string
+		message  string
+	}{
+		{`MODE == `, nil, "at end", ""},
+		{`MODE == )`, nil, "at ')'", ""},
+		{`MODE | "x"`, nil, "line 1, column 6", ""},
+		{`MODE == "prod" and ARCH in ["amd64"]`, map[string]any{"MODE": "prod"}, "line 1, column 20", ""},
+		{`"x" + (1 in 2)`, nil, "line 1, column 8", ""},
+		{`read_file("VERSION")`, nil, "", "read_file() is not available here: it needs access to the build context"},
+		{`env("HOME")`, nil, "", "env() is not available here: it needs access to environment variables"},
+		{`MODE`, map[string]any{"MODE": struct{}{}}, "", "variable MODE: struct {} is not a Docklett value"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.source, func(t *testing.T) {
+			_, err := Eval(tc.source, tc.vars)
+			if err == nil {
+				t.Fatalf("Eval(%s): no error", tc.source)
+			}
+			if tc.location == "" {
+				if !strings.Contains(err.Error(), tc.message) {
+					t.Errorf("Eval(%s): error = %v, want %q", tc.source, err, tc.message)
+				}
+				return
+			}
+			var located compileError.CompileError
+			if !errors.As(err, &located) || located.GetLocation() != tc.location {
+				t.Errorf("Eval(%s): error = %v, want one at %s", tc.source, err, tc.location)
+			}
+		})
+	}
+}
+
+func TestCompiledExpr_EvalContext(t *testing.T) {
+	list, err := CompileExpr(`[x for x in ITEMS]`)
+	if err != nil {
+		t.Fatalf("CompileExpr: %v", err)
+	}
+	vars := map[string]any{"ITEMS": []any{1, 2, 3}}
+	if _, err := list.Eval(vars); err != nil {
+		t.Fatalf("Eval without limits: %v", err)
+	}
+
+	var limit *compileError.LimitError
+	_, err = list.EvalContext(context.Background(), vars, evaluator.Limits{MaxTotalIterations: 2})
+	if !errors.As(err, &limit) {
+		t.Errorf("over the iteration budget: error = %v, want a LimitError", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = list.EvalContext(ctx, vars, evaluator.Limits{})
+	if !errors.As(err, &limit) || !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled: error = %v, want a LimitError wrapping context.Canceled", err)
+	}
+}
+
+func TestCompiledExpr_ConcurrentEval(t *testing.T) {
+	cond, err := CompileExpr(`[a for a in ARCHES if a in SUPPORTED] == ARCHES and MODE == "prod"`)
+	if err != nil {
+		t.Fatalf("CompileExpr: %v", err)
+	}
+	supported := []any{"amd64", "arm64"}
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			arches := []any{"amd64"}
+			want := i%2 == 0
+			if !want {
+				arches = append(arches, "s390x")
+			}
+			got, err := cond.Eval(map[string]any{"ARCHES": arches, "SUPPORTED": supported, "MODE": "prod"})
+			if err != nil || got != want {
+				t.Errorf("Eval %d = %v, %v, want %v", i, got, err, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,160 @@
+package format
+
+import (
+	"docklett/compiler/ast"
+	"docklett/compiler/token"
+	"docklett/compiler/value"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Expression prints an expression as canonical Docklett source.
+// Parentheses are only printed where the source had a GroupingExpression, so precedence is preserved as parsed.
+//
+//	a+b*  2        → a + b * 2
+//	[ "a","b" ]    → ["a", "b"]
+//	{"a":1}        → {"a": 1}
+//	range(0,10,2)  → range(0, 10, 2)
+//	upper( NAME )  → upper(NAME)
+func Expression(expr ast.Expression) string {
+	switch e := expr.(type) {
+	case nil:
+		return ""
+	case *ast.LiteralExpression:
+		return literal(e)
+	case *ast.VariableExpression:
+		return e.Name.Lexeme
+	case *ast.UnaryExpression:
+		return e.Operator.Lexeme + Expression(e.Right)
+	case *ast.GroupingExpression:
+		return "(" + Expression(e.Expression) + ")"
+	case *ast.BinaryExpression:
+		return Expression(e.Left) + " " + e.Operator.Lexeme + " " + Expression(e.Right)
+	case *ast.LogicalExpression:
+		return Expression(e.Left) + " " + e.Operator.Lexeme + " " + Expression(e.Right)
+	case *ast.AssignmentExpression:
+		return e.Name.Lexeme + " = " + Expression(e.Value)
+	case *ast.ArrayLiteralExpression:
+		return "[" + expressionList(e.Elements) + "]"
+	case *ast.MapLiteralExpression:
+		entries := make([]string, len(e.Keys))
+		for i := range e.Keys {
+			entries[i] = Expression(e.Keys[i]) + ": " + Expression(e.Values[i])
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	case *ast.ComprehensionExpression:
+		targets := make([]string, len(e.Targets))
+		for i, target := range e.Targets {
+			targets[i] = target.Lexeme
+		}
+		text := "[" + Expression(e.Element) + " for " + strings.Join(targets, ", ") + " in " + Expression(e.Iterable)
+		if e.Filter != nil {
+			text += " if " + Expression(e.Filter)
+		}
+		return text + "]"
+	case *ast.RangeExpression:
+		args := []ast.Expression{e.Start, e.Stop}
+		if e.Step != nil {
+			args = append(args, e.Step)
+		}
+		return "range(" + expressionList(args) + ")"
+	case *ast.CallExpression:
+		return e.Callee.Lexeme + "(" + expressionList(e.Arguments) + ")"
+	case *ast.MemberExpression:
+		return Expression(e.Object) + "." + e.Name.Lexeme
+	default:
+		return fmt.Sprintf("<%T>", expr)
+	}
+}
+
+func expressionList(expressions []ast.Expression) string {
+	parts := make([]string, len(expressions))
+	for i, expr := range expressions {
+		parts[i] = Expression(expr)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// literal prefers the source lexeme so numbers keep their spelling (1.50 stays 1.50).
+// Literals built without a token, e.g. by constant folding, are printed from their value.
+func literal(e *ast.LiteralExpression) string {
+	switch e.Token.Type {
+	case token.TRUE:
+		return "TRUE"
+	case token.FALSE:
+		return "FALSE"
+	case token.STRING, token.NUMBER:
+		if e.Token.Lexeme != "" {
+			return e.Token.Lexeme
+		}
+	}
+	return Value(e.Value)
+}
+
+// IsLiteral reports whether Value prints val as source that scans back to the same value. Docklett
+// strings have no escapes, so a string with a double quote has no literal; neither have nil, NaN and
+// the infinities.
+func IsLiteral(val any) bool {
+	switch v := val.(type) {
+	case bool, int, value.Version:
+		return true
+	case string:
+		return !strings.Contains(v, `"`)
+	case float64:
+		return !math.IsInf(v, 0) && !math.IsNaN(v)
+	case []any:
+		for _, elem := range v {
+			if !IsLiteral(elem) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		for key, elem := range v {
+			if !IsLiteral(key) || !IsLiteral(elem) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Value prints a compile-time value as a Docklett literal, see IsLiteral for the values that have one.
+func Value(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case string:
+		return `"` + v + `"`
+	case float64:
+		text := strconv.FormatFloat(v, 'f', -1, 64)
+		if !math.IsInf(v, 0) && !math.IsNaN(v) && !strings.Contains(text, ".") {
+			text += ".0" // 3.0, not the int 3
+		}
+		return text
+	case []any:
+		parts := make([]string, len(v))
+		for i, elem := range v {
+			parts[i] = Value(elem)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case map[string]any:
+		parts := make([]string, 0, len(v))
+		for _, key := range value.SortedKeys(v) {
+			parts = append(parts, Value(key)+": "+Value(v[key]))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case value.Version:
+		return `semver("` + v.String() + `")`
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
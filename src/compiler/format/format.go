@@ -0,0 +1,270 @@
+/*
+Package format implements the canonical formatting of Docklett source, used by `docklett fmt`.
+
+The formatter works on the lossless CST (package cst), so comments and intentional blank lines
+survive while everything else is normalised:
+
+	directives       upper case: @if → @IF, in → IN, true → TRUE
+	Docker keywords  upper case: run → RUN
+	blocks           every @IF/@ELIF/@ELSE/@FOR body is indented by one level (4 spaces)
+	expressions      single spaces around binary operators, "[a, b]", "range(0, 10)", "(x)"
+	continuations    RUN a \  → continued lines are re-indented one level deeper than the instruction
+	blank lines      runs of blank lines collapse into one, none at the start or end of a block
+	comments         kept on their own line at the indentation of what follows, or at the end of a line
+
+Docker argument text is never rewritten beyond the indentation of its continuation lines,
+so the generated instruction is the same before and after formatting.
+
+Formatting is idempotent: Source(Source(x)) == Source(x).
+*/
+package format
+
+import (
+	"docklett/compiler/ast"
+	"docklett/compiler/cst"
+	"docklett/compiler/token"
+	"fmt"
+	"strings"
+)
+
+// indentUnit is one level of block or continuation indentation.
+const indentUnit = "    "
+
+type printer struct {
+	file         *cst.File // nil when printing a bare AST: no comments to keep
+	out          strings.Builder
+	depth        int  // current block nesting
+	atBlockStart bool // no line written yet in the current block
+	blankPending bool // a blank line was seen in the source before the next line
+}
+
+// Source formats a whole Docklett file and returns the canonical text.
+func Source(name string, src []byte) ([]byte, error) {
+	file, err := cst.Parse(name, string(src))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &printer{file: file, atBlockStart: true}
+	p.statements(file.Statements)
+	// comments after the last statement
+	p.leadingTrivia(file.EOF().LeadingTrivia)
+	return []byte(p.out.String()), nil
+}
+
+// Node prints a single statement or expression as canonical Docklett source, without comments.
+// Statements end with a newline; expressions don't.
+func Node(node ast.Node) string {
+	p := &printer{atBlockStart: true}
+	switch n := node.(type) {
+	case ast.Statement:
+		p.statement(n)
+	case ast.Expression:
+		return Expression(n)
+	}
+	return p.out.String()
+}
+
+// Statements prints a statement list, such as the Parser output, as canonical Docklett source.
+func Statements(statements []ast.Statement) string {
+	p := &printer{atBlockStart: true}
+	p.statements(statements)
+	return p.out.String()
+}
+
+func (p *printer) statements(statements []ast.Statement) {
+	for _, stmt := range statements {
+		p.statement(stmt)
+	}
+}
+
+// line writes one output line at the current depth, preceded by a blank line when the source had one.
+func (p *printer) line(text string) {
+	if p.blankPending && !p.atBlockStart {
+		p.out.WriteString("\n")
+	}
+	p.blankPending = false
+	p.atBlockStart = false
+	p.out.WriteString(strings.Repeat(indentUnit, p.depth))
+	p.out.WriteString(text)
+	p.out.WriteString("\n")
+}
+
+// leadingTrivia writes the comment lines found before a token and remembers blank lines.
+func (p *printer) leadingTrivia(trivia []token.Trivia) {
+	afterComment := false
+	for _, t := range trivia {
+		switch t.Kind {
+		case token.COMMENT:
+			p.line(t.Text)
+			afterComment = true
+		case token.LINE_BREAK:
+			// the line break ending a comment line is not a blank line
+			if !afterComment {
+				p.blankPending = true
+			}
+			afterComment = false
+		case token.CONTINUATION:
+			afterComment = false
+		}
+	}
+}
+
+// leadingAt writes the trivia in front of the token starting at pos.
+func (p *printer) leadingAt(pos token.Position) {
+	if p.file == nil {
+		return
+	}
+	if tok, ok := p.file.TokenAt(pos); ok {
+		p.leadingTrivia(tok.LeadingTrivia)
+	}
+}
+
+// trailingAt returns the end-of-line comment after the token ending at pos, with a separating space.
+func (p *printer) trailingAt(pos token.Position) string {
+	if p.file == nil {
+		return ""
+	}
+	tok, ok := p.file.TokenEndingAt(pos)
+	if !ok {
+		return ""
+	}
+	return trailingComment(tok)
+}
+
+func trailingComment(tok token.Token) string {
+	comments := cst.Comments(tok.TrailingTrivia)
+	if len(comments) == 0 {
+		return ""
+	}
+	return " " + strings.Join(comments, " ")
+}
+
+// closer writes a directive that ends a block (@ELIF, @ELSE, @END) along with the comments above it.
+// Comments before a closer still belong to the block body, so they keep its indentation.
+func (p *printer) closer(tok token.Token, text string) {
+	if p.file != nil {
+		p.depth++
+		p.leadingTrivia(tok.LeadingTrivia)
+		p.depth--
+	}
+	p.blankPending = false
+	p.line(text)
+}
+
+func (p *printer) block(statements []ast.Statement) {
+	p.depth++
+	p.atBlockStart = true
+	p.statements(statements)
+	p.depth--
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch n := stmt.(type) {
+	case *ast.DockerStatement:
+		p.leadingAt(n.Pos())
+		keyword := strings.ToUpper(n.Keyword.Lexeme)
+		if n.Args == "" {
+			p.line(keyword)
+			return
+		}
+		continuationIndent := strings.Repeat(indentUnit, p.depth+1)
+		p.line(keyword + " " + dockerArgs(n.Args, continuationIndent))
+
+	case *ast.VariableDeclarationStatement:
+		p.leadingAt(n.Pos())
+		text := "@SET " + n.Name.Lexeme
+		if n.IsConstant() {
+			text = "@CONST " + n.Name.Lexeme
+		} else if n.IsDefault() {
+			text = "@DEFAULT " + n.Name.Lexeme
+		}
+		if n.Type != nil {
+			text += ": " + n.Type.String()
+		}
+		if n.Initializer != nil {
+			text += " = " + Expression(n.Initializer)
+		}
+		p.line(text + p.trailingAt(n.End()))
+
+	case *ast.ExpressionStatement:
+		p.leadingAt(n.Pos())
+		p.line(Expression(n.Expression) + p.trailingAt(n.End()))
+
+	case *ast.IfStatement:
+		p.ifChain(n, "@IF")
+		p.closer(n.Close, "@END"+trailingComment(n.Close))
+
+	case *ast.ForStatement:
+		p.leadingAt(n.Pos())
+		p.line("@FOR " + n.Target.Lexeme + " IN " + Expression(n.Iterable) + p.trailingAt(n.Iterable.End()))
+		p.block(n.Body.Statements)
+		p.closer(n.Close, "@END"+trailingComment(n.Close))
+
+	case *ast.BlockStatement:
+		p.block(n.Statements)
+
+	default:
+		p.line(fmt.Sprintf("# unsupported statement %T", stmt))
+	}
+}
+
+// ifChain writes an @IF or @ELIF link and everything chained after it, except the shared @END.
+func (p *printer) ifChain(n *ast.IfStatement, directive string) {
+	header := directive + " " + Expression(n.Condition) + p.trailingAt(n.Condition.End())
+	if directive == "@IF" {
+		p.leadingAt(n.Pos())
+		p.line(header)
+	} else {
+		p.closer(n.Open, header)
+	}
+	p.block(n.ThenBranch.Statements)
+
+	switch elseBranch := n.ElseBranch.(type) {
+	case *ast.IfStatement:
+		p.ifChain(elseBranch, "@ELIF")
+	case *ast.BlockStatement:
+		p.closer(elseBranch.Open, "@ELSE"+trailingComment(elseBranch.Open))
+		p.block(elseBranch.Statements)
+	}
+}
+
+// dockerArgs re-indents the continuation lines of Docker arguments and leaves the text itself alone.
+//
+//	RUN apt-get update \          RUN apt-get update \
+//	  && apt-get install    →         && apt-get install
+//
+// Docker joins continued lines by dropping only the backslash and line break, so a join without
+// any whitespace around it (abc\ + def → abcdef) is kept exactly as written.
+func dockerArgs(args string, continuationIndent string) string {
+	lines := strings.Split(args, "\n")
+	if len(lines) == 1 {
+		return strings.TrimRight(args, " \t\r")
+	}
+
+	var sb strings.Builder
+	for i, line := range lines {
+		if i < len(lines)-1 {
+			// every line but the last ends with the continuation backslash
+			line = strings.TrimSuffix(strings.TrimRight(line, " \t\r"), "\\")
+		}
+		if i > 0 {
+			if strings.TrimLeft(line, " \t") != line || strings.HasSuffix(sb.String(), " \\\n") {
+				sb.WriteString(continuationIndent)
+			}
+			line = strings.TrimLeft(line, " \t")
+		}
+		if i < len(lines)-1 {
+			spaced := strings.TrimRight(line, " \t") != line || strings.HasPrefix(lines[i+1], " ") || strings.HasPrefix(lines[i+1], "\t")
+			line = strings.TrimRight(line, " \t")
+			if spaced && line != "" {
+				line += " "
+			}
+			line += "\\\n"
+		} else {
+			line = strings.TrimRight(line, " \t\r")
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
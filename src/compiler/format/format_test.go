@@ -0,0 +1,134 @@
+package format
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"docklett/compiler/ast"
+	"docklett/compiler/cst"
+)
+
+var update = flag.Bool("update", false, "update .golden files")
+
+// TestSource_Golden formats every testdata/*.input and compares it with the matching .golden file.
+func TestSource_Golden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.input"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no testdata inputs found")
+	}
+
+	for _, input := range inputs {
+		golden := strings.TrimSuffix(input, ".input") + ".golden"
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Source(filepath.Base(input), src)
+			if err != nil {
+				t.Fatalf("format: %v", err)
+			}
+
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("formatted output differs from %s:\n got:\n%s\n want:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+// TestSource_Idempotent checks that formatting formatted output changes nothing, for inputs and goldens alike.
+func TestSource_Idempotent(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			once, err := Source(file, src)
+			if err != nil {
+				t.Fatalf("first format: %v", err)
+			}
+			twice, err := Source(file, once)
+			if err != nil {
+				t.Fatalf("second format: %v", err)
+			}
+			if string(once) != string(twice) {
+				t.Errorf("formatting is not idempotent:\n once:\n%s\n twice:\n%s", once, twice)
+			}
+		})
+	}
+}
+
+var (
+	continuation = regexp.MustCompile(`\\[ \t\r]*\n`)
+	spaces       = regexp.MustCompile(`[ \t]+`)
+)
+
+// dockerInstructions lists every Docker instruction with continuations joined, as the builder would see it.
+func dockerInstructions(t *testing.T, name string, src []byte) []string {
+	t.Helper()
+
+	file, err := cst.Parse(name, string(src))
+	if err != nil {
+		t.Fatalf("parse %s: %v", name, err)
+	}
+	var instructions []string
+	for _, stmt := range file.Statements {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if ds, ok := n.(*ast.DockerStatement); ok {
+				// Docker drops only the backslash and line break; whitespace runs are insignificant
+				args := spaces.ReplaceAllString(continuation.ReplaceAllString(ds.Args, ""), " ")
+				instructions = append(instructions, strings.ToUpper(ds.Keyword.Lexeme)+" "+args)
+			}
+			return true
+		})
+	}
+	return instructions
+}
+
+// TestSource_PreservesDockerArgs checks that formatting never changes what a Docker instruction means.
+func TestSource_PreservesDockerArgs(t *testing.T) {
+	inputs, _ := filepath.Glob(filepath.Join("testdata", "*.input"))
+	for _, input := range inputs {
+		src, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		formatted, err := Source(input, src)
+		if err != nil {
+			t.Fatalf("format %s: %v", input, err)
+		}
+		before := dockerInstructions(t, input, src)
+		after := dockerInstructions(t, input, formatted)
+		if strings.Join(before, "\n") != strings.Join(after, "\n") {
+			t.Errorf("%s: docker instructions changed:\n before %q\n after  %q", input, before, after)
+		}
+	}
+}
+
+func TestSource_SyntaxError(t *testing.T) {
+	if _, err := Source("bad.dock", []byte("@IF x ==\n@END\n")); err == nil {
+		t.Error("expected a parse error")
+	}
+}
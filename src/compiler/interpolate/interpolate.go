@@ -0,0 +1,326 @@
+/*
+Package interpolate expands ${...} references in Docker instruction arguments at compile time.
+
+Arguments are scanned once, left to right, so the result never depends on map iteration order,
+and every reference is looked up through the whole scope chain by the caller's lookup function.
+
+SYNTAX (a subset of Docker's own, evaluated by Docklett when the name is a Docklett variable):
+
+	${name}            value of name
+	${name:-word}      word if name is nil or empty, otherwise its value
+	${name:+word}      word if name is set and not empty, otherwise the empty string
+	${name:?message}   compile error with message if name is nil or empty, otherwise its value
+	$${name}           escape: emits ${name} untouched, for Docker to expand at build time
+	\${name}           Docker's own escape, copied through untouched
+	${call(...)}       a Docklett expression starting with a built-in call: ${upper(NAME)}, ${pad(N, 3, "0")}
+	${name.component}  a Docklett expression starting with a component read: ${NODE.major}
+	${name ?? expr}    a Docklett expression with a fallback for a nil or undefined name: ${CUDA ?? "12.2"}
+
+word may itself contain references: ${TAG:-${VERSION}}. Values are printed with value.Stringify,
+so 3.0 becomes "3" and ["a", "b"] becomes "a b".
+
+UNRESOLVED REFERENCES:
+A reference to a name the lookup does not know is copied through, modifier and all, so Docker
+can resolve its own ARG and ENV variables. With Options.Strict it is an error instead, unless
+Options.External reports the name as one Docker defines.
+
+EXPRESSIONS:
+The text of an expression reference, up to the first "}" outside a string literal, is handed to
+Options.Evaluate. Expressions never fall through to Docker: Docker has no such syntax.
+
+Anything that does not parse as a reference, such as "$HOME", "${1}" or an unclosed "${", is plain text.
+*/
+package interpolate
+
+import (
+	"docklett/compiler/token"
+	"docklett/compiler/value"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Modifier operators, written between the name and the word.
+const (
+	Default   = ":-"
+	Alternate = ":+"
+	Required  = ":?"
+)
+
+// Reference is one ${...} expansion in a string.
+type Reference struct {
+	Name       string // variable name, empty for an expression reference
+	Modifier   string // "", Default, Alternate or Required
+	Word       string // raw text after the modifier
+	Expression string // source of an expression reference, e.g. upper(NAME)
+	Start      int    // byte offset of "${"
+	End        int    // byte offset one past the closing "}"
+}
+
+// Options control how references that cannot be expanded are treated.
+type Options struct {
+	// Strict makes an unresolved reference an error.
+	Strict bool
+	// External reports names that Docker defines itself (ARG, ENV); they are never errors.
+	External func(name string) bool
+	// Residual keeps "$${" escapes as they are, so the result is still Docklett source.
+	Residual bool
+	// Evaluate computes an expression reference. When it is nil or reports false, the reference is
+	// copied through unchanged.
+	Evaluate func(ref Reference) (any, bool, error)
+}
+
+// ReferenceError reports a reference that cannot be expanded: an unresolved name in strict mode,
+// or a ${name:?message} whose name is not set. Ref carries the offsets of the reference.
+type ReferenceError struct {
+	Ref     Reference
+	Message string
+}
+
+func (e *ReferenceError) Error() string {
+	return e.Message
+}
+
+// Lookup returns the compile-time value of a variable, if it has one.
+type Lookup func(name string) (any, bool)
+
+// References returns every reference in s in source order, including those nested in modifier words.
+// Escaped references are skipped.
+func References(s string) []Reference {
+	var refs []Reference
+	scan(s, func(text string) {}, func(ref Reference) {
+		refs = append(refs, ref)
+		for _, nested := range References(ref.Word) {
+			nested.Start += ref.End - len(ref.Word) - 1
+			nested.End += ref.End - len(ref.Word) - 1
+			refs = append(refs, nested)
+		}
+	})
+	return refs
+}
+
+// Expand replaces every reference in s whose name lookup knows.
+func Expand(s string, lookup Lookup, opts Options) (string, error) {
+	return expandText(s, 0, lookup, opts)
+}
+
+// expandText expands s, which starts at byte offset base of the string given to Expand,
+// so the references passed to opts.Evaluate carry offsets into the original string.
+func expandText(s string, base int, lookup Lookup, opts Options) (string, error) {
+	var out strings.Builder
+	var err error
+	scan(s, func(text string) {
+		if text == "$${" && !opts.Residual {
+			text = "${"
+		}
+		out.WriteString(text)
+	}, func(ref Reference) {
+		if err != nil {
+			return
+		}
+		raw := s[ref.Start:ref.End]
+		ref.Start += base
+		ref.End += base
+		var expanded string
+		expanded, err = expand(raw, ref, lookup, opts)
+		out.WriteString(expanded)
+	})
+	return out.String(), err
+}
+
+func expand(raw string, ref Reference, lookup Lookup, opts Options) (string, error) {
+	if ref.Expression != "" {
+		if opts.Evaluate == nil {
+			return raw, nil
+		}
+		val, ok, err := opts.Evaluate(ref)
+		if err != nil || !ok {
+			return raw, err
+		}
+		return value.Stringify(val), nil
+	}
+
+	val, ok := lookup(ref.Name)
+	if !ok {
+		if opts.Strict && (opts.External == nil || !opts.External(ref.Name)) {
+			return raw, &ReferenceError{Ref: ref, Message: fmt.Sprintf(
+				"unresolved reference ${%s}: '%s' is not a Docklett variable or a declared ARG/ENV", ref.Name, ref.Name)}
+		}
+		return raw, nil
+	}
+
+	// the word sits just before the closing "}"
+	word := func() (string, error) {
+		return expandText(ref.Word, ref.End-1-len(ref.Word), lookup, opts)
+	}
+	text := value.Stringify(val)
+	set := text != ""
+	switch ref.Modifier {
+	case Default:
+		if !set {
+			return word()
+		}
+	case Alternate:
+		if !set {
+			return "", nil
+		}
+		return word()
+	case Required:
+		if !set {
+			message, err := word()
+			if err != nil {
+				return raw, err
+			}
+			if message == "" {
+				message = "parameter null or not set"
+			}
+			return raw, &ReferenceError{Ref: ref, Message: fmt.Sprintf("%s: %s", ref.Name, message)}
+		}
+	}
+	return text, nil
+}
+
+// Position returns the source position of byte offset in s, when s itself starts at start.
+// Docker arguments may span continued lines, so line breaks before offset are counted.
+func Position(s string, start token.Position, offset int) token.Position {
+	before := s[:offset]
+	pos := start
+	if line := strings.LastIndexByte(before, '\n'); line >= 0 {
+		pos.Line += strings.Count(before, "\n")
+		pos.Col = utf8.RuneCountInString(before[line+1:]) + 1
+	} else {
+		pos.Col += utf8.RuneCountInString(before)
+	}
+	return pos
+}
+
+// scan splits s into plain text and references. Escapes ("$${" and "\$") are passed to text whole.
+func scan(s string, text func(string), reference func(Reference)) {
+	plainStart := 0
+	flush := func(end int) {
+		if end > plainStart {
+			text(s[plainStart:end])
+		}
+	}
+
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], `\$`):
+			i += 2
+		case strings.HasPrefix(s[i:], "$${"):
+			flush(i)
+			text("$${")
+			i += 3
+			plainStart = i
+		case strings.HasPrefix(s[i:], "${"):
+			ref, ok := parseReference(s, i)
+			if !ok {
+				i += 2
+				continue
+			}
+			flush(i)
+			reference(ref)
+			i = ref.End
+			plainStart = i
+		default:
+			i++
+		}
+	}
+	flush(len(s))
+}
+
+// parseReference parses the reference starting with "${" at s[start:].
+func parseReference(s string, start int) (Reference, bool) {
+	ref := Reference{Start: start}
+	i := start + 2
+	nameStart := i
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !isNameChar(r, i == nameStart) {
+			break
+		}
+		i += size
+	}
+	if i == nameStart {
+		return ref, false
+	}
+	ref.Name = s[nameStart:i]
+
+	if i < len(s) && s[i] == '(' || isComponent(s, i) || isCoalesce(s, i) {
+		return parseExpression(s, ref, nameStart)
+	}
+	if i < len(s) && s[i] == '}' {
+		ref.End = i + 1
+		return ref, true
+	}
+	for _, modifier := range []string{Default, Alternate, Required} {
+		if strings.HasPrefix(s[i:], modifier) {
+			ref.Modifier = modifier
+		}
+	}
+	if ref.Modifier == "" {
+		return ref, false
+	}
+	i += len(ref.Modifier)
+
+	// the word ends at the matching "}", skipping nested references
+	wordStart, depth := i, 0
+	for ; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case s[i] == '}' && depth > 0:
+			depth--
+		case s[i] == '}':
+			ref.Word = s[wordStart:i]
+			ref.End = i + 1
+			return ref, true
+		}
+	}
+	return ref, false
+}
+
+// parseExpression finishes an expression reference whose text starts at s[start:]. It ends at the
+// first "}" that is not inside a double-quoted string.
+func parseExpression(s string, ref Reference, start int) (Reference, bool) {
+	quoted := false
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == '}' && !quoted:
+			ref.Name = ""
+			ref.Expression = strings.TrimSpace(s[start:i])
+			ref.End = i + 1
+			return ref, true
+		}
+	}
+	return ref, false
+}
+
+// isComponent reports whether s[i:] is "." followed by a name, as in ${NODE.major}.
+func isComponent(s string, i int) bool {
+	if i >= len(s) || s[i] != '.' {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s[i+1:])
+	return isNameChar(r, true)
+}
+
+// isCoalesce reports whether s[i:] is "??", optionally after spaces, as in ${CUDA ?? "12.2"}.
+func isCoalesce(s string, i int) bool {
+	return strings.HasPrefix(strings.TrimLeft(s[i:], " "), "??")
+}
+
+// isNameChar accepts Docker and Docklett variable names: letters, digits and underscores, not starting with a digit.
+func isNameChar(r rune, first bool) bool {
+	switch {
+	case unicode.IsLetter(r), r == '_':
+		return true
+	case unicode.IsDigit(r):
+		return !first
+	}
+	return false
+}
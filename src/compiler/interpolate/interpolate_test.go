@@ -0,0 +1,145 @@
+package interpolate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func lookupIn(vars map[string]any) Lookup {
+	return func(name string) (any, bool) {
+		val, ok := vars[name]
+		return val, ok
+	}
+}
+
+func TestExpand(t *testing.T) {
+	vars := map[string]any{
+		"name":    "api",
+		"port":    8080,
+		"ratio":   5.0,
+		"pkgs":    []any{"curl", "git"},
+		"empty":   "",
+		"missing": nil,
+		"ver":     "1.2",
+		"名前":      "x",
+	}
+	tests := []struct {
+		args string
+		want string
+	}{
+		{"echo ${name}:${port}", "echo api:8080"},
+		{"echo ${ratio}", "echo 5"},
+		{"apk add ${pkgs}", "apk add curl git"},
+		{"${name}${name}", "apiapi"},
+		{"${名前}", "x"},
+		{"${empty:-fallback} ${name:-fallback}", "fallback api"},
+		{"${missing:-none}", "none"},
+		{"${name:+set} [${empty:+set}]", "set []"},
+		{"${empty:-${ver}-rc}", "1.2-rc"},
+		{"$${name} stays", "${name} stays"},
+		{`\${name} stays`, `\${name} stays`},
+		{"${HOME} ${HOME:-/root}", "${HOME} ${HOME:-/root}"},
+		{"$name ${1} ${ ${name", "$name ${1} ${ ${name"},
+		{"${name:=x}", "${name:=x}"},
+	}
+
+	for _, test := range tests {
+		got, err := Expand(test.args, lookupIn(vars), Options{})
+		if err != nil {
+			t.Errorf("Expand(%q): %v", test.args, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Expand(%q) = %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestExpand_Required(t *testing.T) {
+	vars := map[string]any{"tag": "", "ver": "1"}
+	if got, err := Expand("${ver:?no version}", lookupIn(vars), Options{}); err != nil || got != "1" {
+		t.Errorf("set variable: got %q, %v", got, err)
+	}
+
+	tests := map[string]string{
+		"${tag:?tag must be set for ${ver}}": "tag: tag must be set for 1",
+		"${tag:?}":                           "tag: parameter null or not set",
+	}
+	for args, want := range tests {
+		if _, err := Expand(args, lookupIn(vars), Options{}); err == nil || err.Error() != want {
+			t.Errorf("Expand(%q) error = %v, want %q", args, err, want)
+		}
+	}
+}
+
+func TestExpand_Strict(t *testing.T) {
+	opts := Options{Strict: true, External: func(name string) bool { return name == "VERSION" }}
+	if _, err := Expand("${VERSION} $${LATER}", lookupIn(nil), opts); err != nil {
+		t.Errorf("external and escaped references reported: %v", err)
+	}
+	_, err := Expand("echo ${VERSON}", lookupIn(nil), opts)
+	if err == nil || !strings.Contains(err.Error(), "${VERSON}") {
+		t.Errorf("error = %v, want unresolved ${VERSON}", err)
+	}
+
+	// the offsets of a nested reference are those in the whole string
+	_, err = Expand("echo ${A:-${B}}", lookupIn(map[string]any{"A": ""}), opts)
+	var refErr *ReferenceError
+	if !errors.As(err, &refErr) || refErr.Ref.Name != "B" || refErr.Ref.Start != 10 {
+		t.Errorf("error = %#v, want a ReferenceError for ${B} at offset 10", err)
+	}
+}
+
+func TestExpand_Residual(t *testing.T) {
+	got, err := Expand("${known} ${unknown:-x} $${escaped}", lookupIn(map[string]any{"known": 1}), Options{Residual: true})
+	if err != nil || got != "1 ${unknown:-x} $${escaped}" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	args := "a ${x} $${y} ${z:-${w}} ${v:+} ${upper(u) }"
+	var got []string
+	for _, ref := range References(args) {
+		got = append(got, fmt.Sprintf("%s%s%s@%s", ref.Name, ref.Modifier, ref.Expression, args[ref.Start:ref.End]))
+	}
+	want := []string{"x@${x}", "z:-@${z:-${w}}", "w@${w}", "v:+@${v:+}", "upper(u)@${upper(u) }"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("references = %q, want %q", got, want)
+	}
+}
+
+func TestExpand_Expressions(t *testing.T) {
+	var seen []Reference
+	evaluate := func(ref Reference) (any, bool, error) {
+		seen = append(seen, ref)
+		if ref.Expression == "unknown(x)" {
+			return nil, false, nil
+		}
+		return "<" + ref.Expression + ">", true, nil
+	}
+	args := `tag ${upper(name)} ${pad(n, 3, "}")} ${unknown(x)} ${v.major}.x ${cuda ?? "12.2"} ${x:-${lower(y)}}`
+	got, err := Expand(args, lookupIn(map[string]any{"x": ""}), Options{Evaluate: evaluate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `tag <upper(name)> <pad(n, 3, "}")> ${unknown(x)} <v.major>.x <cuda ?? "12.2"> <lower(y)>`
+	if got != want {
+		t.Errorf("Expand = %q, want %q", got, want)
+	}
+	// offsets of nested references point into the original string
+	if last := seen[len(seen)-1]; args[last.Start:last.End] != "${lower(y)}" {
+		t.Errorf("nested reference spans %q", args[last.Start:last.End])
+	}
+
+	// without an evaluator, expressions are copied through
+	if got, _ := Expand("${upper(name)}", lookupIn(nil), Options{}); got != "${upper(name)}" {
+		t.Errorf("Expand without Evaluate = %q", got)
+	}
+	// a dot that does not start a component name is not an expression
+	if got, _ := Expand("${v.}", lookupIn(map[string]any{"v": 1}), Options{Evaluate: evaluate}); got != "${v.}" {
+		t.Errorf("Expand(${v.}) = %q", got)
+	}
+}
@@ -9,21 +9,36 @@ EVALUATION vs EXECUTION:
 EXAMPLE EXECUTION FLOW:
   Source: @SET x = 5\n  Parse: VariableStatement(Name="x", Initializer=Literal(5))
   Execute: VisitVarDeclarationStatement → Evaluate initializer → Define in environment
-  Result: Environment.Map["x"] = 5
+  Result: Scope binds x = 5
 
+Expressions are evaluated by the embedded evaluator.Evaluator, the same implementation the
+Translator uses, so both components agree on every operator.
 */
 
 package interpreter
 
 import (
 	"docklett/compiler/ast"
+	"docklett/compiler/evaluator"
+	"docklett/compiler/scope"
+	"docklett/compiler/value"
 )
 
-// Compile-time check to ensure Interpreter implements StatementVisitor
+// Compile-time check to ensure Interpreter implements StatementVisitor and ExpressionVisitor
 var _ ast.StatementVisitor = (*Interpreter)(nil)
+var _ ast.ExpressionVisitor = (*Interpreter)(nil)
 
-// Main entry point to start interpreting
-func (i *Interpreter) interpret(statements []ast.Statement) error {
+// Interpreter executes statements; Scope (from the embedded Evaluator) is the innermost scope.
+type Interpreter struct {
+	*evaluator.Evaluator
+}
+
+func NewInterpreter() *Interpreter {
+	return &Interpreter{Evaluator: evaluator.New(scope.New(nil))}
+}
+
+// Interpret executes statements in order, stopping at the first error.
+func (i *Interpreter) Interpret(statements []ast.Statement) error {
 	for _, stmt := range statements {
 		// statements produce an effect rather than value, so for now we ignore the return value and perform the instruction in place
 		_, err := i.execute(stmt)
@@ -40,11 +55,6 @@ func (i *Interpreter) execute(statement ast.Statement) (any, error) {
 	return statement.Accept(i)
 }
 
-// placeholder for the base Statement interface.
-func (i *Interpreter) VisitStatement(statement *ast.Statement) (any, error) {
-	return nil, nil
-}
-
 // VisitExpressionStatement evaluates an expression for its side effects (like assignment).
 // The expression's return value is discarded - we only care about state changes.
 //
@@ -61,13 +71,13 @@ func (i *Interpreter) VisitStatement(statement *ast.Statement) (any, error) {
 //
 //	Source: x = 10
 //	AST: ExpressionStatement(AssignmentExpression("x", Literal(10)))
-//	Execute: Evaluate assignment → Environment.Assign("x", 10) → Discard return value (10)
+//	Execute: Evaluate assignment → Scope.Assign("x", 10) → Discard return value (10)
 //
 //	Source: 5 + 3  (useless but valid)
 //	AST: ExpressionStatement(BinaryExpression(5, +, 3))
 //	Execute: Evaluate → 8 → Discard result
 func (i *Interpreter) VisitExpressionStatement(expressionStatement *ast.ExpressionStatement) (any, error) {
-	return i.evaluate(expressionStatement.Expression)
+	return i.Evaluate(expressionStatement.Expression)
 }
 
 // VisitVarDeclarationStatement creates a new variable binding in the environment.
@@ -77,44 +87,31 @@ func (i *Interpreter) VisitExpressionStatement(expressionStatement *ast.Expressi
 //
 //   - Defaults to nil if no initializer provided
 //
-//   - Creates binding in environment via Define()
+//   - Checks the value against the type annotation (@SET port: int = ...), if any
+//
+//   - Creates binding in environment via Declare()
 //
-//   - Define() never fails (overwrites existing bindings)
+//   - Declare() overwrites existing bindings, except constants and, in strict mode, same-scope variables
 //
 //     @SET x = 5    → VisitVarDeclarationStatement (creates NEW binding)
 //
 //     x = 10        → VisitAssignmentExpr (updates EXISTING binding, fails if undefined)
 func (i *Interpreter) VisitVarDeclarationStatement(varStatement *ast.VariableDeclarationStatement) (any, error) {
-	var value any = nil
-
-	if varStatement.Initializer != nil {
-		val, err := i.evaluate(varStatement.Initializer)
-		if err != nil {
-			return nil, err
-		}
-		value = val
-	}
-
-	i.Environment.Define(varStatement.Name.Lexeme, value)
-	return nil, nil
+	return nil, i.Declare(varStatement)
 }
 
 // VisitBlockStatement creates a new child scope and executes every statements within that block.
 // After execution, the scope is discarded and the previous scope is restored.
 func (i *Interpreter) VisitBlockStatement(blStatement *ast.BlockStatement) (any, error) {
-	blockEnvironment := Environment{
-		Map:       make(map[string]any),
-		Enclosing: &i.Environment,
-	}
-	return i.executeBlock(blStatement.Statements, blockEnvironment)
+	return i.executeBlock(blStatement.Statements, scope.New(i.Scope))
 }
 
-func (i *Interpreter) executeBlock(statements []ast.Statement, environment Environment) (any, error) {
+func (i *Interpreter) executeBlock(statements []ast.Statement, environment *scope.Scope) (any, error) {
 	// restore environment after executing block
-	previous := i.Environment
-	defer func() { i.Environment = previous }()
+	previous := i.Scope
+	defer func() { i.Scope = previous }()
 
-	i.Environment = environment
+	i.Scope = environment
 
 	for _, statement := range statements {
 		_, err := i.execute(statement)
@@ -126,17 +123,19 @@ func (i *Interpreter) executeBlock(statements []ast.Statement, environment Envir
 }
 
 func (i *Interpreter) VisitIfStatement(iStmt *ast.IfStatement) (any, error) {
-	condition, err := i.evaluate(iStmt.Condition)
+	condition, err := i.Evaluate(iStmt.Condition)
 	if err != nil {
 		return nil, err
 	}
-	if i.isTruthy(condition) {
+	if value.Truthy(condition) {
 		_, err := i.execute(iStmt.ThenBranch)
 		return nil, err
-	} else {
+	}
+	if iStmt.ElseBranch != nil {
 		_, err := i.execute(iStmt.ElseBranch)
 		return nil, err
 	}
+	return nil, nil
 }
 
 // VisitDockerStatement is a no-op stub; Docker instructions are handled by the Translator
@@ -148,13 +147,3 @@ func (i *Interpreter) VisitDockerStatement(ds *ast.DockerStatement) (any, error)
 func (i *Interpreter) VisitForStatement(fs *ast.ForStatement) (any, error) {
 	return nil, nil
 }
-
-// VisitArrayLiteralExpr is a no-op stub
-func (i *Interpreter) VisitArrayLiteralExpr(array *ast.ArrayLiteralExpression) (any, error) {
-	return nil, nil
-}
-
-// VisitRangeExpr is a no-op stub
-func (i *Interpreter) VisitRangeExpr(rangeExpr *ast.RangeExpression) (any, error) {
-	return nil, nil
-}
@@ -4,24 +4,28 @@ Expression are parsed using recursive descent parsing.
 RECURSIVE DESCENT PARSING:
 Each grammar rule becomes a method. Methods call "higher" precedence rules (lower in the call chain).
 Precedence from lowest to highest (call order):
-  expression → assignment → logic_or → logic_and → equality → comparison → term → factor → unary → primary
+  expression → assignment → coalesce → logic_or → logic_and → equality → comparison → term → factor → unary → member → primary
 
 GRAMMAR RULES (from Crafting Interpreters):
   expression     → assignment
-  assignment     → IDENTIFIER "=" assignment | logic_or
-  logic_or       → logic_and ( "or" logic_and )*
-  logic_and      → equality ( "and" equality )*
+  assignment     → IDENTIFIER "=" assignment | coalesce
+  coalesce       → logic_or ( "??" coalesce )?
+  logic_or       → logic_and ( ("||" | "or") logic_and )*
+  logic_and      → equality ( ("&&" | "and") equality )*
   equality       → comparison ( ("==" | "!=") comparison )*
-  comparison     → term ( (">" | ">=" | "<" | "<=") term )*
+  comparison     → term ( (">" | ">=" | "<" | "<=" | "in") term )*
   term           → factor ( ("+" | "-") factor )*
-  factor         → unary ( ("*" | "/") unary )*
-  unary          → ("!" | "-") unary | primary
-  primary        → NUMBER | STRING | "true" | "false" | IDENTIFIER | "(" expression ")"
+  factor         → unary ( ("*" | "/" | "//" | "%") unary )*
+  unary          → ("!" | "-") unary | member
+  member         → primary ( "." IDENTIFIER )*
+  primary        → NUMBER | STRING | "true" | "false" | IDENTIFIER | call | "(" expression ")"
+  call           → IDENTIFIER "(" ( expression ( "," expression )* )? ")"
+  map            → "{" ( expression ":" expression ( "," expression ":" expression )* )? "}"
 
 PARSING STRATEGY:
 Each function parses its level and delegates to higher-precedence rules.
 Left-associative operators use iteration: a + b + c → (a + b) + c
-Right-associative operators use recursion: a = b = c → a = (b = c)
+Right-associative operators use recursion: a = b = c → a = (b = c), A ?? B ?? "x" → A ?? (B ?? "x")
 
 EXAMPLE PARSE TREE:
   Source: (1 + 2) * 3
@@ -59,32 +63,42 @@ func (p *Parser) primary() (ast.Expression, error) {
 		return p.arrayLiteral()
 	}
 
+	// map literal: {key: value, ...}
+	if p.matchCurrentToken(token.LBRACE) {
+		return p.mapLiteral()
+	}
+
 	// range(start, end) or range(start, end, step)
 	if p.matchCurrentToken(token.RANGE) {
 		return p.rangeExpression()
 	}
 
-	// identifier: variable reference
+	// identifier: a built-in call when followed by "(", otherwise a variable reference
 	if p.matchCurrentToken(token.IDENTIFIER) {
-		return &ast.VariableExpression{Name: p.getPreviousToken()}, nil
+		name := p.getPreviousToken()
+		if p.matchCurrentToken(token.LPAREN) {
+			return p.call(name)
+		}
+		return &ast.VariableExpression{Name: name}, nil
 	}
 
 	// If token is an opening parenthesis, the next tokens must form a new expression followed by a closing parenthesis token
 	if p.matchCurrentToken(token.LPAREN) {
+		lparen := p.getPreviousToken()
 		expression, err := p.expression()
 		if err != nil {
 			return nil, err
 		}
-		_, err = p.consumeMatchingToken(token.RPAREN, "Expected ')' after expression.")
+		rparen, err := p.consumeMatchingToken(token.RPAREN, "Expected ')' after expression.")
 		if err != nil {
 			return nil, err
 		}
-		return &ast.GroupingExpression{Expression: expression}, nil
+		return &ast.GroupingExpression{Expression: expression, LParen: lparen, RParen: rparen}, nil
 	}
 	return nil, compileError.NewParseError(p.getCurrentToken(), "Unexpected token "+p.getCurrentToken().Lexeme)
 }
 
-// arrayLiteral parses: [ expression ("," expression)* ]
+// arrayLiteral parses: [ expression ("," expression)* ] or a comprehension, see comprehension().
 // The opening LBRACKET is already consumed by primary().
 func (p *Parser) arrayLiteral() (ast.Expression, error) {
 	bracket := p.getPreviousToken()
@@ -96,6 +110,9 @@ func (p *Parser) arrayLiteral() (ast.Expression, error) {
 		if err != nil {
 			return nil, err
 		}
+		if len(elements) == 0 && p.matchCurrentToken(token.FOR) {
+			return p.comprehension(bracket, elem)
+		}
 		elements = append(elements, elem)
 
 		// trailing comma is optional before "]"
@@ -104,11 +121,105 @@ func (p *Parser) arrayLiteral() (ast.Expression, error) {
 		}
 	}
 
-	_, err := p.consumeMatchingToken(token.RBRACKET, "Expected ']' after array elements.")
+	rbracket, err := p.consumeMatchingToken(token.RBRACKET, "Expected ']' after array elements.")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.ArrayLiteralExpression{Bracket: bracket, Elements: elements, RBracket: rbracket}, nil
+}
+
+// comprehension parses the rest of: [ expression "for" IDENTIFIER ("," IDENTIFIER)? "in" expression ("if" expression)? ]
+// The opening bracket, the element expression and "for" are already consumed.
+func (p *Parser) comprehension(bracket token.Token, element ast.Expression) (ast.Expression, error) {
+	target, err := p.consumeMatchingToken(token.IDENTIFIER, "Expected loop variable after 'for'.")
+	if err != nil {
+		return nil, err
+	}
+	targets := []token.Token{target}
+	if p.matchCurrentToken(token.COMMA) {
+		second, err := p.consumeMatchingToken(token.IDENTIFIER, "Expected second loop variable after ','.")
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, second)
+	}
+	if _, err := p.consumeMatchingToken(token.IN, "Expected 'in' after loop variable."); err != nil {
+		return nil, err
+	}
+	iterable, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	var filter ast.Expression
+	if p.matchCurrentToken(token.IF) {
+		if filter, err = p.expression(); err != nil {
+			return nil, err
+		}
+	}
+	rbracket, err := p.consumeMatchingToken(token.RBRACKET, "Expected ']' after comprehension.")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.ComprehensionExpression{Bracket: bracket, Element: element, Targets: targets,
+		Iterable: iterable, Filter: filter, RBracket: rbracket}, nil
+}
+
+// mapLiteral parses: { expression ":" expression ("," expression ":" expression)* }
+// The opening LBRACE is already consumed by primary().
+func (p *Parser) mapLiteral() (ast.Expression, error) {
+	brace := p.getPreviousToken()
+	var keys, values []ast.Expression
+
+	for !p.checkCurrentToken(token.RBRACE) {
+		key, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.consumeMatchingToken(token.COLON, "Expected ':' after map key."); err != nil {
+			return nil, err
+		}
+		val, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		values = append(values, val)
+
+		// trailing comma is optional before "}"
+		if !p.matchCurrentToken(token.COMMA) {
+			break
+		}
+	}
+
+	rbrace, err := p.consumeMatchingToken(token.RBRACE, "Expected '}' after map entries.")
 	if err != nil {
 		return nil, err
 	}
-	return &ast.ArrayLiteralExpression{Bracket: bracket, Elements: elements}, nil
+	return &ast.MapLiteralExpression{Brace: brace, Keys: keys, Values: values, RBrace: rbrace}, nil
+}
+
+// call parses: name ( expression ("," expression)* )
+// The callee IDENTIFIER and the LPAREN are already consumed by primary().
+func (p *Parser) call(callee token.Token) (ast.Expression, error) {
+	var arguments []ast.Expression
+
+	for !p.checkCurrentToken(token.RPAREN) {
+		arg, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, arg)
+		if !p.matchCurrentToken(token.COMMA) {
+			break
+		}
+	}
+
+	rparen, err := p.consumeMatchingToken(token.RPAREN, "Expected ')' after arguments.")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.CallExpression{Callee: callee, Arguments: arguments, RParen: rparen}, nil
 }
 
 // rangeExpression parses: range(start, end) or range(start, end, step)
@@ -144,11 +255,11 @@ func (p *Parser) rangeExpression() (ast.Expression, error) {
 		}
 	}
 
-	_, err = p.consumeMatchingToken(token.RPAREN, "Expected ')' after range arguments.")
+	rparen, err := p.consumeMatchingToken(token.RPAREN, "Expected ')' after range arguments.")
 	if err != nil {
 		return nil, err
 	}
-	return &ast.RangeExpression{Token: rangeTok, Start: start, End: end, Step: step}, nil
+	return &ast.RangeExpression{Token: rangeTok, Start: start, Stop: end, Step: step, RParen: rparen}, nil
 }
 
 // An unary just takes the immediate value returned from primary and mutate that
@@ -162,18 +273,36 @@ func (p *Parser) unary() (ast.Expression, error) {
 		}
 		return &ast.UnaryExpression{Operator: prev, Right: right}, nil
 	}
-	return p.primary()
+	return p.member()
+}
+
+// member parses component reads after a primary: semver(V).major
+// It binds tighter than the unary operators, so -v.major negates the component.
+func (p *Parser) member() (ast.Expression, error) {
+	expr, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for p.matchCurrentToken(token.DOT) {
+		dot := p.getPreviousToken()
+		name, err := p.consumeMatchingToken(token.IDENTIFIER, "Expected component name after '.'.")
+		if err != nil {
+			return nil, err
+		}
+		expr = &ast.MemberExpression{Object: expr, Dot: dot, Name: name}
+	}
+	return expr, nil
 }
 
 // A factor rule is defined as an unary (now a single unit of actual value) followed by
-// an arbitray number of this structure: (MUL|DIV) unary
+// an arbitray number of this structure: (MUL|DIV|FLOOR_DIV|MOD) unary
 // We implement a "running epxression" approach by continuously consumming next tokens and add that to our current expression
 func (p *Parser) factor() (ast.Expression, error) {
 	expr, err := p.unary()
 	if err != nil {
 		return nil, err
 	}
-	for p.matchCurrentToken(token.MULTI, token.DIVIDE) {
+	for p.matchCurrentToken(token.MULTI, token.DIVIDE, token.FLOOR_DIV, token.MODULO) {
 		operator := p.getPreviousToken()
 		right, err := p.unary()
 		if err != nil {
@@ -206,7 +335,7 @@ func (p *Parser) comparison() (ast.Expression, error) {
 	if err != nil {
 		return nil, err
 	}
-	for p.matchCurrentToken(token.GTE, token.GREATER, token.LTE, token.LESS) {
+	for p.matchCurrentToken(token.GTE, token.GREATER, token.LTE, token.LESS, token.IN) {
 		operator := p.getPreviousToken()
 		right, err := p.term()
 		if err != nil {
@@ -265,11 +394,29 @@ func (p *Parser) logicOr() (ast.Expression, error) {
 	return expr, nil
 }
 
+// coalesce is right-associative, so in A ?? B ?? "x" every name but the last is a left operand and
+// may be undefined.
+func (p *Parser) coalesce() (ast.Expression, error) {
+	expr, err := p.logicOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.matchCurrentToken(token.COALESCE) {
+		operator := p.getPreviousToken()
+		right, err := p.coalesce()
+		if err != nil {
+			return nil, err
+		}
+		expr = &ast.LogicalExpression{Left: expr, Operator: operator, Right: right}
+	}
+	return expr, nil
+}
+
 // In an assignment, the left side is just an identifier that needs to be binded to a value, so we don't consider it an epxression.
 // But parser can't know if an identifier, let's say "x" in "x + ...", is an assignment target or expression until it sees "=".
 // So we parse as expression first, then convert to assignment target if "=" found.
 func (p *Parser) assignment() (ast.Expression, error) {
-	expr, err := p.logicOr()
+	expr, err := p.coalesce()
 	if err != nil {
 		return nil, err
 	}
@@ -289,7 +436,7 @@ func (p *Parser) assignment() (ast.Expression, error) {
 		// For now, only Variable expressions are valid targets.
 		if variable, ok := expr.(*ast.VariableExpression); ok {
 			name := variable.Name
-			return &ast.AssignmentExpression{Name: name, Value: value}, nil
+			return &ast.AssignmentExpression{Name: name, Equals: equals, Value: value}, nil
 		}
 
 		return nil, compileError.NewParseError(equals, "Unable to perform assignment on expression "+equals.Lexeme)
@@ -21,6 +21,8 @@ package parser
 import (
 	"docklett/compiler/ast"
 	compileError "docklett/compiler/error"
+	"docklett/compiler/interpolate"
+	"docklett/compiler/scanner"
 	"docklett/compiler/token"
 	"errors"
 )
@@ -31,6 +33,7 @@ import (
 type Parser struct {
 	Tokens  []token.Token
 	current int
+	depth   int // number of enclosing @IF/@FOR blocks
 }
 
 // consume the current token and advance to the next
@@ -84,13 +87,29 @@ func (p *Parser) consumeMatchingToken(tokenType token.TokenType, errorMessage st
 	return token.Token{}, compileError.NewParseError(p.getCurrentToken(), errorMessage)
 }
 
+// consumeLineEnd consumes the NLINE terminating an instruction.
+// The last instruction of a file may end at EOF instead of a newline.
+func (p *Parser) consumeLineEnd(errorMessage string) error {
+	if p.isAtEnd() {
+		return nil
+	}
+	_, err := p.consumeMatchingToken(token.NLINE, errorMessage)
+	return err
+}
+
+// skipBlankLine consumes an NLINE that does not terminate any instruction.
+// These come from empty lines, comment-only lines and the line break after an @END directive.
+func (p *Parser) skipBlankLine() bool {
+	return p.matchCurrentToken(token.NLINE)
+}
+
 // Synchronize discards tokens until a safe parsing boundary (newline or keyword).
 func (p *Parser) synchronize() {
 	// Ignore the error token. This has already been reported
 	p.advanceToken()
 	for !p.isAtEnd() {
 		currentToken := p.getCurrentToken()
-		if p.getPreviousToken().Lexeme == `\n` {
+		if p.getPreviousToken().Type == token.NLINE {
 			return
 		}
 		// If we find a Docklett or Docker keyword, we can synchronize because these are guaranteed valid tokens
@@ -113,6 +132,9 @@ func (p *Parser) Parse(tokens []token.Token) ([]ast.Statement, error) {
 	var parseErrors []error
 
 	for !p.isAtEnd() {
+		if p.skipBlankLine() {
+			continue
+		}
 		stmt, err := p.declaration()
 		if err != nil {
 			parseErrors = append(parseErrors, err)
@@ -126,3 +148,42 @@ func (p *Parser) Parse(tokens []token.Token) ([]ast.Statement, error) {
 	}
 	return statements, nil
 }
+
+// ParseExpression parses source as a single expression, such as the inside of a ${...} template in
+// Docker arguments. Token positions are shifted so that source starts at the given position,
+// which makes errors point into the file the expression was taken from.
+func ParseExpression(source string, at token.Position) (ast.Expression, error) {
+	s := scanner.Scanner{Source: source, SourceName: at.File}
+	shift := func(line, col *int) {
+		if *line == 1 {
+			*col += at.Col - 1
+		}
+		*line += at.Line - 1
+	}
+	if err := s.ScanExpression(); err != nil {
+		var scanErr *compileError.ScanError
+		if errors.As(err, &scanErr) {
+			shift(&scanErr.Line, &scanErr.Column)
+		}
+		return nil, err
+	}
+	for i := range s.Tokens {
+		shift(&s.Tokens[i].Position.Line, &s.Tokens[i].Position.Col)
+	}
+
+	p := &Parser{Tokens: s.Tokens}
+	expr, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isAtEnd() {
+		return nil, compileError.NewParseError(p.getCurrentToken(), "Unexpected token "+p.getCurrentToken().Lexeme+" after expression.")
+	}
+	return expr, nil
+}
+
+// ParseReference parses the expression of a ${call(...)} reference found in the arguments of stmt.
+func ParseReference(stmt *ast.DockerStatement, ref interpolate.Reference) (ast.Expression, error) {
+	at := interpolate.Position(stmt.Args, stmt.ArgsToken.Position, ref.Start+len("${"))
+	return ParseExpression(ref.Expression, at)
+}
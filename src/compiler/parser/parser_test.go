@@ -0,0 +1,321 @@
+package parser
+
+import (
+	"testing"
+
+	"docklett/compiler/ast"
+	"docklett/compiler/scanner"
+	"docklett/compiler/token"
+)
+
+func parseSource(t *testing.T, source string) []ast.Statement {
+	t.Helper()
+
+	s := scanner.Scanner{SourceName: "test.dock", Source: source}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan source: %v", err)
+	}
+	var p Parser
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return statements
+}
+
+func assertSpan(t *testing.T, name string, node ast.Node, startLine, startCol, endLine, endCol int) {
+	t.Helper()
+
+	pos, end := node.Pos(), node.End()
+	if pos.Line != startLine || pos.Col != startCol || end.Line != endLine || end.Col != endCol {
+		t.Errorf("%s: span %d:%d-%d:%d, want %d:%d-%d:%d", name,
+			pos.Line, pos.Col, end.Line, end.Col, startLine, startCol, endLine, endCol)
+	}
+}
+
+func TestParse_ExpressionSpans(t *testing.T) {
+	statements := parseSource(t, "@SET x = (1 + y) * -2\n@SET arr = [\"a\", \"b\"]\n@SET r = range(0, 10, 2)\nx = a && b\n")
+	if len(statements) != 4 {
+		t.Fatalf("got %d statements, want 4", len(statements))
+	}
+
+	decl := statements[0].(*ast.VariableDeclarationStatement)
+	assertSpan(t, "declaration", decl, 1, 1, 1, 22)
+	binary := decl.Initializer.(*ast.BinaryExpression)
+	assertSpan(t, "binary", binary, 1, 10, 1, 22)
+	assertSpan(t, "grouping", binary.Left, 1, 10, 1, 17)
+	assertSpan(t, "unary", binary.Right, 1, 20, 1, 22)
+
+	array := statements[1].(*ast.VariableDeclarationStatement).Initializer
+	assertSpan(t, "array", array, 2, 12, 2, 22)
+	rangeExpr := statements[2].(*ast.VariableDeclarationStatement).Initializer
+	assertSpan(t, "range", rangeExpr, 3, 10, 3, 25)
+
+	assign := statements[3].(*ast.ExpressionStatement)
+	assertSpan(t, "assignment", assign, 4, 1, 4, 11)
+	logical := assign.Expression.(*ast.AssignmentExpression).Value
+	assertSpan(t, "logical", logical, 4, 5, 4, 11)
+}
+
+func TestParse_StatementSpans(t *testing.T) {
+	source := "FROM alpine\n" +
+		"\n" +
+		"# pick packages\n" +
+		"@IF MODE == \"prod\"\n" +
+		"  RUN echo prod \\\n" +
+		"    && echo done\n" +
+		"@ELIF MODE == \"dev\"\n" +
+		"  RUN echo dev\n" +
+		"@ELSE\n" +
+		"  RUN echo other\n" +
+		"@END\n" +
+		"@FOR pkg IN [\"curl\"]\n" +
+		"  RUN apk add ${pkg}\n" +
+		"@END"
+	statements := parseSource(t, source)
+	if len(statements) != 3 {
+		t.Fatalf("got %d statements, want 3", len(statements))
+	}
+
+	assertSpan(t, "from", statements[0], 1, 1, 1, 12)
+
+	ifStmt := statements[1].(*ast.IfStatement)
+	assertSpan(t, "if", ifStmt, 4, 1, 11, 5)
+	assertSpan(t, "then block", ifStmt.ThenBranch, 4, 1, 7, 6)
+	assertSpan(t, "continued run", ifStmt.ThenBranch.Statements[0], 5, 3, 6, 17)
+
+	elif := ifStmt.ElseBranch.(*ast.IfStatement)
+	assertSpan(t, "elif", elif, 7, 1, 11, 5)
+	if elif.Open.Type != token.ELIF || elif.Close.Type != token.END {
+		t.Errorf("elif directives: got %s/%s", token.TokenTypeNames[elif.Open.Type], token.TokenTypeNames[elif.Close.Type])
+	}
+	elseBlock := elif.ElseBranch.(*ast.BlockStatement)
+	assertSpan(t, "else block", elseBlock, 9, 1, 11, 5)
+
+	forStmt := statements[2].(*ast.ForStatement)
+	assertSpan(t, "for", forStmt, 12, 1, 14, 5)
+	assertSpan(t, "for body", forStmt.Body, 12, 1, 14, 5)
+}
+
+func TestParse_ConstDeclaration(t *testing.T) {
+	statements := parseSource(t, "@CONST BASE = \"alpine\"\n@SET TAG = \"1\"\n")
+
+	constant, ok := statements[0].(*ast.VariableDeclarationStatement)
+	if !ok || !constant.IsConstant() || constant.Name.Lexeme != "BASE" {
+		t.Fatalf("statement 0 = %#v, want @CONST BASE", statements[0])
+	}
+	if variable := statements[1].(*ast.VariableDeclarationStatement); variable.IsConstant() {
+		t.Errorf("@SET parsed as a constant")
+	}
+
+	s := scanner.Scanner{SourceName: "test.dock", Source: "@CONST BASE\n"}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan source: %v", err)
+	}
+	var p Parser
+	if _, err := p.Parse(s.Tokens); err == nil {
+		t.Errorf("@CONST without a value parsed without error")
+	}
+}
+
+// Keywords are upper case inside an expression, so lower-case spellings such as true stay valid
+// names, as they were before directives were read in any case.
+func TestParse_LowerCaseNames(t *testing.T) {
+	for _, name := range []string{"true", "false", "True", "set", "default", "elif", "else", "end"} {
+		t.Run(name, func(t *testing.T) {
+			statements := parseSource(t, "@SET "+name+" = \"x\"\nRUN echo ${"+name+"}\n")
+			declaration, ok := statements[0].(*ast.VariableDeclarationStatement)
+			if !ok || declaration.Name.Lexeme != name {
+				t.Errorf("statement 0 = %#v, want @SET %s", statements[0], name)
+			}
+		})
+	}
+}
+
+func TestParse_DefaultDeclaration(t *testing.T) {
+	statements := parseSource(t, "@DEFAULT MODE = \"dev\"\n@DEFAULT PORT: int = 80\n")
+	for i, name := range []string{"MODE", "PORT"} {
+		decl, ok := statements[i].(*ast.VariableDeclarationStatement)
+		if !ok || !decl.IsDefault() || decl.IsConstant() || decl.Name.Lexeme != name {
+			t.Errorf("statement %d = %#v, want @DEFAULT %s", i, statements[i], name)
+		}
+	}
+
+	for _, source := range []string{"@DEFAULT MODE\n", "@IF TRUE\n@DEFAULT MODE = \"dev\"\n@END\n"} {
+		s := scanner.Scanner{SourceName: "test.dock", Source: source}
+		if err := s.ScanSource(); err != nil {
+			t.Fatalf("scan source: %v", err)
+		}
+		var p Parser
+		if _, err := p.Parse(s.Tokens); err == nil {
+			t.Errorf("%q parsed without error", source)
+		}
+	}
+}
+
+func TestParse_TypeAnnotation(t *testing.T) {
+	statements := parseSource(t, "@SET port: int = 8080\n@SET pkgs: [[string]] = []\n@SET name = \"x\"\n")
+
+	port := statements[0].(*ast.VariableDeclarationStatement)
+	if port.Type == nil || port.Type.String() != "int" {
+		t.Fatalf("port annotation = %v, want int", port.Type)
+	}
+	assertSpan(t, "annotation", port.Type, 1, 12, 1, 15)
+
+	pkgs := statements[1].(*ast.VariableDeclarationStatement)
+	if pkgs.Type == nil || !pkgs.Type.IsArray() || pkgs.Type.String() != "[[string]]" {
+		t.Fatalf("pkgs annotation = %v, want [[string]]", pkgs.Type)
+	}
+	assertSpan(t, "array annotation", pkgs.Type, 2, 12, 2, 22)
+
+	if name := statements[2].(*ast.VariableDeclarationStatement); name.Type != nil {
+		t.Errorf("unannotated declaration has type %v", name.Type)
+	}
+}
+
+func TestParse_Call(t *testing.T) {
+	statements := parseSource(t, "@SET tag = format(\"%s-%d\", name, 3)\n@SET empty = upper()\n")
+
+	call := statements[0].(*ast.VariableDeclarationStatement).Initializer.(*ast.CallExpression)
+	if call.Callee.Lexeme != "format" || len(call.Arguments) != 3 {
+		t.Fatalf("call = %s with %d arguments, want format with 3", call.Callee.Lexeme, len(call.Arguments))
+	}
+	assertSpan(t, "call", call, 1, 12, 1, 36)
+	assertSpan(t, "argument", call.Arguments[1], 1, 28, 1, 32)
+
+	empty := statements[1].(*ast.VariableDeclarationStatement).Initializer.(*ast.CallExpression)
+	if len(empty.Arguments) != 0 {
+		t.Errorf("upper() has %d arguments", len(empty.Arguments))
+	}
+}
+
+func TestParse_CoalesceIsRightAssociative(t *testing.T) {
+	expr, err := ParseExpression(`A ?? B && C ?? "x"`, token.Position{Line: 1, Col: 1})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	outer := expr.(*ast.LogicalExpression)
+	if outer.Operator.Type != token.COALESCE || outer.Left.(*ast.VariableExpression).Name.Lexeme != "A" {
+		t.Fatalf("outer = %#v, want A ?? (...)", outer)
+	}
+	inner := outer.Right.(*ast.LogicalExpression)
+	if inner.Operator.Type != token.COALESCE || inner.Left.(*ast.LogicalExpression).Operator.Type != token.AND {
+		t.Errorf("inner = %#v, want (B && C) ?? \"x\"", inner)
+	}
+}
+
+func TestParse_InBindsLikeComparison(t *testing.T) {
+	expr, err := ParseExpression(`A in B == C or D`, token.Position{Line: 1, Col: 1})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	or := expr.(*ast.LogicalExpression)
+	if or.Operator.Type != token.OR {
+		t.Fatalf("outer = %#v, want (...) or D", or)
+	}
+	equality := or.Left.(*ast.BinaryExpression)
+	if equality.Operator.Type != token.EQUAL || equality.Left.(*ast.BinaryExpression).Operator.Type != token.IN {
+		t.Errorf("left = %#v, want (A in B) == C", equality)
+	}
+}
+
+func TestParse_MapLiteral(t *testing.T) {
+	statements := parseSource(t, "@SET m = {\"a\": 1, \"b\": [x]}\n@SET empty = {}\n")
+
+	m := statements[0].(*ast.VariableDeclarationStatement).Initializer.(*ast.MapLiteralExpression)
+	if len(m.Keys) != 2 || len(m.Values) != 2 {
+		t.Fatalf("map has %d keys and %d values, want 2", len(m.Keys), len(m.Values))
+	}
+	assertSpan(t, "map", m, 1, 10, 1, 28)
+	assertSpan(t, "value", m.Values[1], 1, 24, 1, 27)
+
+	empty := statements[1].(*ast.VariableDeclarationStatement).Initializer.(*ast.MapLiteralExpression)
+	if len(empty.Keys) != 0 {
+		t.Errorf("{} has %d keys", len(empty.Keys))
+	}
+}
+
+func TestParse_Comprehension(t *testing.T) {
+	statements := parseSource(t, "@SET l = [p + \"-dev\" for p in pkgs if p != \"curl\"]\n@SET m = [k for k, v in env]\n")
+
+	c := statements[0].(*ast.VariableDeclarationStatement).Initializer.(*ast.ComprehensionExpression)
+	if len(c.Targets) != 1 || c.Targets[0].Lexeme != "p" || c.Filter == nil {
+		t.Fatalf("comprehension = %#v, want one target p and a filter", c)
+	}
+	assertSpan(t, "comprehension", c, 1, 10, 1, 51)
+	assertSpan(t, "iterable", c.Iterable, 1, 31, 1, 35)
+
+	pair := statements[1].(*ast.VariableDeclarationStatement).Initializer.(*ast.ComprehensionExpression)
+	if len(pair.Targets) != 2 || pair.Targets[1].Lexeme != "v" || pair.Filter != nil {
+		t.Errorf("comprehension = %#v, want targets k, v and no filter", pair)
+	}
+
+	for _, source := range []string{"[x for in xs]", "[x for x xs]", "[x, y for x in xs]", "[x for x in xs if]"} {
+		if _, err := ParseExpression(source, token.Position{Line: 1, Col: 1}); err == nil {
+			t.Errorf("ParseExpression(%s): want error", source)
+		}
+	}
+}
+
+func TestParse_Member(t *testing.T) {
+	statements := parseSource(t, "@SET m = -semver(v).major\n")
+
+	unary := statements[0].(*ast.VariableDeclarationStatement).Initializer.(*ast.UnaryExpression)
+	member, ok := unary.Right.(*ast.MemberExpression)
+	if !ok || member.Name.Lexeme != "major" {
+		t.Fatalf("operand = %#v, want a member read of major", unary.Right)
+	}
+	assertSpan(t, "member", member, 1, 11, 1, 26)
+
+	if _, err := ParseExpression("v.1", token.Position{Line: 1, Col: 1}); err == nil {
+		t.Error("ParseExpression(v.1): want error")
+	}
+}
+
+func TestParseExpression(t *testing.T) {
+	at := token.Position{Line: 4, Col: 10, File: "test.dock"}
+	expr, err := ParseExpression(`pad(build_number, 5, "0")`, at)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	assertSpan(t, "expression", expr, 4, 10, 4, 35)
+	if name := expr.(*ast.CallExpression).Arguments[0].(*ast.VariableExpression).Name; name.Position.File != "test.dock" {
+		t.Errorf("file = %q, want test.dock", name.Position.File)
+	}
+
+	for _, source := range []string{`upper(x) y`, `upper(x`, `upper(x) & 1`} {
+		if _, err := ParseExpression(source, at); err == nil {
+			t.Errorf("ParseExpression(%q): want error", source)
+		}
+	}
+}
+
+func TestTreePrinter_Statements(t *testing.T) {
+	statements := parseSource(t, "@SET x: int = 1\n@FOR p IN range(0, x)\nRUN echo ${p}\n@END\n")
+	got, err := NewTreePrinter().Sprint(statements)
+	if err != nil {
+		t.Fatalf("sprint: %v", err)
+	}
+
+	want := "Program\n" +
+		"├─[0]: VariableDeclaration\n" +
+		"│ ├─Keyword: SET [@SET] @Line:1,Col:1\n" +
+		"│ ├─Name: IDENTIFIER [x] @Line:1,Col:6 (Literal: x)\n" +
+		"│ ├─Type: int\n" +
+		"│ └─Initializer: LiteralExpression\n" +
+		"│   └─Value: 1\n" +
+		"└─[1]: For\n" +
+		"  ├─Target: IDENTIFIER [p] @Line:2,Col:6 (Literal: p)\n" +
+		"  ├─Iterable: Range\n" +
+		"  │ ├─Start: LiteralExpression\n" +
+		"  │ │ └─Value: 0\n" +
+		"  │ └─Stop: Variable\n" +
+		"  │   └─Name: IDENTIFIER [x] @Line:2,Col:20 (Literal: x)\n" +
+		"  └─Body: Block\n" +
+		"    └─[0]: Docker\n" +
+		"      ├─Keyword: DOCKER_KEYWORD [RUN] @Line:3,Col:1\n" +
+		"      └─Args: \"echo ${p}\"\n"
+	if got != want {
+		t.Errorf("tree:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -4,9 +4,14 @@ import (
 	"docklett/compiler/ast"
 	"docklett/compiler/token"
 	"fmt"
+	"reflect"
 	"strings"
 )
 
+// Compile-time check to ensure TreePrinter implements both visitors
+var _ ast.ExpressionVisitor = (*TreePrinter)(nil)
+var _ ast.StatementVisitor = (*TreePrinter)(nil)
+
 type TreePrinter struct {
 	isLastChild []bool
 }
@@ -55,6 +60,54 @@ func (tp *TreePrinter) formatToken(tok token.Token) string {
 	return base
 }
 
+// field prints "label: " and the subtree of node one level deeper.
+func (tp *TreePrinter) field(label string, node ast.Node, isLast bool) (string, error) {
+	result := tp.getIndent(isLast, true) + label + ": "
+	tp.isLastChild = append(tp.isLastChild, isLast)
+	defer func() { tp.isLastChild = tp.isLastChild[:len(tp.isLastChild)-1] }()
+
+	var sub any
+	var err error
+	switch n := node.(type) {
+	case ast.Expression:
+		sub, err = n.Accept(tp)
+	case ast.Statement:
+		sub, err = n.Accept(tp)
+	}
+	if err != nil {
+		return "", err
+	}
+	return result + sub.(string), nil
+}
+
+// fields prints a list of labelled subtrees; nil nodes are skipped.
+func (tp *TreePrinter) fields(labels []string, nodes []ast.Node) (string, error) {
+	var present []int
+	for i, node := range nodes {
+		if node != nil && !reflect.ValueOf(node).IsNil() {
+			present = append(present, i)
+		}
+	}
+	result := ""
+	for n, i := range present {
+		sub, err := tp.field(labels[i], nodes[i], n == len(present)-1)
+		if err != nil {
+			return "", err
+		}
+		result += sub
+	}
+	return result, nil
+}
+
+// list prints a node list as "[0]: ...", "[1]: ...".
+func (tp *TreePrinter) list(nodes []ast.Node) (string, error) {
+	labels := make([]string, len(nodes))
+	for i := range nodes {
+		labels[i] = fmt.Sprintf("[%d]", i)
+	}
+	return tp.fields(labels, nodes)
+}
+
 func (tp *TreePrinter) VisitLiteralExpr(literal *ast.LiteralExpression) (any, error) {
 	result := "LiteralExpression\n"
 	prefix := tp.getIndent(true, true)
@@ -127,7 +180,7 @@ func (tp *TreePrinter) VisitGroupingExpr(grouping *ast.GroupingExpression) (any,
 }
 
 func (tp *TreePrinter) VisitVariableExpr(variable *ast.VariableExpression) (any, error) {
-	return nil, nil
+	return "Variable\n" + tp.getIndent(true, true) + "Name: " + tp.formatToken(variable.Name) + "\n", nil
 }
 
 func (tp *TreePrinter) VisitLogicalExpr(logical *ast.LogicalExpression) (any, error) {
@@ -160,15 +213,122 @@ func (tp *TreePrinter) VisitLogicalExpr(logical *ast.LogicalExpression) (any, er
 }
 
 func (tp *TreePrinter) VisitAssignmentExpr(assignment *ast.AssignmentExpression) (any, error) {
-	return nil, nil
+	result := "Assignment\n" + tp.getIndent(false, true) + "Name: " + tp.formatToken(assignment.Name) + "\n"
+	value, err := tp.field("Value", assignment.Value, true)
+	return result + value, err
 }
 
 func (tp *TreePrinter) VisitArrayLiteralExpr(array *ast.ArrayLiteralExpression) (any, error) {
-	return nil, nil
+	elements := make([]ast.Node, len(array.Elements))
+	for i, elem := range array.Elements {
+		elements[i] = elem
+	}
+	result, err := tp.list(elements)
+	return "ArrayLiteral\n" + result, err
+}
+
+func (tp *TreePrinter) VisitMapLiteralExpr(mapLiteral *ast.MapLiteralExpression) (any, error) {
+	var labels []string
+	var nodes []ast.Node
+	for i := range mapLiteral.Keys {
+		labels = append(labels, fmt.Sprintf("Key[%d]", i), fmt.Sprintf("Value[%d]", i))
+		nodes = append(nodes, mapLiteral.Keys[i], mapLiteral.Values[i])
+	}
+	result, err := tp.fields(labels, nodes)
+	return "MapLiteral\n" + result, err
+}
+
+func (tp *TreePrinter) VisitComprehensionExpr(comprehension *ast.ComprehensionExpression) (any, error) {
+	names := make([]string, len(comprehension.Targets))
+	for i, target := range comprehension.Targets {
+		names[i] = tp.formatToken(target)
+	}
+	result := "Comprehension\n" + tp.getIndent(false, true) + "Targets: " + strings.Join(names, ", ") + "\n"
+	fields, err := tp.fields([]string{"Element", "Iterable", "Filter"},
+		[]ast.Node{comprehension.Element, comprehension.Iterable, comprehension.Filter})
+	return result + fields, err
 }
 
 func (tp *TreePrinter) VisitRangeExpr(rangeExpr *ast.RangeExpression) (any, error) {
-	return nil, nil
+	result, err := tp.fields([]string{"Start", "Stop", "Step"},
+		[]ast.Node{rangeExpr.Start, rangeExpr.Stop, rangeExpr.Step})
+	return "Range\n" + result, err
+}
+
+func (tp *TreePrinter) VisitCallExpr(call *ast.CallExpression) (any, error) {
+	arguments := make([]ast.Node, len(call.Arguments))
+	for i, arg := range call.Arguments {
+		arguments[i] = arg
+	}
+	result := "Call\n" + tp.getIndent(len(arguments) == 0, true) + "Callee: " + tp.formatToken(call.Callee) + "\n"
+	rest, err := tp.list(arguments)
+	return result + rest, err
+}
+
+func (tp *TreePrinter) VisitMemberExpr(member *ast.MemberExpression) (any, error) {
+	result := "Member\n" + tp.getIndent(false, true) + "Name: " + tp.formatToken(member.Name) + "\n"
+	object, err := tp.field("Object", member.Object, true)
+	return result + object, err
+}
+
+func (tp *TreePrinter) VisitExpressionStatement(stmt *ast.ExpressionStatement) (any, error) {
+	result, err := tp.field("Expression", stmt.Expression, true)
+	return "ExpressionStatement\n" + result, err
+}
+
+func (tp *TreePrinter) VisitVarDeclarationStatement(stmt *ast.VariableDeclarationStatement) (any, error) {
+	result := "VariableDeclaration\n"
+	last := stmt.Initializer == nil
+	result += tp.getIndent(false, true) + "Keyword: " + tp.formatToken(stmt.Keyword) + "\n"
+	if stmt.Type != nil {
+		result += tp.getIndent(false, true) + "Name: " + tp.formatToken(stmt.Name) + "\n"
+		result += tp.getIndent(last, true) + "Type: " + stmt.Type.String() + "\n"
+	} else {
+		result += tp.getIndent(last, true) + "Name: " + tp.formatToken(stmt.Name) + "\n"
+	}
+	if last {
+		return result, nil
+	}
+	initializer, err := tp.field("Initializer", stmt.Initializer, true)
+	return result + initializer, err
+}
+
+func (tp *TreePrinter) VisitBlockStatement(stmt *ast.BlockStatement) (any, error) {
+	statements := make([]ast.Node, len(stmt.Statements))
+	for i, s := range stmt.Statements {
+		statements[i] = s
+	}
+	result, err := tp.list(statements)
+	return "Block\n" + result, err
+}
+
+func (tp *TreePrinter) VisitIfStatement(stmt *ast.IfStatement) (any, error) {
+	result, err := tp.fields([]string{"Condition", "Then", "Else"},
+		[]ast.Node{stmt.Condition, stmt.ThenBranch, stmt.ElseBranch})
+	return "If\n" + result, err
+}
+
+func (tp *TreePrinter) VisitDockerStatement(stmt *ast.DockerStatement) (any, error) {
+	result := "Docker\n"
+	result += tp.getIndent(false, true) + "Keyword: " + tp.formatToken(stmt.Keyword) + "\n"
+	result += tp.getIndent(true, true) + fmt.Sprintf("Args: %q\n", stmt.Args)
+	return result, nil
+}
+
+func (tp *TreePrinter) VisitForStatement(stmt *ast.ForStatement) (any, error) {
+	result := "For\n" + tp.getIndent(false, true) + "Target: " + tp.formatToken(stmt.Target) + "\n"
+	rest, err := tp.fields([]string{"Iterable", "Body"}, []ast.Node{stmt.Iterable, stmt.Body})
+	return result + rest, err
+}
+
+// Sprint renders a statement list, such as the Parser output, as a tree.
+func (tp *TreePrinter) Sprint(statements []ast.Statement) (string, error) {
+	nodes := make([]ast.Node, len(statements))
+	for i, stmt := range statements {
+		nodes[i] = stmt
+	}
+	result, err := tp.list(nodes)
+	return "Program\n" + result, err
 }
 
 func PrintAST(expr ast.Expression) {
@@ -181,6 +341,15 @@ func PrintAST(expr ast.Expression) {
 	fmt.Println(result.(string))
 }
 
+func PrintStatements(statements []ast.Statement) {
+	result, err := NewTreePrinter().Sprint(statements)
+	if err != nil {
+		fmt.Printf("Error printing AST: %v\n", err)
+		return
+	}
+	fmt.Print(result)
+}
+
 func DemoPrinter() {
 	// (5 + 3) * 2
 	expr := &ast.BinaryExpression{
@@ -11,17 +11,18 @@ package parser
 
 import (
 	"docklett/compiler/ast"
+	compileError "docklett/compiler/error"
 	"docklett/compiler/token"
 )
 
 // declaration wraps statement parsing with panic-mode error recovery.
-// Checks for Docklett directives (@SET), Docker instructions (FROM, RUN, etc.),
+// Checks for Docklett directives (@SET, @CONST, @DEFAULT), Docker instructions (FROM, RUN, etc.),
 // then falls through to general statement parsing.
 func (p *Parser) declaration() (ast.Statement, error) {
 	var stmt ast.Statement
 	var err error
 
-	if p.matchCurrentToken(token.SET) {
+	if p.matchCurrentToken(token.SET, token.CONST, token.DEFAULT) {
 		stmt, err = p.variableDeclaration()
 	} else if p.matchCurrentToken(token.DOCKER_KEYWORD) {
 		stmt, err = p.dockerStatement()
@@ -50,12 +51,36 @@ func (p *Parser) declaration() (ast.Statement, error) {
 //    long will it live:
 //    print (var x = 5) + x;
 
+// @CONST shares the rule but its initializer is mandatory: a constant can never be given a value later.
+// So does @DEFAULT, which must also be at the top level: build variables are global.
 func (p *Parser) variableDeclaration() (ast.Statement, error) {
-	identifier, errIdentifier := p.consumeMatchingToken(token.IDENTIFIER, "Expect identifier after SET variable declaration")
+	keyword := p.getPreviousToken()
+	identifier, errIdentifier := p.consumeMatchingToken(token.IDENTIFIER, "Expect identifier after "+keyword.Lexeme+" variable declaration")
 	if errIdentifier != nil {
 		return nil, errIdentifier
 	}
 
+	var annotation *ast.TypeAnnotation
+	if p.matchCurrentToken(token.COLON) {
+		var err error
+		annotation, err = p.typeAnnotation()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if keyword.Type == token.CONST && !p.checkCurrentToken(token.ASSIGN) {
+		return nil, compileError.NewParseError(p.getCurrentToken(), "Expect '=' after constant name, @CONST requires a value")
+	}
+	if keyword.Type == token.DEFAULT {
+		if p.depth > 0 {
+			return nil, compileError.NewParseError(keyword, "@DEFAULT is only allowed at the top level of a file")
+		}
+		if !p.checkCurrentToken(token.ASSIGN) {
+			return nil, compileError.NewParseError(p.getCurrentToken(), "Expect '=' after build variable name, @DEFAULT requires a value")
+		}
+	}
+
 	var expression ast.Expression = nil
 	if p.matchCurrentToken(token.ASSIGN) {
 		var err error
@@ -65,8 +90,31 @@ func (p *Parser) variableDeclaration() (ast.Statement, error) {
 		}
 	}
 
-	return &ast.VariableDeclarationStatement{Name: identifier, Initializer: expression}, nil
+	return &ast.VariableDeclarationStatement{Keyword: keyword, Name: identifier, Type: annotation, Initializer: expression}, nil
+
+}
+
+// typeAnnotation parses: IDENTIFIER | "[" typeAnnotation "]"
+// The COLON after the declared name is already consumed. Type names are checked by the type checker.
+func (p *Parser) typeAnnotation() (*ast.TypeAnnotation, error) {
+	if p.matchCurrentToken(token.LBRACKET) {
+		lbracket := p.getPreviousToken()
+		element, err := p.typeAnnotation()
+		if err != nil {
+			return nil, err
+		}
+		rbracket, err := p.consumeMatchingToken(token.RBRACKET, "Expected ']' after array element type.")
+		if err != nil {
+			return nil, err
+		}
+		return &ast.TypeAnnotation{LBracket: lbracket, Element: element, RBracket: rbracket}, nil
+	}
 
+	name, err := p.consumeMatchingToken(token.IDENTIFIER, "Expected type name after ':'.")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.TypeAnnotation{Name: name}, nil
 }
 
 func (p *Parser) statement() (ast.Statement, error) {
@@ -90,18 +138,19 @@ func (p *Parser) dockerStatement() (ast.Statement, error) {
 		return nil, err
 	}
 
-	_, err = p.consumeMatchingToken(token.NLINE, "Expected newline after Docker instruction.")
+	err = p.consumeLineEnd("Expected newline after Docker instruction.")
 	if err != nil {
 		return nil, err
 	}
 
-	return &ast.DockerStatement{Keyword: keyword, Args: args.Lexeme}, nil
+	return &ast.DockerStatement{Keyword: keyword, Args: args.Lexeme, ArgsToken: args}, nil
 }
 
 // forStatement parses: @FOR IDENTIFIER IN iterable NLINE body @END
 // The FOR token is already consumed by statement().
 // Iterable can be an array literal [a, b, c] or a range(start, end) call.
 func (p *Parser) forStatement() (ast.Statement, error) {
+	open := p.getPreviousToken()
 	target, err := p.consumeMatchingToken(token.IDENTIFIER, "Expected loop variable after @FOR.")
 	if err != nil {
 		return nil, err
@@ -127,15 +176,17 @@ func (p *Parser) forStatement() (ast.Statement, error) {
 		return nil, err
 	}
 
-	_, err = p.consumeMatchingToken(token.END, "Expected @END after for loop body.")
+	end, err := p.consumeMatchingToken(token.END, "Expected @END after for loop body.")
 	if err != nil {
 		return nil, err
 	}
 
 	return &ast.ForStatement{
+		Open:     open,
 		Target:   target,
 		Iterable: iterable,
-		Body:     &ast.BlockStatement{Statements: bodyStatements},
+		Body:     &ast.BlockStatement{Open: open, Statements: bodyStatements, Close: end},
+		Close:    end,
 	}, nil
 }
 
@@ -157,7 +208,7 @@ func (p *Parser) expressionStatement() (ast.Statement, error) {
 		return nil, err
 	}
 	// a single expression must end the instruction (reminder instructions can be multi line)
-	_, err = p.consumeMatchingToken(token.NLINE, "Expected newline after expression to signal end of instruction.")
+	err = p.consumeLineEnd("Expected newline after expression to signal end of instruction.")
 	if err != nil {
 		return nil, err
 	}
@@ -169,9 +220,14 @@ func (p *Parser) expressionStatement() (ast.Statement, error) {
 // used by IF, ELSE, and FOR blocks.
 func (p *Parser) collectStatements(terminators ...token.TokenType) ([]ast.Statement, error) {
 	var statements []ast.Statement
+	p.depth++
+	defer func() { p.depth-- }()
 
 	// Continue parsing as long as we haven't hit a terminator or the EOF
 	for !p.isAtEnd() && !p.checkCurrentToken(terminators...) {
+		if p.skipBlankLine() {
+			continue
+		}
 		stmt, err := p.declaration()
 		if err != nil {
 			return nil, err
@@ -182,20 +238,9 @@ func (p *Parser) collectStatements(terminators ...token.TokenType) ([]ast.Statem
 	return statements, nil
 }
 
-func (p *Parser) blockStatement() (ast.Statement, error) {
-	statements, err := p.collectStatements()
-	if err != nil {
-		return nil, err
-	}
-	_, err = p.consumeMatchingToken(token.END, "END directive expected after block declaration.")
-	if err != nil {
-		return nil, err
-	}
-	return &ast.BlockStatement{Statements: statements}, nil
-}
-
 func (p *Parser) ifStatement() (ast.Statement, error) {
 	var elseBranch ast.Statement
+	open := p.getPreviousToken()
 	// Parse the boolean guard that decides true vs false path
 	// The IF or ELIF token is already consumed.
 	condition, err := p.expression()
@@ -214,7 +259,8 @@ func (p *Parser) ifStatement() (ast.Statement, error) {
 	if err != nil {
 		return nil, err
 	}
-	thenBranch := &ast.BlockStatement{Statements: thenStatements}
+	// the block is closed by whichever directive stopped the collection (checked below)
+	thenBranch := &ast.BlockStatement{Open: open, Statements: thenStatements, Close: p.getCurrentToken()}
 
 	// If an ELIF is found, we recurse. This creates the 'ElseBranch' link
 	// to a new IfStatement, continuing the chain.
@@ -224,12 +270,15 @@ func (p *Parser) ifStatement() (ast.Statement, error) {
 			return nil, err
 		}
 		// The deeply nested call already consumed the END so we can immediately return.
-		return &ast.IfStatement{Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}, nil
+		// The whole chain shares the END of its innermost link.
+		closing := elseBranch.(*ast.IfStatement).Close
+		return &ast.IfStatement{Open: open, Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch, Close: closing}, nil
 	}
 
 	// ELSE: collect all statements in the false-path and group them into a single block
 	// If an ELSE is found, we collect the final "catch-all" block.
 	if p.matchCurrentToken(token.ELSE) {
+		elseToken := p.getPreviousToken()
 		_, err = p.consumeMatchingToken(token.NLINE, "Expected newline after ELSE.")
 		if err != nil {
 			return nil, err
@@ -239,14 +288,14 @@ func (p *Parser) ifStatement() (ast.Statement, error) {
 		if err != nil {
 			return nil, err
 		}
-		elseBranch = &ast.BlockStatement{Statements: elseStatements}
+		elseBranch = &ast.BlockStatement{Open: elseToken, Statements: elseStatements, Close: p.getCurrentToken()}
 	}
 
 	// Every conditional chain (no matter how many ELIFs) must end with exactly one 'END'.
-	_, err = p.consumeMatchingToken(token.END, "END directive expected after if block.")
+	end, err := p.consumeMatchingToken(token.END, "END directive expected after if block.")
 	if err != nil {
 		return nil, err
 	}
 
-	return &ast.IfStatement{Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}, nil
+	return &ast.IfStatement{Open: open, Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch, Close: end}, nil
 }
@@ -0,0 +1,275 @@
+/*
+Expression folding for partial evaluation.
+
+fold rebuilds an expression bottom-up. Known variables become literals, and any node whose
+operands are all literals is evaluated by the shared evaluator and replaced with its value:
+
+	MODE == "prod" && 1 + 1 == 2     MODE unknown →  MODE == "prod" && TRUE
+	TRUE || MODE                                  →  TRUE
+	FALSE || MODE                                 →  MODE
+	range(0, N)                      N = 3        →  [0, 1, 2]
+	CUDA ?? "12.2"                   CUDA = nil   →  "12.2"
+	defined(CUDA)                    CUDA unknown →  TRUE
+	upper(NAME) + "-" + TAG          NAME = "api" →  "API-" + TAG
+
+defined(NAME) folds to TRUE once NAME is bound, known or not. An unbound NAME stays as it is: the build
+may still pass it as a build variable, so FALSE is never certain.
+
+A comprehension is folded to its value when its iterable is known and its element and filter use
+nothing but its own targets; otherwise the parts are folded with the targets unknown.
+
+Calls to impure built-ins (file_exists, read_file, ...) are never folded: the residual program
+reads the build context when it is compiled, not when it is previewed. Neither are values without a
+literal, such as a string with a double quote: the residual program must parse back to the same values.
+*/
+package partial
+
+import (
+	"docklett/compiler/ast"
+	"docklett/compiler/builtin"
+	"docklett/compiler/format"
+	"docklett/compiler/token"
+	"docklett/compiler/value"
+)
+
+// fold simplifies expr as far as the known bindings allow. Evaluation errors are reported and
+// leave the expression unfolded.
+func (p *partialEvaluator) fold(expr ast.Expression) ast.Expression {
+	var folded ast.Expression
+	switch e := expr.(type) {
+	case nil:
+		return nil
+
+	case *ast.LiteralExpression:
+		return e
+
+	case *ast.VariableExpression:
+		if val, ok := p.lookup(e.Name.Lexeme); ok {
+			return foldedTo(val, e)
+		}
+		return e
+
+	case *ast.AssignmentExpression:
+		assignment := *e
+		assignment.Value = p.fold(e.Value)
+		val, known := constant(assignment.Value)
+		p.assign(e.Name, val, known)
+		return &assignment
+
+	case *ast.LogicalExpression:
+		return p.foldLogical(e)
+
+	case *ast.GroupingExpression:
+		inner := p.fold(e.Expression)
+		if _, ok := constant(inner); ok {
+			return inner
+		}
+		grouping := *e
+		grouping.Expression = inner
+		return &grouping
+
+	case *ast.UnaryExpression:
+		unary := *e
+		unary.Right = p.fold(e.Right)
+		folded = &unary
+
+	case *ast.BinaryExpression:
+		binary := *e
+		binary.Left, binary.Right = p.fold(e.Left), p.fold(e.Right)
+		folded = &binary
+
+	case *ast.ArrayLiteralExpression:
+		array := *e
+		array.Elements = make([]ast.Expression, len(e.Elements))
+		for i, elem := range e.Elements {
+			array.Elements[i] = p.fold(elem)
+		}
+		folded = &array
+
+	case *ast.MapLiteralExpression:
+		mapLiteral := *e
+		mapLiteral.Keys = make([]ast.Expression, len(e.Keys))
+		mapLiteral.Values = make([]ast.Expression, len(e.Values))
+		for i := range e.Keys {
+			mapLiteral.Keys[i], mapLiteral.Values[i] = p.fold(e.Keys[i]), p.fold(e.Values[i])
+		}
+		folded = &mapLiteral
+
+	case *ast.ComprehensionExpression:
+		return p.foldComprehension(e)
+
+	case *ast.RangeExpression:
+		rangeExpr := *e
+		rangeExpr.Start, rangeExpr.Stop, rangeExpr.Step = p.fold(e.Start), p.fold(e.Stop), p.fold(e.Step)
+		folded = &rangeExpr
+
+	case *ast.CallExpression:
+		if f, ok := builtin.Lookup(e.Callee.Lexeme); ok && f.ByName {
+			return p.foldByName(e)
+		}
+		call := *e
+		call.Arguments = make([]ast.Expression, len(e.Arguments))
+		for i, arg := range e.Arguments {
+			call.Arguments[i] = p.fold(arg)
+		}
+		if f, ok := builtin.Lookup(e.Callee.Lexeme); ok && !f.Pure() {
+			return &call
+		}
+		folded = &call
+
+	case *ast.MemberExpression:
+		member := *e
+		member.Object = p.fold(e.Object)
+		folded = &member
+
+	default:
+		return expr
+	}
+
+	if !allConstant(ast.Children(folded)) {
+		return folded
+	}
+	val, err := p.evaluate(folded)
+	if err != nil {
+		p.report(err)
+		return folded
+	}
+	return foldedTo(val, folded)
+}
+
+// foldLogical short-circuits on a constant left operand, exactly like the evaluator.
+// The right operand only runs sometimes, so its assignments are residual.
+func (p *partialEvaluator) foldLogical(e *ast.LogicalExpression) ast.Expression {
+	logical := *e
+	logical.Left = p.fold(e.Left)
+	if left, ok := constant(logical.Left); ok {
+		decided := value.Truthy(left) == (e.Operator.Type == token.OR)
+		if e.Operator.Type == token.COALESCE {
+			decided = left != nil
+		}
+		if decided {
+			return logical.Left
+		}
+		return p.fold(e.Right)
+	}
+	p.residually(func() { logical.Right = p.fold(e.Right) })
+	return &logical
+}
+
+// foldByName folds defined(NAME) when NAME is bound; the evaluator reports misuse.
+func (p *partialEvaluator) foldByName(e *ast.CallExpression) ast.Expression {
+	if len(e.Arguments) != 1 {
+		return e
+	}
+	if variable, ok := e.Arguments[0].(*ast.VariableExpression); ok {
+		if _, bound := p.scope.Lookup(variable.Name.Lexeme); bound {
+			return literal(true, e.Pos())
+		}
+	}
+	return e
+}
+
+// foldComprehension folds the element and filter in a residual region where the targets are unknown,
+// so an outer binding of the same name is never substituted for them.
+func (p *partialEvaluator) foldComprehension(e *ast.ComprehensionExpression) ast.Expression {
+	comprehension := *e
+	comprehension.Iterable = p.fold(e.Iterable)
+	p.residually(func() {
+		for _, target := range e.Targets {
+			p.scope.Define(target.Lexeme, unknown)
+		}
+		comprehension.Filter = p.fold(e.Filter)
+		comprehension.Element = p.fold(e.Element)
+	})
+	if _, ok := constant(comprehension.Iterable); !ok || !closed(&comprehension) {
+		return &comprehension
+	}
+	val, err := p.evaluate(&comprehension)
+	if err != nil {
+		p.report(err)
+		return &comprehension
+	}
+	return foldedTo(val, &comprehension)
+}
+
+// closed reports whether the element and filter of a comprehension read only its targets, assign
+// nothing and call only pure built-ins, so evaluating it needs no unknown value.
+func closed(comprehension *ast.ComprehensionExpression) bool {
+	targets := make(map[string]bool)
+	for _, target := range comprehension.Targets {
+		targets[target.Lexeme] = true
+	}
+	ok := true
+	for _, part := range []ast.Expression{comprehension.Element, comprehension.Filter} {
+		if part == nil {
+			continue
+		}
+		ast.Inspect(part, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.VariableExpression:
+				ok = ok && targets[n.Name.Lexeme]
+			case *ast.AssignmentExpression:
+				ok = false
+			case *ast.CallExpression:
+				f, known := builtin.Lookup(n.Callee.Lexeme)
+				ok = ok && known && f.Pure()
+			}
+			return ok
+		})
+	}
+	return ok
+}
+
+// constant returns the value of a folded expression that is a literal.
+func constant(expr ast.Expression) (any, bool) {
+	if lit, ok := expr.(*ast.LiteralExpression); ok {
+		return lit.Value, true
+	}
+	return nil, false
+}
+
+func allConstant(nodes []ast.Node) bool {
+	for _, n := range nodes {
+		if expr, ok := n.(ast.Expression); !ok {
+			return false
+		} else if _, ok := constant(expr); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluate computes expr in the current scope, within the limits of the whole preview.
+func (p *partialEvaluator) evaluate(expr ast.Expression) (any, error) {
+	p.evaluator.Scope = p.scope
+	return p.evaluator.Evaluate(expr)
+}
+
+// foldedTo replaces expr with the literal of its value, or keeps expr when the value has no
+// literal (see format.IsLiteral), so the residual program always parses back to the same value.
+// nil has none either but is folded, so NOTHING ?? "x" still folds; a declaration drops it.
+func foldedTo(val any, expr ast.Expression) ast.Expression {
+	if val != nil && !format.IsLiteral(val) {
+		return expr
+	}
+	return literal(val, expr.Pos())
+}
+
+// literal builds the literal expression for a folded value, positioned where the folded code started.
+func literal(val any, pos token.Position) *ast.LiteralExpression {
+	tok := token.Token{Lexeme: format.Value(val), Literal: val, Position: pos}
+	switch v := val.(type) {
+	case bool:
+		tok.Type = token.FALSE
+		if v {
+			tok.Type = token.TRUE
+		}
+	case string:
+		tok.Type = token.STRING
+	case int, float64:
+		tok.Type = token.NUMBER
+	default:
+		tok.Type = token.ILLEGAL // arrays, maps and nil have no single-token spelling
+	}
+	return &ast.LiteralExpression{Value: val, Token: tok}
+}
@@ -0,0 +1,387 @@
+/*
+Package partial implements partial evaluation: it runs a Docklett program with only some variables
+bound and returns the residual program, i.e. everything that still depends on the unbound ones.
+
+Given MODE unbound and nothing else:
+
+	@SET BASE = "alpine"                FROM alpine:3.19
+	@SET VER = "3.19"                   @IF MODE == "prod"
+	FROM ${BASE}:${VER}                     RUN apk add curl
+	@IF MODE == "prod"           →          RUN apk add git
+	    @FOR p IN ["curl", "git"]       @END
+	        RUN apk add ${p}            RUN echo debug off
+	    @END
+	@END
+	@IF 1 > 2
+	    RUN echo debug on
+	@ELSE
+	    RUN echo debug off
+	@END
+
+RULES:
+  - expressions are folded as far as known bindings allow; unbound names stay as variable references
+  - a condition that folds to a constant selects its branch, which is spliced into the enclosing block
+  - a condition that does not fold stays as a residual @IF; its branches are simplified under the
+    assumption that they may or may not run
+  - a @FOR over a constant iterable is unrolled, one copy of the body per element
+  - a @FOR over an unknown iterable stays, with its body simplified and the target unknown
+  - ${name} in Docker arguments is expanded when name is known, and left alone otherwise;
+    ${call(...)} is expanded when the whole expression folds to a constant
+  - a declaration with a known value is dropped once nothing left in its block refers to it
+  - a @DEFAULT is unknown, since the build may override it, unless known binds its name: then the
+    known value replaces the initializer
+
+Assignments to outer variables inside a residual branch or loop make those variables unknown from
+then on, since the assignment may or may not have happened.
+
+A spliced block that still declares variables is kept as "@IF TRUE ... @END", so its declarations
+stay in their own scope exactly as in the original program.
+*/
+package partial
+
+import (
+	"context"
+	"docklett/compiler/ast"
+	compileError "docklett/compiler/error"
+	"docklett/compiler/evaluator"
+	"docklett/compiler/interpolate"
+	"docklett/compiler/parser"
+	"docklett/compiler/scope"
+	"docklett/compiler/token"
+	"docklett/compiler/types"
+	"docklett/compiler/value"
+	"errors"
+	"fmt"
+)
+
+// unknown is bound to names whose value cannot be known at partial-evaluation time.
+type unknownValue struct{}
+
+var unknown = unknownValue{}
+
+type partialEvaluator struct {
+	scope *scope.Scope
+	known map[string]any
+	// boundary is the outermost scope of the innermost residual region, nil outside residual code.
+	// Bindings owned by scopes outside the boundary may or may not be changed by the region.
+	boundary *scope.Scope
+	// evaluator folds constant expressions; its Limits and loop counters span the whole preview.
+	evaluator *evaluator.Evaluator
+	errors    []error
+}
+
+// Evaluate partially evaluates statements with the given known bindings and returns the residual
+// program. Names that are neither in known nor declared by the program are treated as unknown.
+// Evaluation errors in known code (e.g. "a" - 1) are collected and joined, and so are the errors of
+// folding or unrolling past limits, as when compiling.
+func Evaluate(statements []ast.Statement, known map[string]any, limits evaluator.Limits) ([]ast.Statement, error) {
+	return EvaluateContext(context.Background(), statements, known, limits)
+}
+
+// EvaluateContext evaluates like Evaluate, reporting a LimitError once ctx is canceled or its
+// deadline passes.
+func EvaluateContext(ctx context.Context, statements []ast.Statement, known map[string]any, limits evaluator.Limits) ([]ast.Statement, error) {
+	p := &partialEvaluator{scope: scope.New(nil), known: known, evaluator: evaluator.New(nil)}
+	p.evaluator.Limits, p.evaluator.Context = limits, ctx
+	for name, val := range known {
+		p.scope.Define(name, val)
+	}
+	residual := p.statements(statements)
+	return residual, errors.Join(p.errors...)
+}
+
+func (p *partialEvaluator) report(err error) {
+	p.errors = append(p.errors, err)
+}
+
+// statements simplifies a statement list in the current scope.
+func (p *partialEvaluator) statements(statements []ast.Statement) []ast.Statement {
+	var out []ast.Statement
+	for _, stmt := range statements {
+		out = append(out, p.statement(stmt)...)
+	}
+	return prune(out)
+}
+
+// block simplifies a block's statements in a child scope.
+func (p *partialEvaluator) block(stmt *ast.BlockStatement) []ast.Statement {
+	previous := p.scope
+	p.scope = scope.New(previous)
+	defer func() { p.scope = previous }()
+	return p.statements(stmt.Statements)
+}
+
+// residually runs f in a new residual region: code that may or may not run.
+func (p *partialEvaluator) residually(f func()) {
+	previousScope, previousBoundary := p.scope, p.boundary
+	p.scope = scope.New(previousScope)
+	p.boundary = p.scope
+	defer func() { p.scope, p.boundary = previousScope, previousBoundary }()
+	f()
+}
+
+func (p *partialEvaluator) statement(stmt ast.Statement) []ast.Statement {
+	switch s := stmt.(type) {
+	case *ast.ExpressionStatement:
+		expr := p.fold(s.Expression)
+		if _, ok := constant(expr); ok {
+			return nil // no side effects left
+		}
+		return []ast.Statement{&ast.ExpressionStatement{Expression: expr}}
+
+	case *ast.VariableDeclarationStatement:
+		decl := *s
+		var val any = nil
+		if s.IsDefault() {
+			val = unknown
+			if known, ok := p.known[s.Name.Lexeme]; ok {
+				known = types.BuildVariable(s, known)
+				val = known
+				decl.Initializer = foldedTo(known, s.Initializer)
+			}
+		} else if s.Initializer != nil {
+			decl.Initializer = p.fold(s.Initializer)
+			var ok bool
+			if val, ok = constant(decl.Initializer); !ok {
+				val = unknown
+			} else if val == nil {
+				decl.Initializer = nil // nil has no literal: "@SET X" declares it
+			}
+		}
+		p.scope.Define(s.Name.Lexeme, val)
+		return []ast.Statement{&decl}
+
+	case *ast.DockerStatement:
+		docker := *s
+		args, err := interpolate.Expand(s.Args, p.lookup, interpolate.Options{Residual: true, Evaluate: p.template(s)})
+		var located compileError.CompileError
+		if errors.As(err, &located) {
+			p.report(err)
+		} else if err != nil {
+			p.report(compileError.NewEvaluationError(s, err.Error()))
+		}
+		docker.Args = args
+		return []ast.Statement{&docker}
+
+	case *ast.BlockStatement:
+		return p.spliced(s)
+
+	case *ast.IfStatement:
+		return p.ifStatement(s)
+
+	case *ast.ForStatement:
+		return p.forStatement(s)
+	}
+	p.report(fmt.Errorf("partial evaluation: unsupported statement %T", stmt))
+	return []ast.Statement{stmt}
+}
+
+// spliced simplifies a block that is known to run once and returns its statements for the enclosing block.
+func (p *partialEvaluator) spliced(stmt *ast.BlockStatement) []ast.Statement {
+	out := p.block(stmt)
+	for _, s := range out {
+		if _, ok := s.(*ast.VariableDeclarationStatement); ok {
+			return []ast.Statement{scoped(stmt, out)}
+		}
+	}
+	return out
+}
+
+// scoped wraps statements in "@IF TRUE ... @END", the only way to write a block in Docklett.
+func scoped(original *ast.BlockStatement, statements []ast.Statement) *ast.IfStatement {
+	open := token.Token{Type: token.IF, Lexeme: "@IF", Position: original.Pos()}
+	return &ast.IfStatement{
+		Open:       open,
+		Condition:  literal(true, original.Pos()),
+		ThenBranch: &ast.BlockStatement{Open: open, Statements: statements, Close: original.Close},
+		Close:      original.Close,
+	}
+}
+
+// ifStatement takes the branch of a constant condition, or keeps a residual @IF.
+func (p *partialEvaluator) ifStatement(stmt *ast.IfStatement) []ast.Statement {
+	condition := p.fold(stmt.Condition)
+	if val, ok := constant(condition); ok {
+		if value.Truthy(val) {
+			return p.spliced(stmt.ThenBranch)
+		}
+		switch elseBranch := stmt.ElseBranch.(type) {
+		case *ast.IfStatement:
+			return p.ifStatement(elseBranch)
+		case *ast.BlockStatement:
+			return p.spliced(elseBranch)
+		}
+		return nil
+	}
+
+	residual := &ast.IfStatement{Open: stmt.Open, Condition: condition, Close: stmt.Close}
+	p.residually(func() {
+		residual.ThenBranch = &ast.BlockStatement{Open: stmt.ThenBranch.Open,
+			Statements: p.block(stmt.ThenBranch), Close: stmt.ThenBranch.Close}
+	})
+	if stmt.ElseBranch == nil {
+		return []ast.Statement{residual}
+	}
+
+	var rest []ast.Statement
+	p.residually(func() {
+		switch elseBranch := stmt.ElseBranch.(type) {
+		case *ast.IfStatement:
+			rest = p.ifStatement(elseBranch)
+		case *ast.BlockStatement:
+			rest = p.block(elseBranch)
+		}
+	})
+	open := stmt.ThenBranch.Close // the @ELIF or @ELSE directive that ends the then-branch
+	switch {
+	case len(rest) == 0:
+	case len(rest) == 1 && isIf(rest[0]):
+		// a residual @ELIF, or a taken @ELIF that had to stay scoped
+		elif := *rest[0].(*ast.IfStatement)
+		elif.Open = token.Token{Type: token.ELIF, Lexeme: "@ELIF", Position: open.Position}
+		residual.ElseBranch = &elif
+	default:
+		open.Type, open.Lexeme = token.ELSE, "@ELSE"
+		residual.ElseBranch = &ast.BlockStatement{Open: open, Statements: rest, Close: stmt.Close}
+	}
+	return []ast.Statement{residual}
+}
+
+func isIf(stmt ast.Statement) bool {
+	_, ok := stmt.(*ast.IfStatement)
+	return ok
+}
+
+// forStatement unrolls a loop over a constant iterable, or keeps a residual @FOR.
+func (p *partialEvaluator) forStatement(stmt *ast.ForStatement) []ast.Statement {
+	iterable := p.fold(stmt.Iterable)
+	if val, ok := constant(iterable); ok {
+		items, isArray := val.([]any)
+		if !isArray {
+			p.report(compileError.NewEvaluationError(stmt.Iterable, "iterable must be an array"))
+			return nil
+		}
+		if len(items) > p.evaluator.MaxIterations {
+			p.report(compileError.NewEvaluationError(stmt.Iterable,
+				fmt.Sprintf("for loop exceeded maximum iteration limit (%d)", p.evaluator.MaxIterations)))
+			return nil
+		}
+		var out []ast.Statement
+		for _, item := range items {
+			if err := p.evaluator.Iterate(stmt); err != nil {
+				p.report(err)
+				return nil
+			}
+			previous := p.scope
+			p.scope = scope.New(previous)
+			p.scope.Define(stmt.Target.Lexeme, item)
+			out = append(out, p.spliced(stmt.Body)...)
+			p.scope = previous
+		}
+		return out
+	}
+
+	// the body may run any number of times: whatever it assigns outside is unknown even in its first pass
+	ast.Inspect(stmt.Body, func(n ast.Node) bool {
+		if assignment, ok := n.(*ast.AssignmentExpression); ok {
+			if owner := p.scope.Owner(assignment.Name.Lexeme); owner != nil {
+				owner.Define(assignment.Name.Lexeme, unknown)
+			}
+		}
+		return true
+	})
+	residual := &ast.ForStatement{Open: stmt.Open, Target: stmt.Target, Iterable: iterable, Close: stmt.Close}
+	p.residually(func() {
+		p.scope.Define(stmt.Target.Lexeme, unknown)
+		residual.Body = &ast.BlockStatement{Open: stmt.Body.Open, Statements: p.block(stmt.Body), Close: stmt.Body.Close}
+	})
+	return []ast.Statement{residual}
+}
+
+// template folds the ${call(...)} references of stmt; only those that fold to a constant are expanded.
+func (p *partialEvaluator) template(stmt *ast.DockerStatement) func(ref interpolate.Reference) (any, bool, error) {
+	return func(ref interpolate.Reference) (any, bool, error) {
+		expr, err := parser.ParseReference(stmt, ref)
+		if err != nil {
+			return nil, false, err
+		}
+		val, ok := constant(p.fold(expr))
+		return val, ok, nil
+	}
+}
+
+// lookup returns the value of name if it is known.
+func (p *partialEvaluator) lookup(name string) (any, bool) {
+	val, ok := p.scope.Lookup(name)
+	if !ok || val == unknown {
+		return nil, false
+	}
+	return val, true
+}
+
+// assign records an assignment. Inside a residual region, outer bindings become unknown.
+func (p *partialEvaluator) assign(name token.Token, val any, known bool) {
+	owner := p.scope.Owner(name.Lexeme)
+	if owner == nil {
+		return // an unbound parameter stays unknown
+	}
+	if !known || !p.certain(owner) {
+		val = unknown
+	}
+	owner.Define(name.Lexeme, val)
+}
+
+// certain reports whether owner lies inside the innermost residual region (or there is none).
+func (p *partialEvaluator) certain(owner *scope.Scope) bool {
+	if p.boundary == nil {
+		return true
+	}
+	for s := p.scope; s != p.boundary.Enclosing; s = s.Enclosing {
+		if s == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// prune drops declarations with a known value that nothing after them in statements refers to.
+// Their uses have all been folded, so the declaration no longer affects the program.
+func prune(statements []ast.Statement) []ast.Statement {
+	var out []ast.Statement
+	for i, stmt := range statements {
+		if decl, ok := stmt.(*ast.VariableDeclarationStatement); ok {
+			if _, known := constant(decl.Initializer); (known || decl.Initializer == nil) && !referenced(decl.Name.Lexeme, statements[i+1:]) {
+				continue
+			}
+		}
+		out = append(out, stmt)
+	}
+	return out
+}
+
+// referenced reports whether any statement mentions name in an expression or a ${name} reference.
+func referenced(name string, statements []ast.Statement) bool {
+	found := false
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.VariableExpression:
+			found = found || n.Name.Lexeme == name
+		case *ast.AssignmentExpression:
+			found = found || n.Name.Lexeme == name
+		case *ast.DockerStatement:
+			for _, ref := range interpolate.References(n.Args) {
+				if ref.Expression == "" {
+					found = found || ref.Name == name
+				} else if expr, err := parser.ParseReference(n, ref); err == nil {
+					ast.Inspect(expr, visit)
+				}
+			}
+		}
+		return !found
+	}
+	for _, stmt := range statements {
+		ast.Inspect(stmt, visit)
+	}
+	return found
+}
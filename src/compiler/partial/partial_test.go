@@ -0,0 +1,291 @@
+package partial
+
+import (
+	"strings"
+	"testing"
+
+	"docklett/compiler/ast"
+	"docklett/compiler/evaluator"
+	"docklett/compiler/format"
+	"docklett/compiler/parser"
+	"docklett/compiler/scanner"
+	"docklett/compiler/translator"
+)
+
+func parseSource(t *testing.T, source string) []ast.Statement {
+	t.Helper()
+
+	s := scanner.Scanner{SourceName: "test.dock", Source: source}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan source: %v", err)
+	}
+	var p parser.Parser
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return statements
+}
+
+func lines(text ...string) string {
+	return strings.Join(text, "\n") + "\n"
+}
+
+func TestEvaluate_Residual(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		known  map[string]any
+		want   string
+	}{
+		{
+			"known bindings fold away",
+			lines(`@SET BASE = "alpine"`, `@SET VER = "3." + "19"`, `FROM ${BASE}:${VER}`),
+			nil,
+			lines(`FROM alpine:3.19`),
+		},
+		{
+			"unknown condition stays",
+			lines(`@SET PKG = "curl"`, `@IF MODE == "prod"`, `RUN apk add ${PKG}`, `@ELIF 1 > 2`, `RUN echo never`, `@ELSE`, `RUN echo ${MODE}`, `@END`),
+			nil,
+			lines(`@IF MODE == "prod"`, `    RUN apk add curl`, `@ELSE`, `    RUN echo ${MODE}`, `@END`),
+		},
+		{
+			"known parameter selects branch",
+			lines(`@IF MODE == "prod"`, `RUN echo prod`, `@ELIF MODE == "dev"`, `RUN echo dev`, `@END`),
+			map[string]any{"MODE": "dev"},
+			lines(`RUN echo dev`),
+		},
+		{
+			"constant condition with residual elif",
+			lines(`@IF FALSE`, `RUN echo a`, `@ELIF ARCH == "arm64"`, `RUN echo b`, `@END`),
+			nil,
+			lines(`@IF ARCH == "arm64"`, `    RUN echo b`, `@END`),
+		},
+		{
+			"loop over constant array is unrolled",
+			lines(`@FOR p IN ["curl", "git"]`, `RUN apk add ${p}`, `@END`),
+			nil,
+			lines(`RUN apk add curl`, `RUN apk add git`),
+		},
+		{
+			"loop over range with known bound",
+			lines(`@FOR i IN range(0, N)`, `RUN echo ${i}`, `@END`),
+			map[string]any{"N": 2},
+			lines(`RUN echo 0`, `RUN echo 1`),
+		},
+		{
+			"loop over unknown iterable stays",
+			lines(`@SET n = 0`, `@FOR p IN PKGS`, `RUN echo ${n} ${p}`, `n = n + 1`, `@END`, `RUN echo ${n}`),
+			nil,
+			lines(`@SET n = 0`, `@FOR p IN PKGS`, `    RUN echo ${n} ${p}`, `    n = n + 1`, `@END`, `RUN echo ${n}`),
+		},
+		{
+			"assignment in residual branch makes variable unknown",
+			lines(`@SET TAG = "latest"`, `@IF PIN`, `TAG = "1.0"`, `@END`, `FROM alpine:${TAG}`),
+			nil,
+			lines(`@SET TAG = "latest"`, `@IF PIN`, `    TAG = "1.0"`, `@END`, `FROM alpine:${TAG}`),
+		},
+		{
+			"short circuit",
+			lines(`@IF FALSE && MODE`, `RUN echo a`, `@END`, `@IF TRUE && MODE`, `RUN echo b`, `@END`),
+			nil,
+			lines(`@IF MODE`, `    RUN echo b`, `@END`),
+		},
+		{
+			"partially folded condition",
+			lines(`@SET MIN = 2`, `@IF VERSION >= MIN * 3 && 1 < 2`, `@END`),
+			nil,
+			lines(`@IF VERSION >= 6 && TRUE`, `@END`),
+		},
+		{
+			"calls fold in conditions and templates",
+			lines(`@SET NAME = "api"`, `@SET TAG = upper(NAME) + "-" + SUFFIX`, `@IF startswith(NAME, "a")`, `LABEL n=${upper(NAME)} t=${lower(TAG)}`, `@END`),
+			nil,
+			lines(`@SET TAG = "API-" + SUFFIX`, `LABEL n=API t=${lower(TAG)}`),
+		},
+		{
+			"versions fold and print as semver calls",
+			lines(`@SET NODE = semver("v20.1.0")`, `@SET V = semver(IMAGE_VERSION)`, `@IF V > NODE`, `RUN echo ${NODE.major}`, `@END`),
+			nil,
+			lines(`@SET V = semver(IMAGE_VERSION)`, `@IF V > semver("20.1.0")`, `    RUN echo 20`, `@END`),
+		},
+		{
+			"filesystem calls stay residual",
+			lines(`@SET LOCK = "package-lock.json"`, `@IF file_exists(LOCK)`, `RUN npm ci`, `@END`),
+			nil,
+			lines(`@IF file_exists("package-lock.json")`, `    RUN npm ci`, `@END`),
+		},
+		{
+			"comprehensions fold when closed",
+			lines(`@SET p = "x"`, `@SET L = [p + "-dev" for p in ["a", "b"] if p != "b"]`, `@SET M = [p + S for p in ["a"]]`, `@SET N = [q for q in PKGS]`, `RUN echo ${L} ${M} ${N}`),
+			nil,
+			// like block shadowing, pruning does not look through the target p of M
+			lines(`@SET p = "x"`, `@SET M = [p + S for p in ["a"]]`, `@SET N = [q for q in PKGS]`, `RUN echo a-dev ${M} ${N}`),
+		},
+		{
+			"optional references",
+			lines(`@SET NOTHING`, `@SET A = NOTHING ?? "x"`, `@SET B = MODE ?? "dev"`, `@DEFAULT CUDA = "12.2"`,
+				`@IF defined(CUDA) && defined(GPU)`, `RUN echo ${A} ${B} ${CUDA ?? "none"}`, `@END`),
+			nil,
+			lines(`@SET B = MODE ?? "dev"`, `@DEFAULT CUDA = "12.2"`, `@IF defined(GPU)`, `    RUN echo x ${B} ${CUDA ?? "none"}`, `@END`),
+		},
+		{
+			"defaults stay unless given",
+			lines(`@DEFAULT MODE = "dev"`, `@DEFAULT PORT = 80`, `@IF MODE == "prod"`, `EXPOSE ${PORT}`, `@END`),
+			map[string]any{"PORT": 8080},
+			lines(`@DEFAULT MODE = "dev"`, `@IF MODE == "prod"`, `    EXPOSE 8080`, `@END`),
+		},
+		{
+			"taken block with residual declaration stays scoped",
+			lines(`@SET X = "outer"`, `@IF TRUE`, `@SET X = ARG`, `RUN echo ${X}`, `@END`, `RUN echo ${X}`),
+			nil,
+			// the outer declaration is kept: pruning does not look through the inner shadowing
+			lines(`@SET X = "outer"`, `@IF TRUE`, `    @SET X = ARG`, `    RUN echo ${X}`, `@END`, `RUN echo outer`),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			residual, err := Evaluate(parseSource(t, test.source), test.known, evaluator.Limits{})
+			if err != nil {
+				t.Fatalf("evaluate: %v", err)
+			}
+			if got := format.Statements(residual); got != test.want {
+				t.Errorf("residual program:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_ReportsErrorsInKnownCode(t *testing.T) {
+	_, err := Evaluate(parseSource(t, lines(`@SET x = "a" - 1`, `@FOR i IN range(0, 3, 0)`, `@END`)), nil, evaluator.Limits{})
+	if err == nil || !strings.Contains(err.Error(), "step cannot be zero") {
+		t.Errorf("error = %v, want the evaluation errors", err)
+	}
+}
+
+func TestEvaluate_DoesNotModifyInput(t *testing.T) {
+	source := lines(`@SET A = 1`, `@IF MODE`, `RUN echo ${A}`, `@END`)
+	statements := parseSource(t, source)
+	before := format.Statements(statements)
+	if _, err := Evaluate(statements, nil, evaluator.Limits{}); err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if after := format.Statements(statements); after != before {
+		t.Errorf("input changed:\n%s\nwant:\n%s", after, before)
+	}
+}
+
+// translate compiles statements with vars and returns the instructions, one per line.
+func translate(t *testing.T, statements []ast.Statement, vars map[string]any) string {
+	t.Helper()
+	tr := translator.NewTranslator()
+	tr.SetVars(vars)
+	if err := tr.Translate(statements); err != nil {
+		t.Fatalf("translate: %v", err)
+	}
+	var out strings.Builder
+	for _, instruction := range tr.Instructions() {
+		out.WriteString(instruction.String() + "\n")
+	}
+	return out.String()
+}
+
+func TestEvaluate_ResidualParsesBack(t *testing.T) {
+	quote := map[string]any{"QUOTE": `say "hi"`}
+	tests := []struct {
+		name   string
+		source string
+		known  map[string]any
+		modes  []any
+	}{
+		{
+			"string with a double quote",
+			lines(`@SET MSG = QUOTE + "!"`, `@IF MODE == MSG`, `RUN echo yes`, `@END`, `RUN echo ${MSG}`),
+			quote,
+			[]any{`say "hi"!`, "prod"},
+		},
+		{
+			"array with a double quote",
+			lines(`@SET TAGS = [QUOTE, "x"]`, `@IF MODE in TAGS`, `RUN echo ${len(TAGS)}`, `@END`),
+			quote,
+			[]any{`say "hi"`, "x", "y"},
+		},
+		{
+			"known @DEFAULT with a double quote",
+			lines(`@DEFAULT QUOTE = "plain"`, `@IF MODE == QUOTE`, `RUN echo match`, `@END`),
+			quote,
+			[]any{`say "hi"`, "plain"},
+		},
+		{
+			"string with a newline",
+			lines(`@SET X = "a`, `b"`, `@IF MODE == X + "c"`, `RUN echo newline`, `@END`),
+			nil,
+			[]any{"a\nbc", "abc"},
+		},
+		{
+			"whole float",
+			lines(`@SET HALF = 6 / 2`, `@IF MODE == HALF`, `RUN echo float`, `@ELIF MODE == 3`, `RUN echo int`, `@END`),
+			nil,
+			[]any{3.0, 3},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			original := parseSource(t, test.source)
+			residual, err := Evaluate(original, test.known, evaluator.Limits{})
+			if err != nil {
+				t.Fatalf("evaluate: %v", err)
+			}
+			source := format.Statements(residual)
+			reparsed := parseSource(t, source)
+			for _, mode := range test.modes {
+				vars := map[string]any{"MODE": mode}
+				for name, val := range test.known {
+					vars[name] = val
+				}
+				if got, want := translate(t, reparsed, vars), translate(t, original, vars); got != want {
+					t.Errorf("MODE = %#v: residual program\n%s\ncompiles to\n%s\nwant\n%s", mode, source, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluate_Limits(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		limits  evaluator.Limits
+		message string
+	}{
+		{
+			"unrolled items in total",
+			lines(`@FOR i IN range(0, 50)`, `@FOR j IN range(0, 50)`, `RUN echo ${i} ${j}`, `@END`, `@END`),
+			evaluator.Limits{MaxTotalIterations: 1000},
+			"limit of 1000 loop iterations in total",
+		},
+		{
+			"folded collection",
+			lines(`@IF len(range(0, 200000000)) > 0`, `RUN echo big`, `@END`),
+			evaluator.SandboxLimits,
+			"100000",
+		},
+		{
+			"folded string",
+			lines(`@SET S = "ab"`, `@FOR i IN range(0, 20)`, `S = S + S`, `@END`, `RUN echo ${len(S)}`),
+			evaluator.Limits{MaxStringLength: 1 << 10},
+			"1024",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Evaluate(parseSource(t, test.source), nil, test.limits)
+			if err == nil || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("error = %v, want one mentioning %q", err, test.message)
+			}
+		})
+	}
+}
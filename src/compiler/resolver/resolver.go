@@ -0,0 +1,384 @@
+/*
+Package resolver implements a static pass that runs after parsing and before evaluation.
+
+The Translator only evaluates the branch an @IF condition selects, so a misspelled name in the
+"prod" branch is invisible to every "dev" build. The resolver walks EVERY branch and loop body,
+regardless of condition values, and binds each variable reference to its declaration:
+
+	@SET MODE = "dev"             declaration (depth 0)
+	@IF MODE == "prod"            MODE → line 1
+	    RUN echo ${MODE}
+	    @SET TAG = VERSON         error: undefined variable 'VERSON'
+	@END
+	@FOR pkg IN PKGS              error: undefined variable 'PKGS'
+	    RUN apk add ${pkg}
+	@END
+
+SCOPES mirror the ones the Translator creates at evaluation time:
+  - every BlockStatement (@IF/@ELIF/@ELSE/@FOR bodies) opens a child scope
+  - a @FOR target lives in its own loop scope around the body
+  - comprehension targets live in a scope around the element and filter, like a @FOR target
+  - declarations are visible from the statement after them; an initializer cannot see its own name
+
+OPTIONAL references are the left operand of ?? and the argument of defined(NAME). They are bound when
+the name is declared and are never undefined-variable errors:
+
+	@SET CUDA = CUDA_VERSION ?? "12.2"    fine when no CUDA_VERSION is declared
+
+REDEFINITIONS follow the rules of scope.Declare, checked in every branch, not only the taken ones:
+a @CONST can never be redeclared, assigned or shadowed by a loop variable, a @CONST cannot replace a
+binding of the same scope, and with ResolveStrict neither can any other declaration:
+
+	@CONST BASE = "alpine"
+	@IF FALSE
+	    @SET BASE = "x"           error: cannot redefine constant 'BASE'
+	@END
+
+Docker argument references (${name}) are not checked: unresolved ones are left for the container engine.
+Expression references (${upper(NAME)}) are resolved in full, since Docklett evaluates them.
+Resolved ones are recorded as references of their declaration, so later passes can tell a variable that is
+only interpolated from one that is never used.
+*/
+package resolver
+
+import (
+	"docklett/compiler/ast"
+	"docklett/compiler/builtin"
+	compileError "docklett/compiler/error"
+	"docklett/compiler/interpolate"
+	"docklett/compiler/parser"
+	"docklett/compiler/token"
+	"errors"
+	"fmt"
+)
+
+// Compile-time check to ensure Resolver implements both visitors
+var _ ast.StatementVisitor = (*Resolver)(nil)
+var _ ast.ExpressionVisitor = (*Resolver)(nil)
+
+// Kind classifies how a name was declared.
+type Kind int
+
+const (
+	Variable     Kind = iota // @SET
+	Constant                 // @CONST
+	Default                  // @DEFAULT, a build variable
+	LoopVariable             // @FOR target
+	Predeclared              // bound before the file runs, e.g. by the caller
+)
+
+// Declaration is one binding found by the resolver.
+type Declaration struct {
+	Name       token.Token // identifier that introduced the binding (zero for predeclared names)
+	Kind       Kind
+	Depth      int        // scope depth: 0 is the global scope
+	References []ast.Node // every VariableExpression, AssignmentExpression and interpolating DockerStatement, in source order
+}
+
+// Resolution is the result of resolving a program.
+type Resolution struct {
+	// Bindings maps each *ast.VariableExpression and *ast.AssignmentExpression to its declaration.
+	Bindings map[ast.Node]*Declaration
+	// Declarations lists every declaration in source order.
+	Declarations []*Declaration
+	// Predeclared maps the names given to Resolve to their declarations, which collect references
+	// like any other; a @DEFAULT of the same name replaces the predeclared binding from then on.
+	Predeclared map[string]*Declaration
+}
+
+type Resolver struct {
+	scopes     []map[string]*Declaration // innermost scope last
+	strict     bool                      // redeclaring a name in the same scope is an error
+	resolution *Resolution
+	errors     []error
+}
+
+// Resolve binds every variable reference in statements. Names listed in predeclared are treated
+// as global bindings that exist before the file runs. All undefined references and redefinitions
+// of constants are reported, joined.
+func Resolve(statements []ast.Statement, predeclared ...string) (*Resolution, error) {
+	return resolve(statements, false, predeclared)
+}
+
+// ResolveStrict resolves like Resolve and also reports a declaration of a name that is already
+// declared in the same scope, like the translator's strict mode.
+func ResolveStrict(statements []ast.Statement, predeclared ...string) (*Resolution, error) {
+	return resolve(statements, true, predeclared)
+}
+
+func resolve(statements []ast.Statement, strict bool, predeclared []string) (*Resolution, error) {
+	r := &Resolver{strict: strict, resolution: &Resolution{Bindings: make(map[ast.Node]*Declaration), Predeclared: make(map[string]*Declaration)}}
+	r.beginScope()
+	for _, name := range predeclared {
+		r.declare(token.Token{Type: token.IDENTIFIER, Lexeme: name}, Predeclared)
+	}
+	r.resolveStatements(statements)
+	r.endScope()
+	return r.resolution, errors.Join(r.errors...)
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, make(map[string]*Declaration))
+}
+
+func (r *Resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *Resolver) declare(name token.Token, kind Kind) {
+	decl := &Declaration{Name: name, Kind: kind, Depth: len(r.scopes) - 1}
+	r.scopes[len(r.scopes)-1][name.Lexeme] = decl
+	if kind == Predeclared {
+		r.resolution.Predeclared[name.Lexeme] = decl
+	} else {
+		r.resolution.Declarations = append(r.resolution.Declarations, decl)
+	}
+}
+
+// lookup finds the declaration of name from the innermost scope outwards.
+func (r *Resolver) lookup(name string) *Declaration {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if decl, ok := r.scopes[i][name]; ok {
+			return decl
+		}
+	}
+	return nil
+}
+
+// constant returns the declaration of the constant called name, searching every scope.
+func (r *Resolver) constant(name string) *Declaration {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if decl, ok := r.scopes[i][name]; ok && decl.Kind == Constant {
+			return decl
+		}
+	}
+	return nil
+}
+
+// checkRedeclaration reports a declaration that scope.Declare would reject: one of a constant's name,
+// or one that replaces a binding of the same scope when it declares a constant or in strict mode.
+// Predeclared names are not bindings of the file, so a @DEFAULT of one is never a redefinition.
+func (r *Resolver) checkRedeclaration(name token.Token, constant bool) {
+	if previous := r.constant(name.Lexeme); previous != nil {
+		r.errors = append(r.errors, compileError.NewRedefinitionError(name, previous.Name,
+			fmt.Sprintf("cannot redefine constant '%s'", name.Lexeme)))
+		return
+	}
+	previous, ok := r.scopes[len(r.scopes)-1][name.Lexeme]
+	if ok && previous.Kind != Predeclared && (constant || r.strict) {
+		r.errors = append(r.errors, compileError.NewRedefinitionError(name, previous.Name,
+			fmt.Sprintf("'%s' is already declared in this scope", name.Lexeme)))
+	}
+}
+
+// checkShadowing reports a loop variable that would shadow a constant.
+func (r *Resolver) checkShadowing(target token.Token) {
+	if previous := r.constant(target.Lexeme); previous != nil {
+		r.errors = append(r.errors, compileError.NewRedefinitionError(target, previous.Name,
+			fmt.Sprintf("loop variable cannot shadow constant '%s'", target.Lexeme)))
+	}
+}
+
+// bind resolves name and records the reference.
+func (r *Resolver) bind(node ast.Node, name token.Token) {
+	decl := r.lookup(name.Lexeme)
+	if decl == nil {
+		r.errors = append(r.errors, compileError.NewUndefinedVariableError(name))
+		return
+	}
+	r.resolution.Bindings[node] = decl
+	decl.References = append(decl.References, node)
+}
+
+// bindOptional resolves expr, except that a variable reference to an undeclared name is not an error.
+func (r *Resolver) bindOptional(expr ast.Expression) {
+	variable, ok := expr.(*ast.VariableExpression)
+	if !ok {
+		r.resolveExpression(expr)
+		return
+	}
+	if decl := r.lookup(variable.Name.Lexeme); decl != nil {
+		r.resolution.Bindings[variable] = decl
+		decl.References = append(decl.References, variable)
+	}
+}
+
+func (r *Resolver) resolveStatements(statements []ast.Statement) {
+	for _, stmt := range statements {
+		stmt.Accept(r)
+	}
+}
+
+func (r *Resolver) resolveExpression(expr ast.Expression) {
+	if expr != nil {
+		expr.Accept(r)
+	}
+}
+
+func (r *Resolver) VisitExpressionStatement(stmt *ast.ExpressionStatement) (any, error) {
+	r.resolveExpression(stmt.Expression)
+	return nil, nil
+}
+
+// VisitVarDeclarationStatement resolves the initializer first, so "@SET x = x + 1" refers to an outer x.
+func (r *Resolver) VisitVarDeclarationStatement(stmt *ast.VariableDeclarationStatement) (any, error) {
+	r.resolveExpression(stmt.Initializer)
+	r.checkRedeclaration(stmt.Name, stmt.IsConstant())
+	kind := Variable
+	if stmt.IsConstant() {
+		kind = Constant
+	} else if stmt.IsDefault() {
+		kind = Default
+	}
+	r.declare(stmt.Name, kind)
+	return nil, nil
+}
+
+func (r *Resolver) VisitBlockStatement(stmt *ast.BlockStatement) (any, error) {
+	r.beginScope()
+	r.resolveStatements(stmt.Statements)
+	r.endScope()
+	return nil, nil
+}
+
+// VisitIfStatement resolves the condition and every branch, whatever the condition's value.
+func (r *Resolver) VisitIfStatement(stmt *ast.IfStatement) (any, error) {
+	r.resolveExpression(stmt.Condition)
+	stmt.ThenBranch.Accept(r)
+	if stmt.ElseBranch != nil {
+		stmt.ElseBranch.Accept(r)
+	}
+	return nil, nil
+}
+
+// VisitDockerStatement records ${name} references to declared names; unresolved ones are not errors.
+// A ${call(...)} expression is evaluated by Docklett, so it is resolved like any other expression.
+func (r *Resolver) VisitDockerStatement(stmt *ast.DockerStatement) (any, error) {
+	for _, ref := range interpolate.References(stmt.Args) {
+		if ref.Expression != "" {
+			expr, err := parser.ParseReference(stmt, ref)
+			if err != nil {
+				r.errors = append(r.errors, err)
+				continue
+			}
+			r.resolveExpression(expr)
+			continue
+		}
+		if decl := r.lookup(ref.Name); decl != nil {
+			decl.References = append(decl.References, stmt)
+		}
+	}
+	return nil, nil
+}
+
+// VisitForStatement resolves the iterable outside the loop, then the body with the target in a loop scope.
+func (r *Resolver) VisitForStatement(stmt *ast.ForStatement) (any, error) {
+	r.resolveExpression(stmt.Iterable)
+	r.checkShadowing(stmt.Target)
+	r.beginScope()
+	r.declare(stmt.Target, LoopVariable)
+	stmt.Body.Accept(r)
+	r.endScope()
+	return nil, nil
+}
+
+func (r *Resolver) VisitVariableExpr(variable *ast.VariableExpression) (any, error) {
+	r.bind(variable, variable.Name)
+	return nil, nil
+}
+
+func (r *Resolver) VisitAssignmentExpr(assignment *ast.AssignmentExpression) (any, error) {
+	r.resolveExpression(assignment.Value)
+	if previous := r.constant(assignment.Name.Lexeme); previous != nil {
+		r.errors = append(r.errors, compileError.NewRedefinitionError(assignment.Name, previous.Name,
+			fmt.Sprintf("cannot assign to constant '%s'", assignment.Name.Lexeme)))
+	}
+	r.bind(assignment, assignment.Name)
+	return nil, nil
+}
+
+func (r *Resolver) VisitLiteralExpr(literal *ast.LiteralExpression) (any, error) {
+	return nil, nil
+}
+
+func (r *Resolver) VisitUnaryExpr(unary *ast.UnaryExpression) (any, error) {
+	r.resolveExpression(unary.Right)
+	return nil, nil
+}
+
+func (r *Resolver) VisitGroupingExpr(grouping *ast.GroupingExpression) (any, error) {
+	r.resolveExpression(grouping.Expression)
+	return nil, nil
+}
+
+func (r *Resolver) VisitBinaryExpr(binary *ast.BinaryExpression) (any, error) {
+	r.resolveExpression(binary.Left)
+	r.resolveExpression(binary.Right)
+	return nil, nil
+}
+
+func (r *Resolver) VisitLogicalExpr(logical *ast.LogicalExpression) (any, error) {
+	if logical.Operator.Type == token.COALESCE {
+		r.bindOptional(logical.Left)
+	} else {
+		r.resolveExpression(logical.Left)
+	}
+	r.resolveExpression(logical.Right)
+	return nil, nil
+}
+
+func (r *Resolver) VisitArrayLiteralExpr(array *ast.ArrayLiteralExpression) (any, error) {
+	for _, elem := range array.Elements {
+		r.resolveExpression(elem)
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitMapLiteralExpr(mapLiteral *ast.MapLiteralExpression) (any, error) {
+	for i := range mapLiteral.Keys {
+		r.resolveExpression(mapLiteral.Keys[i])
+		r.resolveExpression(mapLiteral.Values[i])
+	}
+	return nil, nil
+}
+
+// VisitComprehensionExpr resolves the iterable outside the comprehension, then the filter and element
+// with the targets in a scope of their own.
+func (r *Resolver) VisitComprehensionExpr(comprehension *ast.ComprehensionExpression) (any, error) {
+	r.resolveExpression(comprehension.Iterable)
+	r.beginScope()
+	for _, target := range comprehension.Targets {
+		r.checkShadowing(target)
+		r.declare(target, LoopVariable)
+	}
+	r.resolveExpression(comprehension.Filter)
+	r.resolveExpression(comprehension.Element)
+	r.endScope()
+	return nil, nil
+}
+
+func (r *Resolver) VisitRangeExpr(rangeExpr *ast.RangeExpression) (any, error) {
+	r.resolveExpression(rangeExpr.Start)
+	r.resolveExpression(rangeExpr.Stop)
+	r.resolveExpression(rangeExpr.Step)
+	return nil, nil
+}
+
+// VisitCallExpr resolves the arguments. Function names live apart from variables: the type checker
+// and the evaluator report unknown functions.
+func (r *Resolver) VisitCallExpr(call *ast.CallExpression) (any, error) {
+	f, ok := builtin.Lookup(call.Callee.Lexeme)
+	for _, arg := range call.Arguments {
+		if ok && f.ByName {
+			r.bindOptional(arg)
+		} else {
+			r.resolveExpression(arg)
+		}
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitMemberExpr(member *ast.MemberExpression) (any, error) {
+	r.resolveExpression(member.Object)
+	return nil, nil
+}
@@ -0,0 +1,238 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"docklett/compiler/ast"
+	compileError "docklett/compiler/error"
+	"docklett/compiler/parser"
+	"docklett/compiler/scanner"
+)
+
+func parseSource(t *testing.T, source string) []ast.Statement {
+	t.Helper()
+
+	s := scanner.Scanner{SourceName: "test.dock", Source: source}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan source: %v", err)
+	}
+	var p parser.Parser
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return statements
+}
+
+// undefinedNames returns "name@line" for every undefined variable error, in order.
+func undefinedNames(t *testing.T, err error) []string {
+	t.Helper()
+
+	var names []string
+	if err == nil {
+		return names
+	}
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var undefined *compileError.UndefinedVariableError
+		if !errors.As(e, &undefined) {
+			t.Fatalf("error = %v, want *UndefinedVariableError", e)
+		}
+		names = append(names, fmt.Sprintf("%s@%d", undefined.Name.Lexeme, undefined.Name.Line))
+	}
+	return names
+}
+
+func TestResolve_ReportsUndefinedInEveryBranch(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"declared before use", "@SET A = 1\n@SET B = A\n", nil},
+		{"use before declaration", "@SET B = A\n@SET A = 1\n", []string{"A@1"}},
+		{"own initializer", "@SET A = A + 1\n", []string{"A@1"}},
+		{
+			"untaken branches",
+			"@SET MODE = \"dev\"\n@IF MODE == \"prod\"\n@SET X = PRODTAG\n@ELIF FALSE\n@SET Y = OTHER\n@ELSE\nZ = 1\n@END\n",
+			[]string{"PRODTAG@3", "OTHER@5", "Z@7"},
+		},
+		{
+			"block declarations do not leak",
+			"@IF TRUE\n@SET INNER = 1\n@END\n@SET OUTER = INNER\n",
+			[]string{"INNER@4"},
+		},
+		{
+			"loop target is scoped to the loop",
+			"@FOR i IN range(0, 3)\n@SET j = i\n@END\n@SET k = i\n",
+			[]string{"i@4"},
+		},
+		{"loop iterable is resolved outside the loop", "@FOR i IN [i]\n@END\n", []string{"i@1"}},
+		{"inner scope sees outer bindings", "@SET A = 1\n@FOR i IN [A]\n@IF i\nA = i\n@END\n@END\n", nil},
+		{"comprehension targets", "@SET A = [1]\n@SET B = [k + v for k, v in A if k > 0]\n@SET C = k\n", []string{"k@3"}},
+		{"comprehension iterable is resolved outside", "@SET B = [x for x in x]\n", []string{"x@1"}},
+		{
+			"optional references",
+			"@SET A = CUDA ?? ROCM ?? 1\n@IF defined(GPU)\n@END\n@SET B = (X) ?? 1\n@SET C = 1 ?? Y\n",
+			[]string{"X@4", "Y@5"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Resolve(parseSource(t, tc.source))
+			got := undefinedNames(t, err)
+			if len(got) != len(tc.want) {
+				t.Fatalf("undefined = %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("undefined[%d] = %s, want %s", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResolve_BindsReferencesToDeclarations(t *testing.T) {
+	statements := parseSource(t, "@SET x = 1\n@IF TRUE\n@SET x = x\nx = 2\n@END\n")
+	resolution, err := Resolve(statements)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	outer := resolution.Declarations[0]
+	inner := resolution.Declarations[1]
+	if outer.Depth != 0 || inner.Depth != 1 {
+		t.Fatalf("depths = %d, %d, want 0, 1", outer.Depth, inner.Depth)
+	}
+
+	block := statements[1].(*ast.IfStatement).ThenBranch
+	initializer := block.Statements[0].(*ast.VariableDeclarationStatement).Initializer
+	assignment := block.Statements[1].(*ast.ExpressionStatement).Expression
+
+	if resolution.Bindings[initializer] != outer {
+		t.Errorf("initializer of inner x should refer to the outer x")
+	}
+	if resolution.Bindings[assignment] != inner {
+		t.Errorf("assignment should refer to the inner x")
+	}
+	if len(outer.References) != 1 || len(inner.References) != 1 {
+		t.Errorf("references = %d, %d, want 1, 1", len(outer.References), len(inner.References))
+	}
+}
+
+func TestResolve_Predeclared(t *testing.T) {
+	if _, err := Resolve(parseSource(t, "@IF MODE == \"prod\"\n@END\n"), "MODE"); err != nil {
+		t.Errorf("predeclared MODE reported: %v", err)
+	}
+}
+
+func TestResolve_PredeclaredReferencesAndDefaults(t *testing.T) {
+	source := "RUN echo ${TAG}\n@IF MODE == \"prod\"\n@END\n@DEFAULT MODE = \"dev\"\nRUN echo ${MODE}\n"
+	resolution, err := Resolve(parseSource(t, source), "MODE", "TAG", "UNUSED")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	for name, want := range map[string]int{"MODE": 1, "TAG": 1, "UNUSED": 0} {
+		if got := len(resolution.Predeclared[name].References); got != want {
+			t.Errorf("%s: %d references, want %d", name, got, want)
+		}
+	}
+	// the read after the @DEFAULT binds to the default, not to the predeclared name
+	decl := resolution.Declarations[0]
+	if decl.Kind != Default || decl.Name.Lexeme != "MODE" || len(decl.References) != 1 {
+		t.Errorf("declaration = %+v, want @DEFAULT MODE with one reference", decl)
+	}
+}
+
+func TestResolve_RecordsDockerReferences(t *testing.T) {
+	statements := parseSource(t, "@SET TAG = \"1\"\n@FOR p IN [\"a\"]\nRUN echo ${p}:${TAG} ${UNKNOWN}\n@END\n")
+	resolution, err := Resolve(statements)
+	if err != nil {
+		t.Fatalf("unresolved Docker reference reported: %v", err)
+	}
+
+	run := statements[1].(*ast.ForStatement).Body.Statements[0]
+	for _, decl := range resolution.Declarations {
+		if len(decl.References) != 1 || decl.References[0] != run {
+			t.Errorf("%s references = %v, want the RUN statement", decl.Name.Lexeme, decl.References)
+		}
+	}
+}
+
+func TestResolve_OptionalReferencesBindDeclaredNames(t *testing.T) {
+	statements := parseSource(t, "@SET CUDA = \"12.2\"\n@SET A = CUDA ?? \"x\"\nRUN echo ${defined(CUDA)} ${CUDA ?? \"none\"}\n")
+	resolution, err := Resolve(statements)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if refs := resolution.Declarations[0].References; len(refs) != 3 {
+		t.Errorf("CUDA references = %v, want 3", refs)
+	}
+}
+
+func TestResolve_TemplateExpressions(t *testing.T) {
+	statements := parseSource(t, "@SET NAME = \"api\"\nRUN echo ${upper(NAME)} ${lower(VERSON)} ${HOME}\n")
+	resolution, err := Resolve(statements)
+	if got := undefinedNames(t, err); fmt.Sprint(got) != "[VERSON@2]" {
+		t.Errorf("undefined = %v, want [VERSON@2]", got)
+	}
+	if refs := resolution.Declarations[0].References; len(refs) != 1 {
+		t.Errorf("NAME references = %v, want the template expression", refs)
+	}
+}
+
+// redefinitions returns "name@line:col<line:col" for every redefinition error, pointing from the new
+// name to the earlier declaration.
+func redefinitions(t *testing.T, err error) []string {
+	t.Helper()
+
+	var names []string
+	if err == nil {
+		return names
+	}
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var redefinition *compileError.RedefinitionError
+		if !errors.As(e, &redefinition) {
+			t.Fatalf("error = %v, want *RedefinitionError", e)
+		}
+		names = append(names, fmt.Sprintf("%s@%d:%d<%d:%d", redefinition.Name.Lexeme, redefinition.Name.Line,
+			redefinition.Name.Col, redefinition.Previous.Line, redefinition.Previous.Col))
+	}
+	return names
+}
+
+func TestResolve_RedefinitionsInDeadBranches(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		strict bool
+		want   []string
+	}{
+		{"constant redeclared", "@CONST BASE = \"alpine\"\n@IF FALSE\n@SET BASE = \"x\"\n@END\n", false, []string{"BASE@3:6<1:8"}},
+		{"constant assigned", "@CONST BASE = \"alpine\"\n@IF FALSE\nBASE = \"x\"\n@END\n", false, []string{"BASE@3:1<1:8"}},
+		{"constant shadowed by a loop", "@CONST P = 1\n@IF FALSE\n@FOR P IN [1]\n@END\n@END\n", false, []string{"P@3:6<1:8"}},
+		{"constant shadowed by a comprehension", "@CONST P = 1\n@IF FALSE\n@SET X = [P for P in [1]]\n@END\n", false, []string{"P@3:17<1:8"}},
+		{"constant replaces a variable", "@IF FALSE\n@SET B = 1\n@CONST B = 2\n@END\n", false, []string{"B@3:8<2:6"}},
+		{"variable redeclared", "@IF FALSE\n@SET B = 1\n@SET B = 2\n@END\n", false, nil},
+		{"strict variable redeclared", "@IF FALSE\n@SET B = 1\n@ELSE\n@SET B = 1\n@SET B = 2\n@END\n", true, []string{"B@5:6<4:6"}},
+		{"strict shadowing in a block", "@SET B = 1\n@IF FALSE\n@SET B = 2\n@END\n", true, nil},
+		{"strict default of a build variable", "@DEFAULT MODE = \"dev\"\n", true, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolve := Resolve
+			if test.strict {
+				resolve = ResolveStrict
+			}
+			_, err := resolve(parseSource(t, test.source), "MODE")
+			if got := redefinitions(t, err); fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("redefinitions = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
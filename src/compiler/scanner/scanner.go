@@ -6,6 +6,7 @@ package scanner
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -25,9 +26,23 @@ type Scanner struct {
 	start        int           // first character of current lexeme
 	current      int           // current char in source code
 	line         int           // current line in source code
+	lineStart    int           // index of the first char on the current line
+	startLine    int           // line where the current lexeme begins
+	startCol     int           // column where the current lexeme begins
+	argsLine     int           // line where the pending DOCKER_ARGS begin
+	argsCol      int           // column where the pending DOCKER_ARGS begin
 	Tokens       []token.Token // list of tokens generated
 	docklett     bool          // flag for whether we are using Docklett extensions
 	pendingToken *token.Token  // holds queued DOCKER_ARGS token between scan cycles
+	lexemeEnd    int           // end of the current lexeme when it differs from current (Docker keywords)
+
+	// Lossless keeps comments, blank lines, spacing and continuations as trivia on tokens
+	// instead of discarding them, so the source can be reproduced byte for byte.
+	Lossless     bool
+	trivia       []token.Trivia // trivia waiting for the next token (lossless mode)
+	lineHasToken bool           // whether a token was emitted on the current line (lossless mode)
+	argsLeading  string         // whitespace between a Docker keyword and its args (lossless mode)
+	argsTrailing string         // whitespace trimmed from the end of Docker args (lossless mode)
 }
 
 // Loads a file into the scanner and fills source metadata.
@@ -62,11 +77,15 @@ func (s *Scanner) ScanSource() error {
 		}
 
 		s.start = s.current // begin new lexeme
+		s.startLine = s.line
+		s.startCol = s.column(s.start)
 		tokenType, literal, err := s.scanToken()
 		if err != nil {
 			return err
 		}
 		if tokenType == token.ILLEGAL {
+			// whitespace, comments and continuations produce no token
+			s.addTrivia()
 			continue
 		}
 		s.addToken(tokenType, literal)
@@ -83,28 +102,100 @@ func (s *Scanner) ScanSource() error {
 		Position: token.Position{
 			Line: s.line,
 			File: s.SourceName,
-			Col:  s.current + 1,
+			Col:  s.column(s.current),
 		},
+		LeadingTrivia: s.takeTrivia(),
 	})
 	return nil
 }
 
+// ScanExpression scans Source as a single Docklett expression, such as the inside of a ${...}
+// template, so a leading name is never taken for a Docker instruction (e.g. "env(...)").
+func (s *Scanner) ScanExpression() error {
+	s.docklett = true
+	return s.ScanSource()
+}
+
+// addTrivia records the text of the lexeme just skipped as trivia for the next token.
+// Runs of whitespace are merged into a single trivia piece.
+func (s *Scanner) addTrivia() {
+	if !s.Lossless {
+		return
+	}
+	text, _ := util.ReadSubstring(s.Source, s.start, s.current)
+	if text == "" {
+		return
+	}
+
+	kind := token.WHITESPACE
+	switch text[0] {
+	case '#':
+		kind = token.COMMENT
+	case '\\':
+		kind = token.CONTINUATION
+	case '\n':
+		kind = token.LINE_BREAK
+	}
+
+	if last := len(s.trivia) - 1; kind == token.WHITESPACE && last >= 0 && s.trivia[last].Kind == token.WHITESPACE {
+		s.trivia[last].Text += text
+		return
+	}
+	s.trivia = append(s.trivia, token.Trivia{Kind: kind, Text: text})
+}
+
+// takeTrivia hands over the trivia collected since the last token.
+func (s *Scanner) takeTrivia() []token.Trivia {
+	trivia := s.trivia
+	s.trivia = nil
+	return trivia
+}
+
 func (s *Scanner) isAtEnd() bool {
 	return s.current >= utf8.RuneCountInString(s.Source)
 }
 
 func (s *Scanner) addToken(tokenType token.TokenType, literal any) {
-	lexeme, _ := util.ReadSubstring(s.Source, s.start, s.current)
-	s.Tokens = append(s.Tokens, token.Token{
+	end := s.current
+	if s.lexemeEnd > s.start {
+		end = s.lexemeEnd
+	}
+	s.lexemeEnd = 0
+	lexeme, _ := util.ReadSubstring(s.Source, s.start, end)
+	tok := token.Token{
 		Type:   tokenType,
 		Lexeme: lexeme,
 		Position: token.Position{
-			Line: s.line,
+			Line: s.startLine,
 			File: s.SourceName,
-			Col:  s.start + 1,
+			Col:  s.startCol,
 		},
 		Literal: literal,
-	})
+	}
+
+	if s.Lossless {
+		if tokenType == token.NLINE && len(s.Tokens) > 0 {
+			// spacing and comments after the last token of a line trail that token
+			last := &s.Tokens[len(s.Tokens)-1]
+			last.TrailingTrivia = append(last.TrailingTrivia, s.takeTrivia()...)
+			s.lineHasToken = false
+		} else {
+			tok.LeadingTrivia = s.takeTrivia()
+			s.lineHasToken = true
+		}
+	}
+	s.Tokens = append(s.Tokens, tok)
+}
+
+// column converts a char index on the current line into a 1-based column.
+func (s *Scanner) column(index int) int {
+	return index - s.lineStart + 1
+}
+
+// newLine is called right after a '\n' is consumed to move the line bookkeeping forward.
+func (s *Scanner) newLine() {
+	s.line++
+	s.lineStart = s.current
 }
 
 func (s *Scanner) advanceChar() rune {
@@ -131,8 +222,12 @@ func (s *Scanner) scanToken() (tokenType token.TokenType, literal any, err error
 	case ' ', '\t', '\r':
 		return token.ILLEGAL, nil, nil
 	case '\n':
-		s.line++
+		s.newLine()
 		s.docklett = false
+		// in lossless mode a line without tokens keeps its line break as trivia
+		if s.Lossless && !s.lineHasToken {
+			return token.ILLEGAL, nil, nil
+		}
 		return token.NLINE, nil, nil
 
 	case '=':
@@ -159,7 +254,12 @@ func (s *Scanner) scanToken() (tokenType token.TokenType, literal any, err error
 		if s.nextMatch('=') {
 			return token.DIV_ASSIGN, nil, nil
 		}
+		if s.nextMatch('/') {
+			return token.FLOOR_DIV, nil, nil
+		}
 		return token.DIVIDE, nil, nil
+	case '%':
+		return token.MODULO, nil, nil
 	case '<':
 		if s.nextMatch('=') {
 			return token.LTE, nil, nil
@@ -179,7 +279,17 @@ func (s *Scanner) scanToken() (tokenType token.TokenType, literal any, err error
 		if s.nextMatch('&') {
 			return token.AND, nil, nil
 		}
-		return token.ILLEGAL, nil, compileError.NewScanError(s.line, s.start+1, s.SourceName, "unexpected char: &")
+		return token.ILLEGAL, nil, compileError.NewScanError(s.startLine, s.startCol, s.SourceName, "unexpected char: &")
+	case '|':
+		if s.nextMatch('|') {
+			return token.OR, nil, nil
+		}
+		return token.ILLEGAL, nil, compileError.NewScanError(s.startLine, s.startCol, s.SourceName, "unexpected char: |")
+	case '?':
+		if s.nextMatch('?') {
+			return token.COALESCE, nil, nil
+		}
+		return token.ILLEGAL, nil, compileError.NewScanError(s.startLine, s.startCol, s.SourceName, "unexpected char: ?")
 	case '(':
 		return token.LPAREN, nil, nil
 	case ')':
@@ -196,10 +306,14 @@ func (s *Scanner) scanToken() (tokenType token.TokenType, literal any, err error
 		return token.COLON, nil, nil
 	case ',':
 		return token.COMMA, nil, nil
+	case '.':
+		return token.DOT, nil, nil
 	case '#':
 		// only ignore full line comments for now
 		// inline comments goes into the Docker instruction itself
 		return s.scanComment()
+	case '\\':
+		return s.scanContinuation()
 	case '"':
 		return s.scanStringToken()
 	case '@':
@@ -209,10 +323,10 @@ func (s *Scanner) scanToken() (tokenType token.TokenType, literal any, err error
 		if unicode.IsDigit(lexeme) {
 			return s.scanNumberToken()
 		}
-		if unicode.IsLetter(lexeme) {
+		if unicode.IsLetter(lexeme) || lexeme == '_' {
 			return s.scanKeywordsAndIdentifierTokens()
 		}
-		return token.ILLEGAL, nil, compileError.NewScanError(s.line, s.start+1, s.SourceName, fmt.Sprintf("unexpected char: %q", lexeme))
+		return token.ILLEGAL, nil, compileError.NewScanError(s.startLine, s.startCol, s.SourceName, fmt.Sprintf("unexpected char: %q", lexeme))
 	}
 }
 
@@ -228,6 +342,24 @@ func (s *Scanner) scanComment() (tokenType token.TokenType, literal string, erro
 	return token.ILLEGAL, "", nil
 }
 
+// a backslash at the end of a directive line joins it with the next line.
+// Only whitespace may follow the backslash before the line break.
+func (s *Scanner) scanContinuation() (tokenType token.TokenType, literal any, err error) {
+	for !s.isAtEnd() {
+		nextChar, _ := util.ReadSingleChar(s.Source, s.current)
+		if nextChar == '\n' {
+			s.advanceChar()
+			s.newLine()
+			return token.ILLEGAL, nil, nil
+		}
+		if nextChar != ' ' && nextChar != '\t' && nextChar != '\r' {
+			break
+		}
+		s.advanceChar()
+	}
+	return token.ILLEGAL, nil, compileError.NewScanError(s.startLine, s.startCol, s.SourceName, "unexpected char: '\\' must end the line")
+}
+
 // when encounter a ", read every single char next until we meet the ending "
 func (s *Scanner) scanStringToken() (tokenType token.TokenType, literal string, error error) {
 	strLiteral := ""
@@ -236,14 +368,14 @@ func (s *Scanner) scanStringToken() (tokenType token.TokenType, literal string,
 		if nextChar == '"' {
 			break
 		}
+		strLiteral += string(s.advanceChar())
 		// read through new lines
 		if nextChar == '\n' {
-			s.line++
+			s.newLine()
 		}
-		strLiteral += string(s.advanceChar())
 	}
 	if s.isAtEnd() {
-		return token.ILLEGAL, "", compileError.NewScanError(s.line, s.start+1, s.SourceName, "unterminated string literal")
+		return token.ILLEGAL, "", compileError.NewScanError(s.startLine, s.startCol, s.SourceName, "unterminated string literal")
 	}
 	s.advanceChar() // consume closing "
 	return token.STRING, strLiteral, nil
@@ -272,17 +404,23 @@ func (s *Scanner) scanNumberToken() (tokenType token.TokenType, literal any, err
 			s.advanceChar()
 		}
 	}
+	text, _ := util.ReadSubstring(s.Source, s.start, s.current)
 	if isFloat {
-		floatLiteral, _ := strconv.ParseFloat(s.Source[s.start:s.current], 64)
+		floatLiteral, _ := strconv.ParseFloat(text, 64)
 		return token.NUMBER, floatLiteral, nil
 	}
-	intLiteral, _ := strconv.Atoi(s.Source[s.start:s.current])
+	intLiteral, err := strconv.Atoi(text)
+	if err != nil {
+		return token.ILLEGAL, nil, compileError.NewScanError(s.startLine, s.startCol, s.SourceName,
+			fmt.Sprintf("integer literal %s is out of range, the largest int is %d", text, math.MaxInt))
+	}
 	return token.NUMBER, intLiteral, nil
 }
 
 // Reads chars after a Docker keyword until newline, handling backslash continuations.
 // Returns only the argument portion (whitespace-trimmed), not the keyword itself.
 func (s *Scanner) scanDockerArgs() string {
+	gapStart := s.current
 	// skip whitespace between keyword and args
 	for !s.isAtEnd() {
 		nextChar, _ := util.ReadSingleChar(s.Source, s.current)
@@ -293,6 +431,8 @@ func (s *Scanner) scanDockerArgs() string {
 	}
 
 	argsStart := s.current
+	s.argsLine = s.line
+	s.argsCol = s.column(argsStart)
 	var lastNonSpace rune
 	for !s.isAtEnd() {
 		nextChar, _ := util.ReadSingleChar(s.Source, s.current)
@@ -300,7 +440,7 @@ func (s *Scanner) scanDockerArgs() string {
 			// backslash before newline means the instruction continues on the next line
 			if lastNonSpace == '\\' {
 				s.advanceChar() // consume newline for continuation
-				s.line++
+				s.newLine()
 				lastNonSpace = 0
 				continue
 			}
@@ -312,10 +452,18 @@ func (s *Scanner) scanDockerArgs() string {
 		}
 		s.advanceChar()
 	}
-	return strings.TrimSpace(s.Source[argsStart:s.current])
+	args, _ := util.ReadSubstring(s.Source, argsStart, s.current)
+	trimmed := strings.TrimSpace(args)
+
+	// remember the whitespace around the args so lossless mode can keep it as trivia
+	gap, _ := util.ReadSubstring(s.Source, gapStart, argsStart)
+	offset := strings.Index(args, trimmed)
+	s.argsLeading = gap + args[:offset]
+	s.argsTrailing = args[offset+len(trimmed):]
+	return trimmed
 }
 
-// Accumulates alphanumeric chars into text buffer, then looks up in DocklettTokenKeywords map
+// Accumulates alphanumeric chars into text buffer, then looks up the directive word, see token.LookupDirective
 func (s *Scanner) scanDocklettToken() (tokenType token.TokenType, literal any, error error) {
 	text := ""
 	for !s.isAtEnd() { // read until space or non-letter/digit
@@ -325,48 +473,67 @@ func (s *Scanner) scanDocklettToken() (tokenType token.TokenType, literal any, e
 		}
 		text += string(s.advanceChar())
 	}
-	docklettTokenType, found := token.DocklettTokenKeywords[text]
+	docklettTokenType, found := token.LookupDirective(text)
 	firstChar, _ := util.ReadSingleChar(s.Source, s.start)
 	if found {
 		return docklettTokenType, nil, nil
 	}
-	return token.ILLEGAL, nil, compileError.NewScanError(s.line, s.start+1, s.SourceName, fmt.Sprintf("unexpected Docklett token: %q", string(firstChar)+text))
+	return token.ILLEGAL, nil, compileError.NewScanError(s.startLine, s.startCol, s.SourceName, fmt.Sprintf("unexpected Docklett token: %q", string(firstChar)+text))
 }
 
 // Accumulates alphanumeric chars, checks Docklett keywords first if flag set,
 // then Docker keywords (queues DOCKER_ARGS as pending), else returns identifier.
 func (s *Scanner) scanKeywordsAndIdentifierTokens() (tokenType token.TokenType, literal any, error error) {
-	text := string(s.Source[s.start])
-	for !s.isAtEnd() { // read until space or non-letter/digit
+	text, _ := util.ReadSubstring(s.Source, s.start, s.current)
+	for !s.isAtEnd() { // read until space or non-letter/digit/underscore
 		nextChar, _ := util.ReadSingleChar(s.Source, s.current)
-		if !unicode.IsLetter(nextChar) && !unicode.IsDigit(nextChar) {
+		if !unicode.IsLetter(nextChar) && !unicode.IsDigit(nextChar) && nextChar != '_' {
 			break
 		}
 		text += string(s.advanceChar())
 	}
 	// if our lexeme starts with a @ we are using Docklett, prioritize Docklett keywords
+	// identifiers inside a directive are never Docker instructions (e.g. @SET user = "app")
 	if s.docklett {
-		if docklettTokenType, docklettFound := token.DocklettTokenKeywords[text]; docklettFound {
+		if docklettTokenType, docklettFound := token.LookupDocklettKeyword(text); docklettFound {
 			return docklettTokenType, nil, nil
 		}
+		return token.IDENTIFIER, text, nil
 	}
 
 	// if this is a Docker keyword, emit DOCKER_KEYWORD now and queue DOCKER_ARGS for next cycle
 	_, found := token.DockerTokenKeywords[strings.ToUpper(text)]
 	if found {
+		// the keyword lexeme ends here, even though the args are consumed in the same cycle
+		s.lexemeEnd = s.current
 		argsText := s.scanDockerArgs()
 		s.pendingToken = &token.Token{
 			Type:   token.DOCKER_ARGS,
 			Lexeme: argsText,
 			Position: token.Position{
-				Line: s.line,
+				Line: s.argsLine,
 				File: s.SourceName,
-				Col:  s.current + 1,
+				Col:  s.argsCol,
 			},
 		}
+		if s.Lossless {
+			s.pendingToken.LeadingTrivia = whitespaceTrivia(s.argsLeading)
+			s.pendingToken.TrailingTrivia = whitespaceTrivia(s.argsTrailing)
+		}
 		return token.DOCKER_KEYWORD, nil, nil
 	}
 
 	return token.IDENTIFIER, text, nil
 
 }
+
+// whitespaceTrivia wraps whitespace cut around Docker args, which may include a continued line break.
+func whitespaceTrivia(text string) []token.Trivia {
+	if text == "" {
+		return nil
+	}
+	if strings.Contains(text, "\n") {
+		return []token.Trivia{{Kind: token.LINE_BREAK, Text: text}}
+	}
+	return []token.Trivia{{Kind: token.WHITESPACE, Text: text}}
+}
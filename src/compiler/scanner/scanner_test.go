@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"docklett/compiler/token"
@@ -41,6 +42,10 @@ func tokenTypeName(t token.TokenType) string {
 		return "DIVIDE"
 	case token.DIV_ASSIGN:
 		return "DIV_ASSIGN"
+	case token.FLOOR_DIV:
+		return "FLOOR_DIV"
+	case token.MODULO:
+		return "MODULO"
 	case token.NEGATE:
 		return "NEGATE"
 	case token.AND:
@@ -73,6 +78,10 @@ func tokenTypeName(t token.TokenType) string {
 		return "COMMA"
 	case token.SET:
 		return "SET"
+	case token.CONST:
+		return "CONST"
+	case token.DEFAULT:
+		return "DEFAULT"
 	case token.IF:
 		return "IF"
 	case token.ELIF:
@@ -179,3 +188,102 @@ func TestScan_NewlineAfterDocker(t *testing.T) {
 	source := "FROM alpine\nRUN echo test\n"
 	scanAndPrintTokens(t, "newline_after_docker.dock", source)
 }
+
+// checkTokenTypes compares the types of tokens with want, one by one.
+func checkTokenTypes(t *testing.T, tokens []token.Token, want []token.TokenType) {
+	t.Helper()
+	if len(tokens) != len(want) {
+		t.Fatalf("got %d tokens, want %d: %v", len(tokens), len(want), tokens)
+	}
+	for i, tok := range tokens {
+		if tok.Type != want[i] {
+			t.Errorf("token %d: got %s, want %s", i, tokenTypeName(tok.Type), tokenTypeName(want[i]))
+		}
+	}
+}
+
+func TestScan_KeywordCase(t *testing.T) {
+	const (
+		id     = token.IDENTIFIER
+		assign = token.ASSIGN
+		nl     = token.NLINE
+		eof    = token.EOF
+	)
+	tests := []struct {
+		source string
+		want   []token.TokenType
+	}{
+		// directive words are matched in any case
+		{"@if x\n@End\n", []token.TokenType{token.IF, id, nl, token.END, nl, eof}},
+		{"@Set x\n", []token.TokenType{token.SET, id, nl, eof}},
+		// keywords inside a directive are upper case, and a few also lower case
+		{"@FOR x in xs\n", []token.TokenType{token.FOR, id, token.IN, id, nl, eof}},
+		{"@SET b = TRUE and FALSE\n", []token.TokenType{token.SET, id, assign, token.TRUE, token.AND, token.FALSE, nl, eof}},
+		// other spellings, and the words that only start a directive, are names
+		{"@SET In = True\n", []token.TokenType{token.SET, id, assign, id, nl, eof}},
+		{"@SET true = false\n", []token.TokenType{token.SET, id, assign, id, nl, eof}},
+		{"@SET end = default + set + ELSE\n", []token.TokenType{token.SET, id, assign, id, token.ADD, id, token.ADD, id, nl, eof}},
+		{"@SET r = RANGE\n", []token.TokenType{token.SET, id, assign, id, nl, eof}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			s := Scanner{Source: tt.source}
+			if err := s.ScanSource(); err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			checkTokenTypes(t, s.Tokens, tt.want)
+		})
+	}
+
+	s := Scanner{Source: "@IN x\n"}
+	if err := s.ScanSource(); err == nil || !strings.Contains(err.Error(), `unexpected Docklett token: "@IN"`) {
+		t.Errorf("scan @IN: error = %v, want unexpected Docklett token", err)
+	}
+}
+
+func TestScanExpression(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []token.TokenType
+	}{
+		{`upper(name)`, []token.TokenType{token.IDENTIFIER, token.LPAREN, token.IDENTIFIER, token.RPAREN, token.EOF}},
+		// a name that is also a Docker instruction stays an identifier
+		{`env("HOME")`, []token.TokenType{token.IDENTIFIER, token.LPAREN, token.STRING, token.RPAREN, token.EOF}},
+		{`_tag + base_image`, []token.TokenType{token.IDENTIFIER, token.ADD, token.IDENTIFIER, token.EOF}},
+		{`range(0, 3)`, []token.TokenType{token.RANGE, token.LPAREN, token.NUMBER, token.COMMA, token.NUMBER, token.RPAREN, token.EOF}},
+		{`7 // 2 % 3`, []token.TokenType{token.NUMBER, token.FLOOR_DIV, token.NUMBER, token.MODULO, token.NUMBER, token.EOF}},
+		{`a/b`, []token.TokenType{token.IDENTIFIER, token.DIVIDE, token.IDENTIFIER, token.EOF}},
+		{`a || b && c`, []token.TokenType{token.IDENTIFIER, token.OR, token.IDENTIFIER, token.AND, token.IDENTIFIER, token.EOF}},
+		{`a or b AND c`, []token.TokenType{token.IDENTIFIER, token.OR, token.IDENTIFIER, token.AND, token.IDENTIFIER, token.EOF}},
+		{`x in xs`, []token.TokenType{token.IDENTIFIER, token.IN, token.IDENTIFIER, token.EOF}},
+		{`[x for x in xs if x]`, []token.TokenType{token.LBRACKET, token.IDENTIFIER, token.FOR, token.IDENTIFIER, token.IN,
+			token.IDENTIFIER, token.IF, token.IDENTIFIER, token.RBRACKET, token.EOF}},
+		// only whole words are keywords
+		{`order + android`, []token.TokenType{token.IDENTIFIER, token.ADD, token.IDENTIFIER, token.EOF}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			s := Scanner{Source: tt.source}
+			if err := s.ScanExpression(); err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			checkTokenTypes(t, s.Tokens, tt.want)
+		})
+	}
+}
+
+func TestScanExpression_SingleBar(t *testing.T) {
+	s := Scanner{Source: "a | b"}
+	if err := s.ScanExpression(); err == nil || !strings.Contains(err.Error(), "unexpected char: |") {
+		t.Errorf("scan a | b: error = %v, want unexpected char: |", err)
+	}
+}
+
+func TestScanExpression_IntegerOutOfRange(t *testing.T) {
+	s := Scanner{Source: "9223372036854775808"}
+	err := s.ScanExpression()
+	want := "integer literal 9223372036854775808 is out of range, the largest int is 9223372036854775807"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %v, want %q", err, want)
+	}
+}
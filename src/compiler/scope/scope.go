@@ -0,0 +1,166 @@
+/*
+Package scope manages variable bindings for the Interpreter and the Translator using a
+parent-pointer tree (scope chain). Each Scope represents one level and links to its
+immediately enclosing scope.
+
+SCOPE CHAIN LOOKUP:
+Variables are resolved by walking the chain from innermost to outermost scope:
+ 1. Check current scope
+ 2. If not found, check parent (Enclosing), up to the global scope
+ 3. If not found in any scope, return an UndefinedVariableError carrying the referencing token
+
+Nothing here panics: every failure is returned as a typed error from package error, so
+callers can report it at the right position and keep going.
+
+EXAMPLES:
+
+	@SET x = 5       → Declare(x, 5) in current scope
+	x                → Get(x) walks chain: current → parent → ... → global
+	x = 10           → Assign(x, 10) walks chain to find existing binding
+	@FOR i IN ...    → Define("i", elem) per iteration, Delete("i") afterwards
+
+SHADOWING:
+Inner scopes can shadow outer variables with the same name:
+
+	@SET x = "outer"
+	@IF TRUE
+	    @SET x = "inner"   // shadows outer x inside the block
+	@END
+
+CONSTANTS:
+A @CONST binding can never be reassigned, redefined or shadowed. With Strict set,
+redeclaring a @SET variable in the same scope is also an error. Both cases return a
+RedefinitionError pointing at the new name and the original declaration.
+*/
+package scope
+
+import (
+	compileError "docklett/compiler/error"
+	"docklett/compiler/token"
+	"fmt"
+)
+
+// declaration records the identifier that introduced a binding and whether it is a constant.
+type declaration struct {
+	name     token.Token
+	constant bool
+}
+
+// Scope stores variable bindings for one scope level.
+type Scope struct {
+	Enclosing    *Scope // parent scope (nil for global scope)
+	Strict       bool   // redeclaring a @SET in the same scope is an error
+	values       map[string]any
+	declarations map[string]declaration
+}
+
+// New creates a scope below enclosing, or a global scope when enclosing is nil.
+// Child scopes inherit strict mode.
+func New(enclosing *Scope) *Scope {
+	s := &Scope{
+		Enclosing:    enclosing,
+		values:       make(map[string]any),
+		declarations: make(map[string]declaration),
+	}
+	if enclosing != nil {
+		s.Strict = enclosing.Strict
+	}
+	return s
+}
+
+// Define binds name in the current scope without any checks, e.g. for loop variables.
+// Allows shadowing of outer scope variables.
+func (s *Scope) Define(name string, value any) {
+	s.values[name] = value
+}
+
+// Declare binds a @SET or @CONST declaration in the current scope.
+// Fails when the name is a constant anywhere in the scope chain, when a constant would replace
+// a binding of the same scope, or when Strict is set and the name is already declared in this scope.
+func (s *Scope) Declare(name token.Token, value any, constant bool) error {
+	if previous, ok := s.Constant(name.Lexeme); ok {
+		return compileError.NewRedefinitionError(name, previous,
+			fmt.Sprintf("cannot redefine constant '%s'", name.Lexeme))
+	}
+	if previous, ok := s.declarations[name.Lexeme]; ok && (constant || s.Strict) {
+		return compileError.NewRedefinitionError(name, previous.name,
+			fmt.Sprintf("'%s' is already declared in this scope", name.Lexeme))
+	}
+	s.values[name.Lexeme] = value
+	s.declarations[name.Lexeme] = declaration{name: name, constant: constant}
+	return nil
+}
+
+// Constant returns the declaring identifier of the constant called name, searching the whole scope chain.
+func (s *Scope) Constant(name string) (token.Token, bool) {
+	for current := s; current != nil; current = current.Enclosing {
+		if decl, ok := current.declarations[name]; ok && decl.constant {
+			return decl.name, true
+		}
+	}
+	return token.Token{}, false
+}
+
+// Lookup finds name in the scope chain without reporting an error.
+func (s *Scope) Lookup(name string) (any, bool) {
+	for current := s; current != nil; current = current.Enclosing {
+		if value, ok := current.values[name]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// Owner returns the scope in the chain that binds name, or nil when name is not bound.
+func (s *Scope) Owner(name string) *Scope {
+	for current := s; current != nil; current = current.Enclosing {
+		if _, ok := current.values[name]; ok {
+			return current
+		}
+	}
+	return nil
+}
+
+// Get retrieves the value of the variable referenced by name, walking the scope chain.
+func (s *Scope) Get(name token.Token) (any, error) {
+	if value, ok := s.Lookup(name.Lexeme); ok {
+		return value, nil
+	}
+	return nil, compileError.NewUndefinedVariableError(name)
+}
+
+// Assign updates an EXISTING variable in the nearest scope that binds it.
+// Unlike Declare, this requires the variable to exist somewhere in the chain and not be a constant.
+func (s *Scope) Assign(name token.Token, value any) error {
+	if previous, ok := s.Constant(name.Lexeme); ok {
+		return compileError.NewRedefinitionError(name, previous,
+			fmt.Sprintf("cannot assign to constant '%s'", name.Lexeme))
+	}
+	for current := s; current != nil; current = current.Enclosing {
+		if _, ok := current.values[name.Lexeme]; ok {
+			current.values[name.Lexeme] = value
+			return nil
+		}
+	}
+	return compileError.NewUndefinedVariableError(name)
+}
+
+// Delete removes a variable from the current scope only.
+// Used to clean up loop variables after a ForStatement completes.
+func (s *Scope) Delete(name string) {
+	delete(s.values, name)
+	delete(s.declarations, name)
+}
+
+// Visible returns every binding reachable from this scope, with inner bindings shadowing outer ones.
+func (s *Scope) Visible() map[string]any {
+	visible := make(map[string]any)
+	for current := s; current != nil; current = current.Enclosing {
+		for name, value := range current.values {
+			if _, shadowed := visible[name]; !shadowed {
+				visible[name] = value
+			}
+		}
+	}
+	return visible
+}
@@ -0,0 +1,113 @@
+package scope
+
+import (
+	"errors"
+	"testing"
+
+	compileError "docklett/compiler/error"
+	"docklett/compiler/token"
+)
+
+func identifier(name string, line, col int) token.Token {
+	return token.Token{Type: token.IDENTIFIER, Lexeme: name, Position: token.Position{Line: line, Col: col}}
+}
+
+func assertRedefinition(t *testing.T, err error, line, previousLine int) {
+	t.Helper()
+
+	var redefinition *compileError.RedefinitionError
+	if !errors.As(err, &redefinition) {
+		t.Fatalf("error = %v, want *RedefinitionError", err)
+	}
+	if redefinition.Name.Line != line || redefinition.Previous.Line != previousLine {
+		t.Errorf("redefinition at line %d (previous %d), want line %d (previous %d)",
+			redefinition.Name.Line, redefinition.Previous.Line, line, previousLine)
+	}
+}
+
+func assertUndefined(t *testing.T, err error, name string, line int) {
+	t.Helper()
+
+	var undefined *compileError.UndefinedVariableError
+	if !errors.As(err, &undefined) {
+		t.Fatalf("error = %v, want *UndefinedVariableError", err)
+	}
+	if undefined.Name.Lexeme != name || undefined.Name.Line != line {
+		t.Errorf("undefined %q at line %d, want %q at line %d", undefined.Name.Lexeme, undefined.Name.Line, name, line)
+	}
+}
+
+func TestScope_ChainLookupAndAssign(t *testing.T) {
+	global := New(nil)
+	global.Define("x", 1)
+	inner := New(global)
+	inner.Define("y", 2)
+
+	if got, err := inner.Get(identifier("x", 3, 1)); err != nil || got != 1 {
+		t.Errorf("Get(x) = %v, %v, want 1", got, err)
+	}
+	if err := inner.Assign(identifier("x", 4, 1), 10); err != nil {
+		t.Fatalf("Assign(x): %v", err)
+	}
+	if got, _ := global.Get(identifier("x", 5, 1)); got != 10 {
+		t.Errorf("assignment did not reach the enclosing scope: x = %v", got)
+	}
+
+	if inner.Owner("x") != global || inner.Owner("y") != inner || inner.Owner("z") != nil {
+		t.Errorf("Owner did not return the binding scope")
+	}
+
+	inner.Delete("y")
+	if _, ok := inner.Lookup("y"); ok {
+		t.Errorf("y still bound after Delete")
+	}
+}
+
+func TestScope_UndefinedVariable(t *testing.T) {
+	s := New(New(nil))
+
+	_, err := s.Get(identifier("VERSON", 7, 12))
+	assertUndefined(t, err, "VERSON", 7)
+
+	assertUndefined(t, s.Assign(identifier("missing", 9, 1), 1), "missing", 9)
+}
+
+func TestScope_ConstantCannotBeRedefinedOrShadowed(t *testing.T) {
+	global := New(nil)
+	if err := global.Declare(identifier("BASE", 1, 8), "alpine", true); err != nil {
+		t.Fatalf("declare constant: %v", err)
+	}
+
+	assertRedefinition(t, global.Declare(identifier("BASE", 2, 6), "debian", false), 2, 1)
+	assertRedefinition(t, global.Declare(identifier("BASE", 3, 8), "debian", true), 3, 1)
+
+	inner := New(global)
+	assertRedefinition(t, inner.Declare(identifier("BASE", 5, 6), "debian", false), 5, 1)
+	assertRedefinition(t, inner.Assign(identifier("BASE", 6, 1), "debian"), 6, 1)
+
+	if got, _ := global.Get(identifier("BASE", 7, 1)); got != "alpine" {
+		t.Errorf("BASE = %v, want alpine", got)
+	}
+}
+
+func TestScope_StrictRedefinition(t *testing.T) {
+	s := New(nil)
+	if err := s.Declare(identifier("TAG", 1, 6), "1", false); err != nil {
+		t.Fatalf("declare: %v", err)
+	}
+	if err := s.Declare(identifier("TAG", 2, 6), "2", false); err != nil {
+		t.Errorf("non-strict redefinition: %v", err)
+	}
+
+	s.Strict = true
+	assertRedefinition(t, s.Declare(identifier("TAG", 3, 6), "3", false), 3, 2)
+
+	// strict mode is inherited, but shadowing in an inner scope stays legal
+	inner := New(s)
+	if !inner.Strict {
+		t.Errorf("child scope did not inherit strict mode")
+	}
+	if err := inner.Declare(identifier("TAG", 5, 6), "inner", false); err != nil {
+		t.Errorf("shadowing in inner scope: %v", err)
+	}
+}
@@ -1,5 +1,7 @@
 package token
 
+import "strings"
+
 type Position struct {
 	Line int
 	File string
@@ -28,9 +30,12 @@ const (
 	MULTI_ASSIGN //
 	DIVIDE       //
 	DIV_ASSIGN   //
+	FLOOR_DIV    // "//", integer (floor) division
+	MODULO       //
 	NEGATE       //
 	AND          //
 	OR           //
+	COALESCE     // "??", the right operand when the left one is nil or undefined
 	GREATER      //
 	LESS         //
 	GTE          //
@@ -45,10 +50,13 @@ const (
 	RBRACKET //
 	COLON    //
 	COMMA    //
+	DOT      //
 	NLINE
 
 	// Keywords
 	SET
+	CONST
+	DEFAULT
 	IF
 	ELIF
 	ELSE
@@ -89,16 +97,56 @@ var DockerTokenKeywords = map[string]TokenType{
 }
 
 var DocklettTokenKeywords = map[string]TokenType{
-	"SET":   SET,
-	"IF":    IF,
-	"ELIF":  ELIF,
-	"ELSE":  ELSE,
-	"FOR":   FOR,
-	"IN":    IN,
-	"END":   END,
-	"TRUE":  TRUE,
-	"FALSE": FALSE,
-	"range": RANGE,
+	"SET":     SET,
+	"CONST":   CONST,
+	"DEFAULT": DEFAULT,
+	"IF":      IF,
+	"ELIF":    ELIF,
+	"ELSE":    ELSE,
+	"FOR":     FOR,
+	"IN":      IN,
+	"AND":     AND, // and, or: spellings of && and ||
+	"OR":      OR,
+	"END":     END,
+	"TRUE":    TRUE,
+	"FALSE":   FALSE,
+	"range":   RANGE,
+}
+
+// LowerCaseKeywords are the keywords that are also spelled in lower case inside an expression:
+// the "for", "in" and "if" of a comprehension and the "and", "or" and "in" operators.
+var LowerCaseKeywords = map[string]TokenType{
+	"for": FOR,
+	"in":  IN,
+	"if":  IF,
+	"and": AND,
+	"or":  OR,
+}
+
+// LookupDirective finds the keyword after an "@" regardless of case, so @if, @If and @IF all scan
+// as IF. Only words that start a directive are found: "@IN" is not a directive.
+func LookupDirective(text string) (TokenType, bool) {
+	tokenType, found := DocklettTokenKeywords[strings.ToUpper(text)]
+	switch tokenType {
+	case SET, CONST, DEFAULT, IF, ELIF, ELSE, FOR, END:
+		return tokenType, found
+	}
+	return 0, false
+}
+
+// LookupDocklettKeyword finds the keyword for a word inside a directive. Keywords are spelled in
+// upper case, IN or TRUE, except "range" and the LowerCaseKeywords. Any other spelling, such as
+// true or In, and the words that only start a directive (SET, DEFAULT, ELSE, END, ...) are names.
+func LookupDocklettKeyword(text string) (TokenType, bool) {
+	tokenType, found := DocklettTokenKeywords[text]
+	if !found {
+		tokenType, found = LowerCaseKeywords[text]
+	}
+	switch tokenType {
+	case SET, CONST, DEFAULT, ELIF, ELSE, END:
+		return 0, false
+	}
+	return tokenType, found
 }
 
 var TokenTypeNames = map[TokenType]string{
@@ -117,9 +165,12 @@ var TokenTypeNames = map[TokenType]string{
 	MULTI_ASSIGN:   "MULTI_ASSIGN",
 	DIVIDE:         "DIVIDE",
 	DIV_ASSIGN:     "DIV_ASSIGN",
+	FLOOR_DIV:      "FLOOR_DIV",
+	MODULO:         "MODULO",
 	NEGATE:         "NEGATE",
 	AND:            "AND",
 	OR:             "OR",
+	COALESCE:       "COALESCE",
 	GREATER:        "GREATER",
 	LESS:           "LESS",
 	GTE:            "GTE",
@@ -132,7 +183,10 @@ var TokenTypeNames = map[TokenType]string{
 	RBRACKET:       "RBRACKET",
 	COLON:          "COLON",
 	COMMA:          "COMMA",
+	DOT:            "DOT",
 	SET:            "SET",
+	CONST:          "CONST",
+	DEFAULT:        "DEFAULT",
 	IF:             "IF",
 	ELIF:           "ELIF",
 	ELSE:           "ELSE",
@@ -149,9 +203,45 @@ var TokenTypeNames = map[TokenType]string{
 	NLINE:          "NEW_LINE",
 }
 
+// TriviaKind classifies source text that carries no meaning for the parser.
+type TriviaKind int
+
+const (
+	_            TriviaKind = iota
+	WHITESPACE              // spaces, tabs and carriage returns
+	COMMENT                 // "#" comment up to, not including, the line break
+	LINE_BREAK              // line break of an empty or comment-only line
+	CONTINUATION            // backslash continuing a directive onto the next line, including the line break
+)
+
+// Trivia is a piece of source text kept alongside a token in lossless scanning mode.
+// Concatenating every token's leading trivia, lexeme and trailing trivia reproduces the source.
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+}
+
 type Token struct {
 	Type   TokenType
 	Lexeme string
 	Position
-	Literal any
+	Literal        any
+	LeadingTrivia  []Trivia // trivia between the previous token and this one (lossless mode only)
+	TrailingTrivia []Trivia // trivia after this token up to the end of its line (lossless mode only)
+}
+
+// End returns the position immediately after the token's lexeme.
+// Lexemes can span lines (string literals, continued Docker arguments), so a newline
+// inside the lexeme moves the end onto the following line.
+func (t Token) End() Position {
+	end := t.Position
+	for _, r := range t.Lexeme {
+		if r == '\n' {
+			end.Line++
+			end.Col = 1
+			continue
+		}
+		end.Col++
+	}
+	return end
 }
@@ -13,6 +13,11 @@ package translator
 
 import (
 	"docklett/compiler/ast"
+	compileError "docklett/compiler/error"
+	"docklett/compiler/interpolate"
+	"docklett/compiler/parser"
+	"docklett/compiler/token"
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -21,7 +26,28 @@ import (
 // Variable interpolation is applied to args before dispatch.
 func (t *Translator) translateDocker(stmt *ast.DockerStatement) error {
 	keyword := strings.ToUpper(stmt.Keyword.Lexeme)
-	args := t.interpolateVariables(stmt.Args)
+	args, err := t.interpolateVariables(stmt)
+	if err != nil {
+		var located compileError.CompileError
+		if errors.As(err, &located) {
+			return err // already points into the template expression
+		}
+		var refErr *interpolate.ReferenceError
+		if errors.As(err, &refErr) {
+			return compileError.NewEvaluationError(referenceName(stmt, refErr.Ref), refErr.Message)
+		}
+		return compileError.NewEvaluationError(stmt, err.Error())
+	}
+	if err := t.CheckSize(stmt, args); err != nil {
+		return err
+	}
+	if limit := t.Limits.MaxInstructions; limit > 0 && len(t.instructions) >= limit {
+		return compileError.NewLimitError(stmt, fmt.Sprintf("compilation exceeded the limit of %d Docker instructions", limit), nil)
+	}
+	t.declareDockerVariables(keyword, args)
+	instruction := Instruction{Keyword: keyword, Args: args, Node: stmt}
+	t.instructions = append(t.instructions, instruction)
+	t.traceInstruction(stmt, instruction)
 
 	switch keyword {
 	case "FROM":
@@ -44,19 +70,57 @@ func (t *Translator) translateDocker(stmt *ast.DockerStatement) error {
 		return nil
 
 	default:
-		return fmt.Errorf("[line %d] unknown Docker instruction: %s", stmt.Keyword.Line, keyword)
+		return compileError.NewTranslatorError(stmt, fmt.Sprintf("unknown Docker instruction: %s", keyword))
 	}
 }
 
-// interpolateVariables replaces ${name} references in args with compile-time variable values.
-// Unresolved variables are left as-is for runtime resolution by the container engine.
-func (t *Translator) interpolateVariables(args string) string {
-	result := args
-	for name, val := range t.env.Bindings {
-		placeholder := "${" + name + "}"
-		result = strings.ReplaceAll(result, placeholder, fmt.Sprintf("%v", val))
+// interpolateVariables replaces ${name} references in the args of stmt with compile-time variable
+// values from the whole scope chain, and ${call(...)} references with the value of the expression,
+// see package interpolate. Unresolved variables are left as-is for runtime resolution by the
+// container engine, or reported in strict interpolation mode.
+func (t *Translator) interpolateVariables(stmt *ast.DockerStatement) (string, error) {
+	return interpolate.Expand(stmt.Args, t.Scope.Lookup, interpolate.Options{
+		Strict:   t.strictInterpolation,
+		External: func(name string) bool { return t.dockerVariables[name] },
+		Evaluate: func(ref interpolate.Reference) (any, bool, error) {
+			expr, err := parser.ParseReference(stmt, ref)
+			if err != nil {
+				return nil, false, err
+			}
+			val, err := t.Evaluate(expr)
+			return val, err == nil, err
+		},
+	})
+}
+
+// referenceName returns the name of a ${name} reference in the args of stmt as a variable expression,
+// so an error about the reference points at the name rather than at the instruction.
+func referenceName(stmt *ast.DockerStatement, ref interpolate.Reference) *ast.VariableExpression {
+	at := interpolate.Position(stmt.Args, stmt.ArgsToken.Position, ref.Start+len("${"))
+	return &ast.VariableExpression{Name: token.Token{Type: token.IDENTIFIER, Lexeme: ref.Name, Position: at}}
+}
+
+// declareDockerVariables records the names an ARG or ENV instruction defines for Docker,
+// so strict interpolation accepts later ${name} references to them.
+//
+//	ARG VERSION=1.0         ENV PATH=/bin HOME=/root         ENV LANG C.UTF-8
+func (t *Translator) declareDockerVariables(keyword, args string) {
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 0:
+		return
+	case keyword == "ARG":
+		name, _, _ := strings.Cut(fields[0], "=")
+		t.dockerVariables[name] = true
+	case keyword == "ENV" && !strings.Contains(fields[0], "="):
+		t.dockerVariables[fields[0]] = true
+	case keyword == "ENV":
+		for _, field := range fields {
+			if name, _, ok := strings.Cut(field, "="); ok {
+				t.dockerVariables[name] = true
+			}
+		}
 	}
-	return result
 }
 
 // translateFrom sets the base image. "scratch" produces an empty state.
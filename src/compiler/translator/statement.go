@@ -6,62 +6,38 @@ package translator
 
 import (
 	"docklett/compiler/ast"
+	compileError "docklett/compiler/error"
+	"docklett/compiler/scope"
+	"docklett/compiler/value"
 	"fmt"
 )
 
 // Compile-time check to ensure Translator implements StatementVisitor
 var _ ast.StatementVisitor = (*Translator)(nil)
 
-// isTruthy determines boolean value of any value.
-func (t *Translator) isTruthy(value any) bool {
-	switch v := value.(type) {
-	case bool:
-		return v
-	case nil:
-		return false
-	case int:
-		return v != 0
-	case float64:
-		return v != 0.0
-	case string:
-		return v != ""
-	default:
-		return value != nil
-	}
-}
-
-// VisitStatement is a placeholder for the base Statement interface
-func (t *Translator) VisitStatement(statement *ast.Statement) (any, error) {
-	return nil, nil
-}
-
 // VisitExpressionStatement evaluates the expression for side effects.
 func (t *Translator) VisitExpressionStatement(stmt *ast.ExpressionStatement) (any, error) {
-	return nil, nil
+	return t.Evaluate(stmt.Expression)
 }
 
 // VisitVarDeclarationStatement binds a variable in the translator's environment.
 // The initializer is evaluated and the result stored for later interpolation.
+// Redefinitions rejected by the environment (constants, strict mode) are returned as errors.
 func (t *Translator) VisitVarDeclarationStatement(stmt *ast.VariableDeclarationStatement) (any, error) {
-	var value any = nil
-	if stmt.Initializer != nil {
-		val, err := t.evaluateExpression(stmt.Initializer)
-		if err != nil {
-			return nil, err
-		}
-		value = val
-	}
-	t.env.Define(stmt.Name.Lexeme, value)
-	return nil, nil
+	return nil, t.Declare(stmt)
 }
 
 // VisitBlockStatement creates a child scope and translates all statements within it.
 // The child scope is discarded after the block completes.
 func (t *Translator) VisitBlockStatement(stmt *ast.BlockStatement) (any, error) {
-	childEnv := NewEnvironment(t.env)
-	previousEnv := t.env
-	defer func() { t.env = previousEnv }()
-	t.env = childEnv
+	if err := t.Enter(stmt); err != nil {
+		return nil, err
+	}
+	defer t.Leave()
+	childEnv := scope.New(t.Scope)
+	previousEnv := t.Scope
+	defer func() { t.Scope = previousEnv }()
+	t.Scope = childEnv
 
 	for _, s := range stmt.Statements {
 		if _, err := t.execute(s); err != nil {
@@ -74,11 +50,13 @@ func (t *Translator) VisitBlockStatement(stmt *ast.BlockStatement) (any, error)
 // VisitIfStatement evaluates the condition and only translates the taken branch,
 // producing LLB nodes for that path only.
 func (t *Translator) VisitIfStatement(stmt *ast.IfStatement) (any, error) {
-	condVal, err := t.evaluateExpression(stmt.Condition)
+	condVal, err := t.Evaluate(stmt.Condition)
 	if err != nil {
 		return nil, err
 	}
-	if t.isTruthy(condVal) {
+	taken := value.Truthy(condVal)
+	t.traceCondition(stmt, condVal, taken)
+	if taken {
 		return t.execute(stmt.ThenBranch)
 	}
 	if stmt.ElseBranch != nil {
@@ -94,13 +72,12 @@ func (t *Translator) VisitDockerStatement(stmt *ast.DockerStatement) (any, error
 }
 
 // VisitForStatement unrolls the loop at compile time.
-// This is just a placeholder implementation, TODO is look up compiler design for looping
 // Evaluates the iterable (array literal or range), then for each element:
-//  1. Binds the target variable to the element value
-//  2. Executes the body (producing LLB nodes)
-//  3. Unbinds the target after the loop completes
+//  1. Binds the target variable to the element value in a loop scope
+//  2. Executes the body (producing LLB nodes) in a child scope of the loop scope
+//  3. Discards the loop scope, so the target never leaks into or clobbers the enclosing scope
 func (t *Translator) VisitForStatement(stmt *ast.ForStatement) (any, error) {
-	iterVal, err := t.evaluateExpression(stmt.Iterable)
+	iterVal, err := t.Evaluate(stmt.Iterable)
 	if err != nil {
 		return nil, err
 	}
@@ -108,20 +85,34 @@ func (t *Translator) VisitForStatement(stmt *ast.ForStatement) (any, error) {
 	// the iterable must evaluate to a []any slice
 	elements, ok := iterVal.([]any)
 	if !ok {
-		return nil, fmt.Errorf("[line %d] for loop iterable must be an array or range, got %T",
-			stmt.Target.Line, iterVal)
+		return nil, compileError.NewTranslatorError(stmt.Iterable,
+			fmt.Sprintf("for loop iterable must be an array or range, got %s", value.TypeName(iterVal)))
 	}
 
+	// the loop variable would shadow the constant inside the body
+	if previous, isConstant := t.Scope.Constant(stmt.Target.Lexeme); isConstant {
+		return nil, compileError.NewRedefinitionError(stmt.Target, previous,
+			fmt.Sprintf("loop variable cannot shadow constant '%s'", stmt.Target.Lexeme))
+	}
+
+	loopScope := scope.New(t.Scope)
+	previousScope := t.Scope
+	defer func() { t.Scope = previousScope }()
+	t.Scope = loopScope
+
 	for i, elem := range elements {
-		if i >= t.maxLoopIter {
-			return nil, fmt.Errorf("[line %d] for loop exceeded maximum iteration limit (%d)",
-				stmt.Target.Line, t.maxLoopIter)
+		if i >= t.MaxIterations {
+			return nil, compileError.NewTranslatorError(stmt,
+				fmt.Sprintf("for loop exceeded maximum iteration limit (%d)", t.MaxIterations))
+		}
+		if err := t.Iterate(stmt); err != nil {
+			return nil, err
 		}
-		t.env.Define(stmt.Target.Lexeme, elem)
+		loopScope.Define(stmt.Target.Lexeme, elem)
+		t.traceIteration(stmt, elem, i, len(elements))
 		if _, err := t.execute(stmt.Body); err != nil {
 			return nil, err
 		}
 	}
-	t.env.Delete(stmt.Target.Lexeme)
 	return nil, nil
 }
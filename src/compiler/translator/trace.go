@@ -0,0 +1,182 @@
+/*
+Trace mode: a log of every decision the Translator makes, for finding out why an instruction is or
+is not in the output. SetTrace installs a function that receives one TraceEvent per decision:
+
+	condition       an @IF or @ELIF condition, its value and whether its branch was taken
+	iteration       one pass of a @FOR loop with the value bound to the loop variable
+	interpolation   the arguments of a Docker instruction with ${...} references, before and after
+	instruction     a Docker instruction as emitted
+
+Every event carries the file and line of the directive or instruction, and the scope depth at that
+point: 0 at the top level, one more for each enclosing block, and one more for the scope that binds a
+@FOR variable. Values are printed as Docklett literals (see format.Value), so "3" and 3 stay apart.
+
+TextTrace writes one line per event, JSONTrace one JSON object per line:
+
+	app.dock:4 depth=0 condition @IF OS == "alpine" → TRUE, taken
+	app.dock:5 depth=2 iteration @FOR PKG = "curl" (1 of 2)
+	app.dock:6 depth=3 interpolation apk add ${PKG} → apk add curl
+	app.dock:6 depth=3 instruction RUN apk add curl
+
+	{"after":"apk add curl","before":"apk add ${PKG}","depth":3,"event":"interpolation","file":"app.dock","line":6}
+*/
+package translator
+
+import (
+	"docklett/compiler/ast"
+	"docklett/compiler/format"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TraceKind names what a TraceEvent records.
+type TraceKind string
+
+const (
+	TraceCondition     TraceKind = "condition"
+	TraceIteration     TraceKind = "iteration"
+	TraceInterpolation TraceKind = "interpolation"
+	TraceInstruction   TraceKind = "instruction"
+)
+
+// TraceEvent is one decision of the translation. Only the fields of its Kind are set.
+type TraceEvent struct {
+	Kind  TraceKind
+	File  string // source file, empty for source without a name
+	Line  int
+	Depth int // scope depth, 0 at the top level
+
+	Directive  string // condition, iteration: @IF, @ELIF or @FOR
+	Expression string // condition: the condition as written
+	Value      string // condition: its value as a literal
+	Taken      bool   // condition: whether its branch was translated
+
+	Target string // iteration: loop variable
+	Item   string // iteration: value bound to Target, as a literal
+	Index  int    // iteration: 1 for the first pass
+	Count  int    // iteration: number of items in the iterable
+
+	Before string // interpolation: arguments as written
+	After  string // interpolation: arguments after expansion
+
+	Keyword string // instruction: upper-case verb, e.g. RUN
+	Args    string // instruction: interpolated arguments
+}
+
+func (e TraceEvent) String() string {
+	location := fmt.Sprintf("line %d", e.Line)
+	if e.File != "" {
+		location = fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+	var detail string
+	switch e.Kind {
+	case TraceCondition:
+		taken := "not taken"
+		if e.Taken {
+			taken = "taken"
+		}
+		detail = fmt.Sprintf("%s %s → %s, %s", e.Directive, e.Expression, e.Value, taken)
+	case TraceIteration:
+		detail = fmt.Sprintf("%s %s = %s (%d of %d)", e.Directive, e.Target, e.Item, e.Index, e.Count)
+	case TraceInterpolation:
+		detail = fmt.Sprintf("%s → %s", e.Before, e.After)
+	case TraceInstruction:
+		detail = Instruction{Keyword: e.Keyword, Args: e.Args}.String()
+	}
+	return fmt.Sprintf("%s depth=%d %s %s", location, e.Depth, e.Kind, detail)
+}
+
+// MarshalJSON writes the common fields and the fields of the event's Kind, so a false Taken or an
+// empty After is still present where it means something.
+func (e TraceEvent) MarshalJSON() ([]byte, error) {
+	fields := map[string]any{"event": e.Kind, "line": e.Line, "depth": e.Depth}
+	if e.File != "" {
+		fields["file"] = e.File
+	}
+	switch e.Kind {
+	case TraceCondition:
+		fields["directive"], fields["expression"], fields["value"], fields["taken"] = e.Directive, e.Expression, e.Value, e.Taken
+	case TraceIteration:
+		fields["directive"], fields["bindings"] = e.Directive, map[string]string{e.Target: e.Item}
+		fields["index"], fields["count"] = e.Index, e.Count
+	case TraceInterpolation:
+		fields["before"], fields["after"] = e.Before, e.After
+	case TraceInstruction:
+		fields["keyword"], fields["args"] = e.Keyword, e.Args
+	}
+	return json.Marshal(fields)
+}
+
+// TextTrace returns a trace function that writes each event to w as a line of text.
+func TextTrace(w io.Writer) func(TraceEvent) {
+	return func(event TraceEvent) {
+		fmt.Fprintln(w, event)
+	}
+}
+
+// JSONTrace returns a trace function that writes each event to w as a line of JSON.
+func JSONTrace(w io.Writer) func(TraceEvent) {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return func(event TraceEvent) {
+		_ = encoder.Encode(event)
+	}
+}
+
+// SetTrace makes the translation call trace for every decision it makes; nil turns tracing off.
+func (t *Translator) SetTrace(trace func(TraceEvent)) {
+	t.trace = trace
+}
+
+// traceCondition records the value of an @IF or @ELIF condition.
+func (t *Translator) traceCondition(stmt *ast.IfStatement, val any, taken bool) {
+	if t.trace == nil {
+		return
+	}
+	event := t.traceEvent(TraceCondition, stmt)
+	event.Directive = strings.ToUpper(stmt.Open.Lexeme)
+	event.Expression = format.Expression(stmt.Condition)
+	event.Value = format.Value(val)
+	event.Taken = taken
+	t.trace(event)
+}
+
+// traceIteration records one pass of a @FOR loop; index counts from 0.
+func (t *Translator) traceIteration(stmt *ast.ForStatement, item any, index, count int) {
+	if t.trace == nil {
+		return
+	}
+	event := t.traceEvent(TraceIteration, stmt)
+	event.Directive = strings.ToUpper(stmt.Open.Lexeme)
+	event.Target = stmt.Target.Lexeme
+	event.Item = format.Value(item)
+	event.Index, event.Count = index+1, count
+	t.trace(event)
+}
+
+// traceInstruction records an emitted instruction, preceded by its interpolation if the arguments
+// had ${...} references.
+func (t *Translator) traceInstruction(stmt *ast.DockerStatement, instruction Instruction) {
+	if t.trace == nil {
+		return
+	}
+	if strings.Contains(stmt.Args, "${") {
+		event := t.traceEvent(TraceInterpolation, stmt)
+		event.Before, event.After = stmt.Args, instruction.Args
+		t.trace(event)
+	}
+	event := t.traceEvent(TraceInstruction, stmt)
+	event.Keyword, event.Args = instruction.Keyword, instruction.Args
+	t.trace(event)
+}
+
+func (t *Translator) traceEvent(kind TraceKind, node ast.Node) TraceEvent {
+	depth := 0
+	for s := t.Scope; s.Enclosing != nil; s = s.Enclosing {
+		depth++
+	}
+	pos := node.Pos()
+	return TraceEvent{Kind: kind, File: pos.File, Line: pos.Line, Depth: depth}
+}
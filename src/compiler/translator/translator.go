@@ -15,43 +15,133 @@ STATEMENT DISPATCH:
 IMMUTABILITY RULE:
 
 	llb.State is a value type. Every method returns a NEW state, never mutates in place.
+
+EXPRESSIONS:
+
+	Conditions, iterables and initializers are evaluated by the embedded evaluator.Evaluator,
+	the same implementation the Interpreter uses. Its Scope field is the innermost scope.
+
+INSTRUCTIONS:
+
+	Every Docker instruction reached after @IF selection and @FOR unrolling is recorded, with its
+	arguments interpolated, and can be read back with Instructions().
+
+LIMITS:
+
+	The Limits and Context of the embedded Evaluator bound the whole translation: statements stop
+	once Context ends, blocks count towards MaxDepth, @FOR items towards MaxTotalIterations and
+	instructions towards MaxInstructions. See evaluator.Limits.
+
+TRACE:
+
+	SetTrace reports each condition, loop iteration, interpolation and instruction as it happens,
+	see trace.go.
 */
 package translator
 
 import (
 	"docklett/compiler/ast"
+	compileError "docklett/compiler/error"
+	"docklett/compiler/evaluator"
+	"docklett/compiler/scope"
+	"errors"
 	"fmt"
 )
 
+// Compile-time check to ensure Translator implements ExpressionVisitor through the embedded Evaluator
+var _ ast.ExpressionVisitor = (*Translator)(nil)
+
 type Translator struct {
-	env         *Environment // variable scope
-	maxLoopIter int          // guard against infinite loop unrolling (default: 10000)
-	errors      []error      // collected translation errors
+	*evaluator.Evaluator                  // expression evaluation, Scope is the current variable scope
+	errors               []error          // collected translation errors
+	instructions         []Instruction    // emitted Docker instructions, in order
+	strictInterpolation  bool             // unresolved ${name} references are errors
+	dockerVariables      map[string]bool  // names declared by ARG and ENV instructions
+	trace                func(TraceEvent) // receives every decision in trace mode, see SetTrace
+}
+
+// Instruction is one Docker instruction produced by the translation.
+type Instruction struct {
+	Keyword string               // upper-case instruction verb, e.g. RUN
+	Args    string               // arguments after interpolation
+	Node    *ast.DockerStatement // statement that produced the instruction
+}
+
+func (in Instruction) String() string {
+	if in.Args == "" {
+		return in.Keyword
+	}
+	return in.Keyword + " " + in.Args
 }
 
 func NewTranslator() *Translator {
 	return &Translator{
-		env:         NewEnvironment(nil),
-		maxLoopIter: 10000,
+		Evaluator:       evaluator.New(scope.New(nil)),
+		dockerVariables: make(map[string]bool),
 	}
 }
 
+// Instructions returns the Docker instructions emitted so far.
+func (t *Translator) Instructions() []Instruction {
+	return t.instructions
+}
+
+// SetStrict enables strict mode: redefining a @SET variable in the same scope becomes an error.
+func (t *Translator) SetStrict(strict bool) {
+	t.Scope.Strict = strict
+}
+
+// SetStrictInterpolation makes a ${name} reference an error when name is neither a Docklett
+// variable nor declared by an earlier ARG or ENV instruction.
+func (t *Translator) SetStrictInterpolation(strict bool) {
+	t.strictInterpolation = strict
+}
+
+// SetVars binds build variables in the global scope. They are visible to the whole file and
+// replace the initializer of the @DEFAULT declaration with the same name.
+func (t *Translator) SetVars(vars map[string]any) {
+	global := t.Scope
+	for global.Enclosing != nil {
+		global = global.Enclosing
+	}
+	for name, val := range vars {
+		global.Define(name, val)
+	}
+	t.Vars = vars
+}
+
+// Errors returns the errors of the last Translate call, one per failing statement.
+func (t *Translator) Errors() []error {
+	return t.errors
+}
+
 // Translate processes the full AST and produces an LLB state graph.
-// Returns collected errors if any statement fails translation.
+// A failing statement does not stop translation: every error is collected and returned together,
+// so errors.As finds each typed error (UndefinedVariableError, RedefinitionError, ...).
+// Calling Translate again continues from the variables and instructions the previous call left,
+// as the REPL does; only the errors start afresh.
 func (t *Translator) Translate(statements []ast.Statement) error {
+	t.errors = nil
 	for _, stmt := range statements {
 		_, err := t.execute(stmt)
 		if err != nil {
 			t.errors = append(t.errors, err)
 		}
+		var limit *compileError.LimitError
+		if errors.As(err, &limit) {
+			break // the budget is spent for every later statement too
+		}
 	}
 	if len(t.errors) > 0 {
-		return fmt.Errorf("translation failed with %d error(s): %v", len(t.errors), t.errors)
+		return fmt.Errorf("translation failed with %d error(s): %w", len(t.errors), errors.Join(t.errors...))
 	}
 	return nil
 }
 
 // execute dispatches a statement to its corresponding visitor method
 func (t *Translator) execute(statement ast.Statement) (any, error) {
+	if err := t.CheckContext(statement); err != nil {
+		return nil, err
+	}
 	return statement.Accept(t)
 }
@@ -0,0 +1,579 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"docklett/compiler/builtin"
+	compileError "docklett/compiler/error"
+	"docklett/compiler/evaluator"
+	"docklett/compiler/parser"
+	"docklett/compiler/scanner"
+)
+
+func translateSource(t *testing.T, source string, strict bool) *Translator {
+	t.Helper()
+
+	s := scanner.Scanner{SourceName: "test.dock", Source: source}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	var p parser.Parser
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	tr := NewTranslator()
+	tr.SetStrict(strict)
+	_ = tr.Translate(statements)
+	return tr
+}
+
+func TestTranslate_ConstantDiagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		strict bool
+		line   int // 0: no error expected
+	}{
+		{"redefine constant", "@CONST BASE = \"alpine\"\n@SET BASE = \"debian\"\n", false, 2},
+		{"assign constant", "@CONST BASE = \"alpine\"\nBASE = \"debian\"\n", false, 2},
+		{"redefine variable", "@SET TAG = \"1\"\n@SET TAG = \"2\"\n", false, 0},
+		{"redefine variable strict", "@SET TAG = \"1\"\n@SET TAG = \"2\"\n", true, 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := translateSource(t, tc.source, tc.strict)
+			if tc.line == 0 {
+				if len(tr.errors) != 0 {
+					t.Errorf("unexpected errors: %v", tr.errors)
+				}
+				return
+			}
+			if len(tr.errors) != 1 {
+				t.Fatalf("errors = %v, want exactly one", tr.errors)
+			}
+			var redefinition *compileError.RedefinitionError
+			if !errors.As(tr.errors[0], &redefinition) {
+				t.Fatalf("error = %v, want *RedefinitionError", tr.errors[0])
+			}
+			if redefinition.Name.Line != tc.line || redefinition.Previous.Line != 1 {
+				t.Errorf("redefinition at line %d (previous %d), want line %d (previous 1)",
+					redefinition.Name.Line, redefinition.Previous.Line, tc.line)
+			}
+		})
+	}
+}
+
+func TestTranslate_CollectsUndefinedVariables(t *testing.T) {
+	tr := translateSource(t, "@SET A = B\nFROM alpine\n@SET C = D\n", false)
+
+	if len(tr.errors) != 2 {
+		t.Fatalf("errors = %v, want 2", tr.errors)
+	}
+	for i, want := range []struct {
+		name string
+		line int
+	}{{"B", 1}, {"D", 3}} {
+		var undefined *compileError.UndefinedVariableError
+		if !errors.As(tr.errors[i], &undefined) {
+			t.Fatalf("error %d = %v, want *UndefinedVariableError", i, tr.errors[i])
+		}
+		if undefined.Name.Lexeme != want.name || undefined.Name.Line != want.line {
+			t.Errorf("error %d: undefined %q at line %d, want %q at line %d",
+				i, undefined.Name.Lexeme, undefined.Name.Line, want.name, want.line)
+		}
+	}
+}
+
+func TestTranslate_ControlFlow(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			"if selects the matching branch",
+			"@SET MODE = \"dev\"\n@IF MODE == \"prod\"\nRUN prod\n@ELIF MODE == \"dev\"\nRUN dev\n@ELSE\nRUN other\n@END\n",
+			[]string{"RUN dev"},
+		},
+		{
+			"if without else",
+			"@IF FALSE\nRUN never\n@END\nRUN always\n",
+			[]string{"RUN always"},
+		},
+		{
+			"for over array",
+			"@FOR pkg IN [\"curl\", \"git\"]\nRUN apk add ${pkg}\n@END\n",
+			[]string{"RUN apk add curl", "RUN apk add git"},
+		},
+		{
+			"for over negative range",
+			"@FOR i IN range(3, 0, -1)\nRUN echo ${i}\n@END\n",
+			[]string{"RUN echo 3", "RUN echo 2", "RUN echo 1"},
+		},
+		{
+			"loop variable does not clobber the enclosing scope",
+			"@SET i = \"outer\"\n@FOR i IN [1]\nRUN echo ${i}\n@END\nRUN echo ${i}\n",
+			[]string{"RUN echo 1", "RUN echo outer"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := translateSource(t, tc.source, false)
+			if len(tr.errors) != 0 {
+				t.Fatalf("errors: %v", tr.errors)
+			}
+			var got []string
+			for _, instruction := range tr.Instructions() {
+				got = append(got, instruction.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("instructions = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTranslate_EnforcesTypeAnnotations(t *testing.T) {
+	tr := translateSource(t, "@SET pkgs: [string] = [\"curl\", 1]\n@SET port: float = 80\n", false)
+	if len(tr.errors) != 1 {
+		t.Fatalf("errors = %v, want exactly one", tr.errors)
+	}
+	var typeErr *compileError.TypeError
+	if !errors.As(tr.errors[0], &typeErr) || typeErr.GetLine() != 1 {
+		t.Errorf("error = %v, want *TypeError at line 1", tr.errors[0])
+	}
+}
+
+func TestTranslate_Vars(t *testing.T) {
+	source := "@DEFAULT MODE = \"dev\"\n@DEFAULT PORT: int = 80\n@DEFAULT TAG = undefined_call()\n" +
+		"RUN echo ${MODE} ${PORT} ${TAG} ${REGION}\n"
+	s := scanner.Scanner{SourceName: "test.dock", Source: source}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	var p parser.Parser
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	tr := NewTranslator()
+	// overridden initializers are never evaluated; REGION has no @DEFAULT but is visible too.
+	// MODE and PORT are converted to the type of their @DEFAULT.
+	tr.SetVars(map[string]any{"MODE": 7, "PORT": "08080", "TAG": "1.2", "REGION": "eu"})
+	if err := tr.Translate(statements); err != nil {
+		t.Fatalf("translate: %v", err)
+	}
+	if got := tr.Instructions()[0].String(); got != "RUN echo 7 8080 1.2 eu" {
+		t.Errorf("instruction = %q", got)
+	}
+
+	tr = NewTranslator()
+	tr.SetVars(map[string]any{"PORT": "http"})
+	_ = tr.Translate(statements[1:2])
+	var typeErr *compileError.TypeError
+	if len(tr.errors) != 1 || !errors.As(tr.errors[0], &typeErr) {
+		t.Errorf("errors = %v, want a *TypeError for PORT", tr.errors)
+	}
+}
+
+func TestTranslate_Interpolation(t *testing.T) {
+	source := "@SET BASE = \"alpine\"\n" +
+		"@SET N = 2 + 3\n" +
+		"FROM ${BASE}\n" +
+		"@FOR p IN [\"curl\", \"git\"]\n" +
+		"@IF TRUE\n" +
+		"RUN apk add ${p} ${N} ${BASE}\n" +
+		"@END\n" +
+		"@END\n" +
+		"RUN echo $${HOME} ${HOME} ${TAG:-latest}\n"
+	tr := translateSource(t, source, false)
+	if len(tr.errors) != 0 {
+		t.Fatalf("unexpected errors: %v", tr.errors)
+	}
+
+	var got []string
+	for _, in := range tr.Instructions() {
+		got = append(got, in.String())
+	}
+	want := []string{
+		"FROM alpine",
+		"RUN apk add curl 5 alpine",
+		"RUN apk add git 5 alpine",
+		"RUN echo ${HOME} ${HOME} ${TAG:-latest}",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("instructions:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTranslate_StrictInterpolation(t *testing.T) {
+	source := "ARG VERSION=1\nENV LANG C.UTF-8\n@SET NAME = \"api\"\nRUN echo ${VERSION} ${LANG} ${NAME} $${LATER}\nRUN echo ${VERSON}\n"
+	s := scanner.Scanner{SourceName: "test.dock", Source: source}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan source: %v", err)
+	}
+	var p parser.Parser
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	tr := NewTranslator()
+	tr.SetStrictInterpolation(true)
+	err = tr.Translate(statements)
+	var evalErr *compileError.EvaluationError
+	if len(tr.errors) != 1 || !errors.As(tr.errors[0], &evalErr) || evalErr.GetLine() != 5 {
+		t.Fatalf("errors = %v, want one evaluation error at line 5", err)
+	}
+	// the error points at the name of the reference, not at the instruction
+	if location := evalErr.GetLocation(); location != "file test.dock, line 5, column 12" {
+		t.Errorf("location = %q, want line 5, column 12", location)
+	}
+}
+
+func TestTranslate_TemplateCalls(t *testing.T) {
+	source := "@SET NAME = \"Café\"\n" +
+		"@SET BUILD = 7\n" +
+		"@IF startswith(lower(NAME), \"caf\")\n" +
+		"LABEL name=${lower(NAME)} tag=${format(\"%s-%s\", replace(NAME, \"é\", \"e\"), pad(BUILD, 3, \"0\"))}\n" +
+		"@END\n" +
+		"RUN echo ${upper(NAME)} ${HOME}\n"
+	tr := translateSource(t, source, false)
+	if len(tr.errors) != 0 {
+		t.Fatalf("unexpected errors: %v", tr.errors)
+	}
+
+	var got []string
+	for _, in := range tr.Instructions() {
+		got = append(got, in.String())
+	}
+	want := []string{
+		"LABEL name=café tag=Cafe-007",
+		"RUN echo CAFÉ ${HOME}",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("instructions:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTranslate_OptionalVariables(t *testing.T) {
+	source := "@SET BASE = \"ubuntu:22.04\"\n" +
+		"@IF defined(CUDA_VERSION)\n" +
+		"FROM nvidia/cuda:${CUDA_VERSION}\n" +
+		"@ELSE\n" +
+		"FROM ${BASE}\n" +
+		"@END\n" +
+		"RUN echo ${PYTHON ?? \"3.12\"} ${defined(BASE)} ${CUDA_VERSION:-none}\n"
+	tr := translateSource(t, source, false)
+	if len(tr.errors) != 0 {
+		t.Fatalf("unexpected errors: %v", tr.errors)
+	}
+
+	var got []string
+	for _, in := range tr.Instructions() {
+		got = append(got, in.String())
+	}
+	want := []string{
+		"FROM ubuntu:22.04",
+		"RUN echo 3.12 true ${CUDA_VERSION:-none}",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("instructions:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTranslate_Semver(t *testing.T) {
+	source := "@SET NODE = semver(\"v20.11.1\")\n" +
+		"@IF NODE >= \"18.0.0\" && satisfies(NODE, \"^20\")\n" +
+		"FROM node:${NODE.major}-alpine\n" +
+		"@END\n" +
+		"@IF NODE < \"9.0.0\"\n" +
+		"RUN legacy\n" +
+		"@END\n" +
+		"LABEL node=${NODE}\n"
+	tr := translateSource(t, source, false)
+	if len(tr.errors) != 0 {
+		t.Fatalf("unexpected errors: %v", tr.errors)
+	}
+
+	var got []string
+	for _, in := range tr.Instructions() {
+		got = append(got, in.String())
+	}
+	want := []string{
+		"FROM node:20-alpine",
+		"LABEL node=20.11.1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("instructions:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTranslate_Files(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{"package-lock.json": "{}", "apk.txt": "curl\ngit\n"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source := "@IF file_exists(\"package-lock.json\") && !file_exists(\"yarn.lock\")\n" +
+		"RUN npm ci\n" +
+		"@END\n" +
+		"@FOR f IN glob(\"*.txt\")\n" +
+		"RUN apk add ${join(read_lines(f), \" \")}\n" +
+		"@END\n" +
+		"RUN cat ${read_file(\"../secret\")}\n"
+
+	s := scanner.Scanner{SourceName: "test.dock", Source: source}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	var p parser.Parser
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	tr := NewTranslator()
+	tr.Host = builtin.NewHost(root)
+	_ = tr.Translate(statements)
+
+	var got []string
+	for _, in := range tr.Instructions() {
+		got = append(got, in.String())
+	}
+	want := []string{"RUN npm ci", "RUN apk add curl git"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("instructions:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	var located compileError.CompileError
+	if len(tr.errors) != 1 || !errors.As(tr.errors[0], &located) ||
+		located.Error() != `Compile Error: [line 7] read_file() "../secret" is outside the build context` {
+		t.Errorf("errors = %v, want the escape reported at line 7", tr.errors)
+	}
+	if inputs := tr.Host.Inputs(); len(inputs) != 1 || inputs[0] != "apk.txt" {
+		t.Errorf("inputs = %v, want [apk.txt]", inputs)
+	}
+}
+
+func TestTranslate_Env(t *testing.T) {
+	s := scanner.Scanner{SourceName: "test.dock", Source: "LABEL rev=${env(\"CI_COMMIT_SHA\", \"dev\")} build=${env(\"BUILD_NUMBER\", \"0\")}\n"}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	var p parser.Parser
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	tr := NewTranslator()
+	tr.Host = builtin.NewHost(t.TempDir())
+	tr.Host.Env = func(name string) (string, bool) { return "4f2e9c1", name == "CI_COMMIT_SHA" }
+	tr.Host.AllowEnv = []string{"CI_COMMIT_SHA", "BUILD_NUMBER"}
+	if err := tr.Translate(statements); err != nil {
+		t.Fatalf("translate: %v", err)
+	}
+
+	if got := tr.Instructions()[0].String(); got != "LABEL rev=4f2e9c1 build=0" {
+		t.Errorf("instruction = %q", got)
+	}
+	if got := tr.Host.EnvVars(); strings.Join(got, ",") != "BUILD_NUMBER,CI_COMMIT_SHA" {
+		t.Errorf("EnvVars() = %v", got)
+	}
+}
+
+func TestTranslate_TemplateCallErrors(t *testing.T) {
+	tests := []struct {
+		args     string
+		location string
+		message  string
+	}{
+		{`echo ${upper(1)}`, "file test.dock, line 1, column 12", "Compile Error: [line 1] upper() argument 1 must be string, got int"},
+		{`echo é ${pad("x", -2, "ab")}`, "file test.dock, line 1, column 14", `Compile Error: [line 1] pad() fill must be a single character, got "ab"`},
+		{`echo ${pad("", -1)}`, "file test.dock, line 1, column 12", "Compile Error: [line 1] pad() width must not be negative, got -1"},
+		{`echo ${chunk([1], 0)}`, "file test.dock, line 1, column 12", "Compile Error: [line 1] chunk() size must be positive, got 0"},
+		{`echo ${upper(MISSING)}`, "file test.dock, line 1, column 18", "Compile Error: [line 1] undefined variable 'MISSING'"},
+		{`echo ${upper("a" "b")}`, "at '\"b\"'", "Compile Error: [line 1] Expected ')' after arguments."},
+	}
+	for _, tc := range tests {
+		t.Run(tc.args, func(t *testing.T) {
+			tr := translateSource(t, "RUN "+tc.args+"\n", false)
+			if len(tr.errors) != 1 {
+				t.Fatalf("errors = %v, want one", tr.errors)
+			}
+			var located compileError.CompileError
+			if !errors.As(tr.errors[0], &located) {
+				t.Fatalf("error = %v, want a compile error", tr.errors[0])
+			}
+			if located.Error() != tc.message || located.GetLocation() != tc.location {
+				t.Errorf("error = %q at %q, want %q at %q", located.Error(), located.GetLocation(), tc.message, tc.location)
+			}
+		})
+	}
+}
+
+func TestTranslate_Limits(t *testing.T) {
+	nested := "@FOR i IN range(0, 10)\n@FOR j IN range(0, 10)\n@SET k = [x for x in range(0, 5)]\n@END\n@END\n"
+	tests := []struct {
+		name    string
+		source  string
+		limits  evaluator.Limits
+		message string
+	}{
+		{"unlimited", nested, evaluator.Limits{}, ""},
+		{"total iterations across nesting", nested, evaluator.Limits{MaxTotalIterations: 500},
+			"Compile Error: [line 3] compilation exceeded the limit of 500 loop iterations in total"},
+		{"iterations within budget", nested, evaluator.Limits{MaxTotalIterations: 610}, ""},
+		{"nesting", "@IF TRUE\n@IF TRUE\n@SET x = (((1)))\n@END\n@END\n", evaluator.Limits{MaxDepth: 4},
+			"Compile Error: [line 3] compilation exceeded the nesting limit of 4"},
+		{"instructions", "@FOR i IN range(0, 3)\nRUN echo ${i}\n@END\n", evaluator.Limits{MaxInstructions: 2},
+			"Compile Error: [line 2] compilation exceeded the limit of 2 Docker instructions"},
+		{"string length", "@SET s = \"ab\"\n@FOR i IN range(0, 8)\ns = s + s\n@END\n", evaluator.Limits{MaxStringLength: 100},
+			"Compile Error: [line 3] string of 128 bytes exceeds the limit of 100 bytes"},
+		{"interpolated instruction", "@SET s = \"abcdef\"\nRUN echo ${s}${s}\n", evaluator.Limits{MaxStringLength: 12},
+			"Compile Error: [line 2] string of 17 bytes exceeds the limit of 12 bytes"},
+		{"pad width", "RUN echo ${pad(\"\", 9223372036854775807)}\n", evaluator.SandboxLimits,
+			"Compile Error: [line 1] pad() result exceeds the limit of 1048576 bytes"},
+		{"pad without limits", "@SET s = pad(\"\", 9223372036854775807)\n", evaluator.Limits{},
+			"Compile Error: [line 1] pad() result exceeds the limit of 268435456 bytes"},
+		{"format precision", "@SET s = format(\"%.999999999f\", 1.5)\n", evaluator.SandboxLimits,
+			"Compile Error: [line 1] format() result exceeds the limit of 1048576 bytes"},
+		{"join", "@SET s = join([pad(\"\", 1000) for i in range(0, 2000)], \"\")\n", evaluator.SandboxLimits,
+			"Compile Error: [line 1] join() result exceeds the limit of 1048576 bytes"},
+		{"range", "@SET r = range(0, 9223372036854775807)\n", evaluator.Limits{MaxCollectionLength: 1000},
+			"Compile Error: [line 1] range of 9223372036854775807 elements exceeds the limit of 1000 elements"},
+		{"map", "@SET m = {\"a\": 1, \"b\": 2}\n", evaluator.Limits{MaxCollectionLength: 1},
+			"Compile Error: [line 1] map of 2 entries exceeds the limit of 1 entries"},
+		{"files", "@IF file_exists(\"go.mod\")\n@END\n", evaluator.Limits{NoFiles: true},
+			"Compile Error: [line 1] file_exists() is disabled: this compilation may not read the build context"},
+		{"env", "RUN echo ${env(\"HOME\", \"\")}\n", evaluator.Limits{NoEnv: true},
+			"Compile Error: [line 1] env() is disabled: this compilation may not read environment variables"},
+		{"first exceeded budget stops", "RUN a\nRUN b\nRUN c\n", evaluator.Limits{MaxInstructions: 1},
+			"Compile Error: [line 2] compilation exceeded the limit of 1 Docker instructions"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := scanner.Scanner{SourceName: "test.dock", Source: tc.source}
+			if err := s.ScanSource(); err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			var p parser.Parser
+			statements, err := p.Parse(s.Tokens)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			tr := NewTranslator()
+			tr.Limits = tc.limits
+			tr.Host = builtin.NewHost(t.TempDir())
+			tr.Host.AllowEnv = []string{"*"}
+			tr.Host.NoFiles, tr.Host.NoEnv = tc.limits.NoFiles, tc.limits.NoEnv
+			_ = tr.Translate(statements)
+
+			if tc.message == "" {
+				if len(tr.errors) != 0 {
+					t.Errorf("errors = %v, want none", tr.errors)
+				}
+				return
+			}
+			var limit *compileError.LimitError
+			if len(tr.errors) != 1 || !errors.As(tr.errors[0], &limit) || limit.Error() != tc.message {
+				t.Errorf("errors = %v, want one LimitError %q", tr.errors, tc.message)
+			}
+		})
+	}
+}
+
+func TestTranslate_Deadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	tr := NewTranslator()
+	tr.Context = ctx
+	tr.Limits = evaluator.Limits{}
+	s := scanner.Scanner{SourceName: "test.dock", Source: "FROM alpine\nRUN echo a\n"}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	var p parser.Parser
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	err = tr.Translate(statements)
+	if !errors.Is(err, context.DeadlineExceeded) || len(tr.errors) != 1 || len(tr.Instructions()) != 0 {
+		t.Fatalf("error = %v, want one deadline error and no instructions", err)
+	}
+	if !strings.Contains(err.Error(), "[line 1] compilation exceeded its deadline") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestTranslate_Trace(t *testing.T) {
+	source := "@SET MODE = \"dev\"\n" +
+		"@IF MODE == \"prod\"\nRUN prod\n" +
+		"@ELIF MODE == \"dev\"\n" +
+		"@FOR pkg IN [\"curl\", 2]\nRUN apk add ${pkg}\n@END\n" +
+		"@END\n" +
+		"EXPOSE 80\n"
+	want := []string{
+		`test.dock:2 depth=0 condition @IF MODE == "prod" → FALSE, not taken`,
+		`test.dock:4 depth=0 condition @ELIF MODE == "dev" → TRUE, taken`,
+		`test.dock:5 depth=2 iteration @FOR pkg = "curl" (1 of 2)`,
+		`test.dock:6 depth=3 interpolation apk add ${pkg} → apk add curl`,
+		`test.dock:6 depth=3 instruction RUN apk add curl`,
+		`test.dock:5 depth=2 iteration @FOR pkg = 2 (2 of 2)`,
+		`test.dock:6 depth=3 interpolation apk add ${pkg} → apk add 2`,
+		`test.dock:6 depth=3 instruction RUN apk add 2`,
+		`test.dock:9 depth=0 instruction EXPOSE 80`,
+	}
+
+	s := scanner.Scanner{SourceName: "test.dock", Source: source}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	var p parser.Parser
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var text, lines strings.Builder
+	tr := NewTranslator()
+	tr.SetTrace(TextTrace(&text))
+	if err := tr.Translate(statements); err != nil {
+		t.Fatalf("translate: %v", err)
+	}
+	if got := strings.TrimSuffix(text.String(), "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("text trace =\n%s\nwant\n%s", got, strings.Join(want, "\n"))
+	}
+
+	tr = NewTranslator()
+	tr.SetTrace(JSONTrace(&lines))
+	if err := tr.Translate(statements); err != nil {
+		t.Fatalf("translate: %v", err)
+	}
+	wantJSON := []string{
+		`{"depth":0,"directive":"@IF","event":"condition","expression":"MODE == \"prod\"","file":"test.dock","line":2,"taken":false,"value":"FALSE"}`,
+		`{"bindings":{"pkg":"\"curl\""},"count":2,"depth":2,"directive":"@FOR","event":"iteration","file":"test.dock","index":1,"line":5}`,
+		`{"after":"apk add curl","before":"apk add ${pkg}","depth":3,"event":"interpolation","file":"test.dock","line":6}`,
+		`{"args":"80","depth":0,"event":"instruction","file":"test.dock","keyword":"EXPOSE","line":9}`,
+	}
+	got := strings.Split(lines.String(), "\n")
+	for _, line := range wantJSON {
+		if !slices.Contains(got, line) {
+			t.Errorf("json trace has no line %s in\n%s", line, lines.String())
+		}
+	}
+}
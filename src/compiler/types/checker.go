@@ -0,0 +1,471 @@
+/*
+The type checker is a static pass that runs after the resolver. Like the resolver it visits every
+branch and loop body, and it opens scopes the same way the Translator does at evaluation time.
+
+INFERENCE:
+Unannotated bindings take the type of their initializer; annotated ones keep their declared type and
+every later assignment is checked against it:
+
+	@SET port: int = 8080       declared int
+	@SET name = "api"           inferred string
+	@SET pkgs = ["curl", "git"] inferred [string]
+	@FOR p IN pkgs              p: string
+	port = "80"                 error: cannot assign string to 'port' (declared int)
+	@IF name == port            error: cannot compare string with int
+
+Anything the checker cannot know (predeclared names, mixed arrays) is "any" and is left to the evaluator.
+
+TRUTHINESS:
+By default @IF, @ELIF and the operands of && and || accept any value through implicit truthiness.
+Config.NoImplicitTruthiness restricts them to bool, so @IF COUNT has to be written @IF COUNT > 0.
+*/
+package types
+
+import (
+	"docklett/compiler/ast"
+	"docklett/compiler/builtin"
+	compileError "docklett/compiler/error"
+	"docklett/compiler/interpolate"
+	"docklett/compiler/parser"
+	"docklett/compiler/token"
+	"errors"
+	"fmt"
+)
+
+// Compile-time check to ensure Checker implements both visitors
+var _ ast.StatementVisitor = (*Checker)(nil)
+var _ ast.ExpressionVisitor = (*Checker)(nil)
+
+// Config selects optional checks.
+type Config struct {
+	NoImplicitTruthiness bool           // conditions and logical operands must be bool
+	Vars                 map[string]any // build variables, typed by their values; see @DEFAULT
+}
+
+// Info records the result of type checking.
+type Info struct {
+	Types map[ast.Expression]*Type // type of every checked expression
+}
+
+// binding is the static type of one variable.
+type binding struct {
+	typ      *Type
+	declared bool // typ comes from an annotation and constrains assignments
+}
+
+type Checker struct {
+	config Config
+	scopes []map[string]*binding // innermost scope last
+	info   *Info
+	errors []error
+}
+
+// Check type-checks statements and returns every type error, joined.
+func Check(statements []ast.Statement, config Config) (*Info, error) {
+	c := &Checker{config: config, info: &Info{Types: make(map[ast.Expression]*Type)}}
+	c.beginScope()
+	for name, val := range config.Vars {
+		c.scopes[0][name] = &binding{typ: Of(val)}
+	}
+	for _, stmt := range statements {
+		stmt.Accept(c)
+	}
+	return c.info, errors.Join(c.errors...)
+}
+
+func (c *Checker) beginScope() {
+	c.scopes = append(c.scopes, make(map[string]*binding))
+}
+
+func (c *Checker) endScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+func (c *Checker) lookup(name string) *binding {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if b, ok := c.scopes[i][name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+func (c *Checker) report(node ast.Node, format string, args ...any) {
+	c.errors = append(c.errors, compileError.NewTypeError(node, fmt.Sprintf(format, args...)))
+}
+
+// typeOf checks expr and returns its type; nil expressions are "any".
+func (c *Checker) typeOf(expr ast.Expression) *Type {
+	if expr == nil {
+		return AnyType
+	}
+	result, _ := expr.Accept(c)
+	t, ok := result.(*Type)
+	if !ok {
+		t = AnyType
+	}
+	c.info.Types[expr] = t
+	return t
+}
+
+// condition checks a value used for its truthiness.
+func (c *Checker) condition(expr ast.Expression, use string) *Type {
+	t := c.typeOf(expr)
+	if c.config.NoImplicitTruthiness && t.Kind != Bool && t.Kind != Any {
+		c.report(expr, "%s must be bool, got %s (implicit truthiness is disabled)", use, t)
+	}
+	return t
+}
+
+func (c *Checker) VisitExpressionStatement(stmt *ast.ExpressionStatement) (any, error) {
+	c.typeOf(stmt.Expression)
+	return nil, nil
+}
+
+func (c *Checker) VisitVarDeclarationStatement(stmt *ast.VariableDeclarationStatement) (any, error) {
+	var initType *Type = AnyType
+	if stmt.Initializer != nil {
+		initType = c.typeOf(stmt.Initializer)
+	}
+
+	b := &binding{typ: initType}
+	if stmt.Type != nil {
+		declared, err := FromAnnotation(stmt.Type)
+		if err != nil {
+			c.report(stmt.Type, "%v", err)
+			declared = AnyType
+		}
+		if stmt.Initializer != nil && !AssignableTo(initType, declared) {
+			c.report(stmt.Initializer, "cannot use %s as %s in declaration of '%s'", initType, declared, stmt.Name.Lexeme)
+		}
+		b = &binding{typ: declared, declared: true}
+	}
+	// a build variable replaces the default, so the binding has the type of the value given
+	if val, ok := c.config.Vars[stmt.Name.Lexeme]; ok && stmt.IsDefault() {
+		val = BuildVariable(stmt, val)
+		if b.declared && !Conforms(val, b.typ) {
+			c.report(stmt.Type, "cannot use %s value of build variable '%s' as %s", Of(val), stmt.Name.Lexeme, b.typ)
+		} else if !b.declared {
+			b = &binding{typ: Of(val)}
+		}
+	}
+	c.scopes[len(c.scopes)-1][stmt.Name.Lexeme] = b
+	return nil, nil
+}
+
+func (c *Checker) VisitBlockStatement(stmt *ast.BlockStatement) (any, error) {
+	c.beginScope()
+	for _, s := range stmt.Statements {
+		s.Accept(c)
+	}
+	c.endScope()
+	return nil, nil
+}
+
+func (c *Checker) VisitIfStatement(stmt *ast.IfStatement) (any, error) {
+	c.condition(stmt.Condition, "condition")
+	stmt.ThenBranch.Accept(c)
+	if stmt.ElseBranch != nil {
+		stmt.ElseBranch.Accept(c)
+	}
+	return nil, nil
+}
+
+// VisitDockerStatement checks the ${call(...)} expressions interpolation evaluates. A plain ${name}
+// is printed whatever its type, and a reference that does not parse is the resolver's error.
+func (c *Checker) VisitDockerStatement(stmt *ast.DockerStatement) (any, error) {
+	for _, ref := range interpolate.References(stmt.Args) {
+		if ref.Expression == "" {
+			continue
+		}
+		if expr, err := parser.ParseReference(stmt, ref); err == nil {
+			c.typeOf(expr)
+		}
+	}
+	return nil, nil
+}
+
+func (c *Checker) VisitForStatement(stmt *ast.ForStatement) (any, error) {
+	iterable := c.typeOf(stmt.Iterable)
+	elem := AnyType
+	switch iterable.Kind {
+	case Array:
+		elem = iterable.Elem
+	case Any:
+	default:
+		c.report(stmt.Iterable, "cannot iterate over %s, @FOR needs an array or range", iterable)
+	}
+
+	c.beginScope()
+	c.scopes[len(c.scopes)-1][stmt.Target.Lexeme] = &binding{typ: elem}
+	stmt.Body.Accept(c)
+	c.endScope()
+	return nil, nil
+}
+
+func (c *Checker) VisitLiteralExpr(literal *ast.LiteralExpression) (any, error) {
+	return Of(literal.Value), nil
+}
+
+// VisitVariableExpr returns the binding's type; unknown names are "any" (the resolver reports them).
+func (c *Checker) VisitVariableExpr(variable *ast.VariableExpression) (any, error) {
+	if b := c.lookup(variable.Name.Lexeme); b != nil {
+		return b.typ, nil
+	}
+	return AnyType, nil
+}
+
+func (c *Checker) VisitUnaryExpr(unary *ast.UnaryExpression) (any, error) {
+	right := c.typeOf(unary.Right)
+	switch unary.Operator.Type {
+	case token.NEGATE:
+		if right.Kind != Bool && right.Kind != Any {
+			c.report(unary, "operator ! requires bool, got %s", right)
+		}
+		return BoolType, nil
+	case token.SUBTRACT:
+		if !right.IsNumeric() && right.Kind != Any {
+			c.report(unary, "operator - requires a number, got %s", right)
+			return AnyType, nil
+		}
+		return right, nil
+	}
+	return AnyType, nil
+}
+
+func (c *Checker) VisitGroupingExpr(grouping *ast.GroupingExpression) (any, error) {
+	return c.typeOf(grouping.Expression), nil
+}
+
+// VisitBinaryExpr mirrors the evaluator's operator rules, see package evaluator.
+func (c *Checker) VisitBinaryExpr(binary *ast.BinaryExpression) (any, error) {
+	left, right := c.typeOf(binary.Left), c.typeOf(binary.Right)
+	op := binary.Operator
+	unknown := left.Kind == Any || right.Kind == Any
+	numbers := left.IsNumeric() && right.IsNumeric()
+	strs := left.Kind == String && right.Kind == String
+	// int with int stays int, except for "/"
+	arithmetic := FloatType
+	if left.Kind == Int && right.Kind == Int && op.Type != token.DIVIDE {
+		arithmetic = IntType
+	}
+
+	switch op.Type {
+	case token.EQUAL, token.UNEQUAL:
+		if !Comparable(left, right) {
+			c.report(binary, "cannot compare %s with %s", left, right)
+		}
+		return BoolType, nil
+
+	case token.GREATER, token.GTE, token.LESS, token.LTE:
+		if !Ordered(left, right) {
+			c.report(binary, "cannot compare %s with %s using %s", left, right, op.Lexeme)
+		}
+		return BoolType, nil
+
+	case token.IN:
+		switch right.Kind {
+		case Array:
+			if !Comparable(left, right.Elem) {
+				c.report(binary, "%s is never in %s", left, right)
+			}
+		case Map, String:
+			if left.Kind != String && left.Kind != Any {
+				c.report(binary, "'in' %s needs a string on the left, got %s", right, left)
+			}
+		case Any:
+		default:
+			c.report(binary, "'in' needs an array, map or string on the right, got %s", right)
+		}
+		return BoolType, nil
+
+	case token.ADD:
+		switch {
+		case numbers:
+			return arithmetic, nil
+		case strs:
+			return StringType, nil
+		case unknown:
+			return AnyType, nil
+		}
+		c.report(binary, "invalid operation: %s + %s", left, right)
+		return AnyType, nil
+
+	case token.SUBTRACT, token.MULTI, token.DIVIDE, token.FLOOR_DIV, token.MODULO:
+		if numbers {
+			return arithmetic, nil
+		}
+		if !unknown || left.Kind == String || right.Kind == String {
+			c.report(binary, "invalid operation: %s %s %s", left, op.Lexeme, right)
+		}
+		return AnyType, nil
+	}
+	return AnyType, nil
+}
+
+// VisitLogicalExpr types "??" as either operand, since the left one may be nil or undefined.
+func (c *Checker) VisitLogicalExpr(logical *ast.LogicalExpression) (any, error) {
+	if logical.Operator.Type == token.COALESCE {
+		return Join(c.typeOf(logical.Left), c.typeOf(logical.Right)), nil
+	}
+	use := fmt.Sprintf("operand of %s", logical.Operator.Lexeme)
+	left := c.condition(logical.Left, use)
+	right := c.condition(logical.Right, use)
+	return Join(left, right), nil
+}
+
+func (c *Checker) VisitAssignmentExpr(assignment *ast.AssignmentExpression) (any, error) {
+	valueType := c.typeOf(assignment.Value)
+	b := c.lookup(assignment.Name.Lexeme)
+	switch {
+	case b == nil:
+	case b.declared:
+		if !AssignableTo(valueType, b.typ) {
+			c.report(assignment.Value, "cannot assign %s to '%s' (declared %s)", valueType, assignment.Name.Lexeme, b.typ)
+		}
+	case !Identical(b.typ, valueType):
+		// an unannotated binding that changes type can only be known at evaluation time
+		b.typ = AnyType
+	}
+	return valueType, nil
+}
+
+func (c *Checker) VisitArrayLiteralExpr(array *ast.ArrayLiteralExpression) (any, error) {
+	if len(array.Elements) == 0 {
+		return ArrayOf(AnyType), nil
+	}
+	elem := c.typeOf(array.Elements[0])
+	for _, e := range array.Elements[1:] {
+		elem = Join(elem, c.typeOf(e))
+	}
+	return ArrayOf(elem), nil
+}
+
+func (c *Checker) VisitMapLiteralExpr(mapLiteral *ast.MapLiteralExpression) (any, error) {
+	elem := AnyType
+	for i, key := range mapLiteral.Keys {
+		if t := c.typeOf(key); t.Kind != String && t.Kind != Any {
+			c.report(key, "map key must be a string, got %s", t)
+		}
+		if valueType := c.typeOf(mapLiteral.Values[i]); i == 0 {
+			elem = valueType
+		} else {
+			elem = Join(elem, valueType)
+		}
+	}
+	return MapOf(elem), nil
+}
+
+// VisitComprehensionExpr types the targets from the iterable: elements (or an int index and the
+// element) for arrays, keys (or the key and the value) for maps.
+func (c *Checker) VisitComprehensionExpr(comprehension *ast.ComprehensionExpression) (any, error) {
+	iterable := c.typeOf(comprehension.Iterable)
+	targets := []*Type{AnyType, AnyType}
+	switch iterable.Kind {
+	case Array:
+		targets = []*Type{IntType, iterable.Elem}
+		if len(comprehension.Targets) == 1 {
+			targets[0] = iterable.Elem
+		}
+	case Map:
+		targets = []*Type{StringType, iterable.Elem}
+	case Any:
+	default:
+		c.report(comprehension.Iterable, "cannot iterate over %s, a comprehension needs an array, range or map", iterable)
+	}
+
+	c.beginScope()
+	for i, target := range comprehension.Targets {
+		c.scopes[len(c.scopes)-1][target.Lexeme] = &binding{typ: targets[i]}
+	}
+	if comprehension.Filter != nil {
+		c.condition(comprehension.Filter, "comprehension filter")
+	}
+	elem := c.typeOf(comprehension.Element)
+	c.endScope()
+	return ArrayOf(elem), nil
+}
+
+func (c *Checker) VisitRangeExpr(rangeExpr *ast.RangeExpression) (any, error) {
+	for _, arg := range []ast.Expression{rangeExpr.Start, rangeExpr.Stop, rangeExpr.Step} {
+		if arg == nil {
+			continue
+		}
+		if t := c.typeOf(arg); !t.IsNumeric() && t.Kind != Any {
+			c.report(arg, "range arguments must be numbers, got %s", t)
+		}
+	}
+	return ArrayOf(IntType), nil
+}
+
+// VisitCallExpr checks a built-in call against its signature in package builtin.
+// An "int" parameter accepts any number, as at evaluation time, where whole floats are converted.
+func (c *Checker) VisitCallExpr(call *ast.CallExpression) (any, error) {
+	if f, ok := builtin.Lookup(call.Callee.Lexeme); ok && f.ByName {
+		return c.byName(f, call), nil
+	}
+	argTypes := make([]*Type, len(call.Arguments))
+	for i, arg := range call.Arguments {
+		argTypes[i] = c.typeOf(arg)
+	}
+
+	f, ok := builtin.Lookup(call.Callee.Lexeme)
+	if !ok {
+		c.report(call, "undefined function '%s'", call.Callee.Lexeme)
+		return AnyType, nil
+	}
+	if err := f.CheckArity(len(call.Arguments)); err != nil {
+		c.report(call, "%s", err.Error())
+		return fromSignature(f.Result), nil
+	}
+	for i, argType := range argTypes {
+		param := fromSignature(f.Param(i))
+		if param.Kind == Int && argType.IsNumeric() {
+			continue
+		}
+		if !AssignableTo(argType, param) {
+			c.report(call.Arguments[i], "%s() argument %d must be %s, got %s", f.Name, i+1, param, argType)
+		}
+	}
+	return fromSignature(f.Result), nil
+}
+
+// byName checks a call to a built-in that takes a variable name, such as defined(NAME).
+func (c *Checker) byName(f *builtin.Function, call *ast.CallExpression) *Type {
+	if err := f.CheckArity(len(call.Arguments)); err != nil {
+		c.report(call, "%s", err.Error())
+	} else if _, ok := call.Arguments[0].(*ast.VariableExpression); !ok {
+		c.report(call.Arguments[0], "%s() takes a variable name, e.g. %s(CUDA_VERSION)", f.Name, f.Name)
+	}
+	return fromSignature(f.Result)
+}
+
+// fromSignature converts a type name from a builtin signature; builtin cannot import this package.
+func fromSignature(name string) *Type {
+	t, err := Parse(name)
+	if err != nil {
+		return AnyType
+	}
+	return t
+}
+
+// VisitMemberExpr types a version component. Other types have no components.
+func (c *Checker) VisitMemberExpr(member *ast.MemberExpression) (any, error) {
+	object := c.typeOf(member.Object)
+	name := member.Name.Lexeme
+	switch object.Kind {
+	case Any:
+		return AnyType, nil
+	case Semver:
+		switch name {
+		case "major", "minor", "patch":
+			return IntType, nil
+		case "prerelease":
+			return StringType, nil
+		}
+		c.report(member, "semver has no component '%s', want major, minor, patch or prerelease", name)
+		return AnyType, nil
+	}
+	c.report(member, "%s has no component '%s'", object, name)
+	return AnyType, nil
+}
@@ -0,0 +1,195 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"docklett/compiler/ast"
+	compileError "docklett/compiler/error"
+	"docklett/compiler/parser"
+	"docklett/compiler/scanner"
+)
+
+func parseSource(t *testing.T, source string) []ast.Statement {
+	t.Helper()
+
+	s := scanner.Scanner{SourceName: "test.dock", Source: source}
+	if err := s.ScanSource(); err != nil {
+		t.Fatalf("scan source: %v", err)
+	}
+	var p parser.Parser
+	statements, err := p.Parse(s.Tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return statements
+}
+
+// typeErrors returns "line:col-col message" for every type error, in order.
+func typeErrors(t *testing.T, err error) []string {
+	t.Helper()
+
+	var messages []string
+	if err == nil {
+		return messages
+	}
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var typeErr *compileError.TypeError
+		if !errors.As(e, &typeErr) {
+			t.Fatalf("error = %v, want *TypeError", e)
+		}
+		pos, end := typeErr.Node.Pos(), typeErr.Node.End()
+		messages = append(messages, fmt.Sprintf("%d:%d-%d %s", pos.Line, pos.Col, end.Col, typeErr.Message))
+	}
+	return messages
+}
+
+func TestCheck_Diagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		config Config
+		want   []string
+	}{
+		{"annotated declarations", "@SET port: int = 8080\n@SET pkgs: [string] = [\"curl\", \"git\"]\n@SET ratio: float = 1\n", Config{}, nil},
+		{"compare string with number", "@SET name = \"api\"\n@IF name == 8080\n@END\n", Config{},
+			[]string{"2:5-17 cannot compare string with int"}},
+		{"ordered compare", "@IF \"a\" < 1\n@END\n", Config{},
+			[]string{"1:5-12 cannot compare string with int using <"}},
+		{"declaration mismatch", "@SET port: int = \"80\"\n", Config{},
+			[]string{"1:18-22 cannot use string as int in declaration of 'port'"}},
+		{"array element mismatch", "@SET pkgs: [string] = [1, 2]\n", Config{},
+			[]string{"1:23-29 cannot use [int] as [string] in declaration of 'pkgs'"}},
+		{"mixed array left to evaluation", "@SET pkgs: [string] = [\"curl\", 1]\n", Config{}, nil},
+		{"unknown type", "@SET x: dict = 1\n", Config{},
+			[]string{"1:9-13 unknown type 'dict'"}},
+		{"assignment to annotated", "@SET port: int = 80\nport = \"80\"\n", Config{},
+			[]string{"2:8-12 cannot assign string to 'port' (declared int)"}},
+		{"unannotated reassignment", "@SET x = 1\nx = \"a\"\n@IF x == \"a\"\n@END\n", Config{}, nil},
+		{"loop variable inferred", "@FOR p IN [\"a\"]\n@IF p > 1\n@END\n@END\n", Config{},
+			[]string{"2:5-10 cannot compare string with int using >"}},
+		{"range over string", "@FOR p IN \"abc\"\n@END\n", Config{},
+			[]string{"1:11-16 cannot iterate over string, @FOR needs an array or range"}},
+		{"invalid arithmetic", "@SET x = \"a\" - 1\n", Config{},
+			[]string{"1:10-17 invalid operation: string - int"}},
+		{"predeclared names are any", "@IF MODE == 1\n@END\n", Config{}, nil},
+		{"implicit truthiness allowed", "@SET n = 3\n@IF n\n@END\n", Config{}, nil},
+		{"implicit truthiness disabled", "@SET n = 3\n@IF n\n@ELIF n > 1 && \"x\"\n@END\n", Config{NoImplicitTruthiness: true},
+			[]string{"2:5-6 condition must be bool, got int (implicit truthiness is disabled)",
+				"3:16-19 operand of && must be bool, got string (implicit truthiness is disabled)"}},
+		{"calls", "@SET tag: string = pad(1 + 1, 3, \"0\")\n@SET parts: [string] = split(tag, \".\")\n", Config{}, nil},
+		{"undefined function", "@SET x = uper(\"a\")\n", Config{},
+			[]string{"1:10-19 undefined function 'uper'"}},
+		{"call arity", "@SET x = trim()\n", Config{},
+			[]string{"1:10-16 trim() takes 1 to 2 arguments, got 0"}},
+		{"call argument type", "@SET n = 3\n@SET x = upper(n)\n", Config{},
+			[]string{"2:16-17 upper() argument 1 must be string, got int"}},
+		{"call result type", "@IF startswith(\"a\", \"b\") == \"yes\"\n@END\n", Config{},
+			[]string{"1:5-34 cannot compare bool with string"}},
+		{"collections", "@SET m: map = {\"a\": 1}\n@SET n: int = len(keys(m))\n@SET groups: [[any]] = chunk([1, 2], n)\n", Config{}, nil},
+		{"map key type", "@SET m = {1: \"a\"}\n", Config{},
+			[]string{"1:11-12 map key must be a string, got int"}},
+		{"comprehensions", "@SET l: [string] = [p + \"-dev\" for p in [\"a\"] if p != \"b\"]\n" +
+			"@SET ks: [string] = [k for k, v in {\"a\": 1}]\n@SET is: [int] = [i for i, p in [\"a\"]]\n", Config{}, nil},
+		{"comprehension element type", "@SET l: [int] = [p for p in [\"a\"]]\n", Config{},
+			[]string{"1:17-35 cannot use [string] as [int] in declaration of 'l'"}},
+		{"comprehension iterable", "@SET l = [c for c in \"abc\"]\n", Config{},
+			[]string{"1:22-27 cannot iterate over string, a comprehension needs an array, range or map"}},
+		{"comprehension filter", "@SET l = [c for c in [1] if c]\n", Config{NoImplicitTruthiness: true},
+			[]string{"1:29-30 comprehension filter must be bool, got int (implicit truthiness is disabled)"}},
+		{"map argument", "@SET k = keys([1])\n", Config{},
+			[]string{"1:15-18 keys() argument 1 must be map, got [int]"}},
+		{"semver", "@SET v: semver = semver(\"1.2.3\")\n@SET m: int = v.major\n@IF v >= \"1.0.0\" && satisfies(v, \"^1\")\n@END\n", Config{}, nil},
+		{"semver comparison", "@IF semver(\"1.2.3\") > 1\n@END\n", Config{},
+			[]string{"1:5-24 cannot compare semver with int using >"}},
+		{"component of int", "@SET n = 1\n@SET m = n.major\n", Config{},
+			[]string{"2:10-17 int has no component 'major'"}},
+		{"unknown component", "@SET m = semver(\"1.0.0\").build\n", Config{},
+			[]string{"1:10-31 semver has no component 'build', want major, minor, patch or prerelease"}},
+		{"coalesce", "@SET n: int = CUDA ?? 1\n@SET s: string = \"a\" ?? \"b\"\n@IF defined(CUDA)\n@END\n", Config{}, nil},
+		{"defined of a value", "@SET d = defined(\"CUDA\")\n", Config{},
+			[]string{"1:18-24 defined() takes a variable name, e.g. defined(CUDA_VERSION)"}},
+		{"defined arity", "@SET d = defined(A, B)\n", Config{},
+			[]string{"1:10-23 defined() takes 1 argument, got 2"}},
+		{"in", "@SET pkgs = [\"curl\"]\n@IF \"curl\" in pkgs and \"a\" in \"abc\" or \"k\" in {\"k\": 1}\n@END\n", Config{}, nil},
+		{"in array of another type", "@SET pkgs = [\"curl\"]\n@IF 1 in pkgs\n@END\n", Config{},
+			[]string{"2:5-14 int is never in [string]"}},
+		{"in number", "@IF \"a\" in 1\n@END\n", Config{},
+			[]string{"1:5-13 'in' needs an array, map or string on the right, got int"}},
+		{"template expressions", "@SET PORT: int = 8080\nRUN echo ${trim(PORT + \"x\")} ${upper(PORT)} ${PORT}\n", Config{},
+			[]string{"2:17-27 invalid operation: int + string", "2:38-42 upper() argument 1 must be string, got int"}},
+		{"every branch", "@IF FALSE\n@SET a: bool = 1\n@ELSE\n@SET b: string = 2\n@END\n", Config{},
+			[]string{"2:16-17 cannot use int as bool in declaration of 'a'",
+				"4:18-19 cannot use int as string in declaration of 'b'"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Check(parseSource(t, test.source), test.config)
+			got := typeErrors(t, err)
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("errors = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheck_InfersTypes(t *testing.T) {
+	statements := parseSource(t, "@SET pkgs = [\"curl\", \"git\"]\n@SET r = range(0, 3)\n@SET n = 1 + 2.5\n@SET m = {\"a\": 1}\n@SET i = 7 // 2 * 3 % 4\n@SET q = 6 / 2\n")
+	info, err := Check(statements, Config{})
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+
+	want := []string{"[string]", "[int]", "float", "map[int]", "int", "float"}
+	for i, stmt := range statements {
+		init := stmt.(*ast.VariableDeclarationStatement).Initializer
+		if got := info.Types[init].String(); got != want[i] {
+			t.Errorf("statement %d: type %s, want %s", i, got, want[i])
+		}
+	}
+}
+
+func TestCheck_BuildVariables(t *testing.T) {
+	vars := map[string]any{"PORT": "http", "PKGS": []any{"curl", "git"}, "DEBUG": true, "VERSION": 7, "BUILD": "0042"}
+	source := "@DEFAULT PORT: int = 80\n@DEFAULT PKGS = [1]\n@DEFAULT VERSION = \"1.0\"\n@DEFAULT BUILD: int = 0\n" +
+		"@SET n = PKGS\n@SET d = DEBUG\n@SET v = VERSION\n@SET b = BUILD\n"
+	statements := parseSource(t, source)
+	info, err := Check(statements, Config{Vars: vars})
+
+	want := []string{"1:16-19 cannot use string value of build variable 'PORT' as int"}
+	if got := typeErrors(t, err); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("errors = %q, want %q", got, want)
+	}
+	// values are converted to the type of their @DEFAULT where they can be
+	for i, typ := range []string{"[string]", "bool", "string", "int"} {
+		init := statements[i+4].(*ast.VariableDeclarationStatement).Initializer
+		if got := info.Types[init].String(); got != typ {
+			t.Errorf("statement %d: type %s, want %s", i+4, got, typ)
+		}
+	}
+}
+
+func TestConforms(t *testing.T) {
+	tests := []struct {
+		value any
+		typ   *Type
+		want  bool
+	}{
+		{8080, IntType, true},
+		{8080, FloatType, true},
+		{1.5, IntType, false},
+		{"x", StringType, true},
+		{[]any{"a", "b"}, ArrayOf(StringType), true},
+		{[]any{"a", 1}, ArrayOf(StringType), false},
+		{nil, StringType, true},
+		{true, AnyType, true},
+	}
+
+	for _, test := range tests {
+		if got := Conforms(test.value, test.typ); got != test.want {
+			t.Errorf("Conforms(%#v, %s) = %v, want %v", test.value, test.typ, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+/*
+Package types defines Docklett's static types and the optional type checker.
+
+TYPES:
+
+	bool, int, float, string    scalar types
+	[T]                         array whose elements are all T
+	map                         map from strings to values of any type; map[T] when every value is a T
+	semver                      semantic version, see value.Version
+	nil                         the value of @SET x without an initializer
+	any                         unknown or mixed: accepted everywhere, checked at evaluation time
+
+Types mirror the values in package value: int and float64, string, bool, []any, map[string]any,
+value.Version and nil.
+Numeric types are compatible with each other (an int is accepted where a float is expected),
+matching the evaluator's int → float promotion.
+*/
+package types
+
+import (
+	"docklett/compiler/ast"
+	"docklett/compiler/value"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Kind identifies the shape of a Type.
+type Kind int
+
+const (
+	Any Kind = iota
+	Nil
+	Bool
+	Int
+	Float
+	String
+	Array
+	Map
+	Semver
+)
+
+// Type is a Docklett static type. Elem is only set for arrays (element type) and maps (value type).
+type Type struct {
+	Kind Kind
+	Elem *Type
+}
+
+var (
+	AnyType    = &Type{Kind: Any}
+	NilType    = &Type{Kind: Nil}
+	BoolType   = &Type{Kind: Bool}
+	IntType    = &Type{Kind: Int}
+	FloatType  = &Type{Kind: Float}
+	StringType = &Type{Kind: String}
+	SemverType = &Type{Kind: Semver}
+)
+
+// named maps type names usable in annotations to their types.
+var named = map[string]*Type{
+	"any":    AnyType,
+	"bool":   BoolType,
+	"int":    IntType,
+	"float":  FloatType,
+	"string": StringType,
+	"map":    MapOf(AnyType),
+	"semver": SemverType,
+}
+
+// ArrayOf returns the array type [elem].
+func ArrayOf(elem *Type) *Type {
+	return &Type{Kind: Array, Elem: elem}
+}
+
+// MapOf returns the type of maps whose values are elem.
+func MapOf(elem *Type) *Type {
+	return &Type{Kind: Map, Elem: elem}
+}
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case Nil:
+		return "nil"
+	case Bool:
+		return "bool"
+	case Int:
+		return "int"
+	case Float:
+		return "float"
+	case String:
+		return "string"
+	case Array:
+		return "[" + t.Elem.String() + "]"
+	case Map:
+		if t.Elem.Kind == Any {
+			return "map"
+		}
+		return "map[" + t.Elem.String() + "]"
+	case Semver:
+		return "semver"
+	default:
+		return "any"
+	}
+}
+
+// IsNumeric reports whether t is int or float.
+func (t *Type) IsNumeric() bool {
+	return t.Kind == Int || t.Kind == Float
+}
+
+// Identical reports whether two types are the same.
+func Identical(a, b *Type) bool {
+	if a.Kind != b.Kind {
+		return false
+	}
+	if a.Kind == Array || a.Kind == Map {
+		return Identical(a.Elem, b.Elem)
+	}
+	return true
+}
+
+// AssignableTo reports whether a value of type v may be stored in a binding of type t.
+//
+//	any ↔ everything     int → float     nil → everything     [v] → [t], map[v] → map[t] when v → t
+func AssignableTo(v, t *Type) bool {
+	switch {
+	case v.Kind == Any || t.Kind == Any || v.Kind == Nil:
+		return true
+	case v.Kind == Int && t.Kind == Float:
+		return true
+	case v.Kind == Array && t.Kind == Array, v.Kind == Map && t.Kind == Map:
+		return AssignableTo(v.Elem, t.Elem)
+	}
+	return v.Kind == t.Kind
+}
+
+// Comparable reports whether == and != between the two types can ever be true.
+// Numbers compare across int and float, versions with strings; nil and any compare with everything.
+func Comparable(a, b *Type) bool {
+	if a.IsNumeric() && b.IsNumeric() || Ordered(a, b) && (a.Kind == Semver || b.Kind == Semver) {
+		return true
+	}
+	return AssignableTo(a, b) || AssignableTo(b, a)
+}
+
+// Ordered reports whether <, <=, > and >= apply to the two types: numbers, strings, or a version
+// and a version or string. Operands of type any may be anything.
+func Ordered(a, b *Type) bool {
+	switch {
+	case a.Kind == Any || b.Kind == Any:
+		return true
+	case a.IsNumeric() && b.IsNumeric():
+		return true
+	case a.Kind == Semver:
+		return b.Kind == Semver || b.Kind == String
+	case b.Kind == Semver:
+		return a.Kind == String
+	}
+	return a.Kind == String && b.Kind == String
+}
+
+// Join returns the common type of two values, e.g. for the elements of an array literal.
+// Identical types join to themselves, int and float to float, anything else to any.
+func Join(a, b *Type) *Type {
+	switch {
+	case Identical(a, b):
+		return a
+	case a.IsNumeric() && b.IsNumeric():
+		return FloatType
+	case a.Kind == Nil:
+		return b
+	case b.Kind == Nil:
+		return a
+	case a.Kind == Array && b.Kind == Array:
+		return ArrayOf(Join(a.Elem, b.Elem))
+	case a.Kind == Map && b.Kind == Map:
+		return MapOf(Join(a.Elem, b.Elem))
+	}
+	return AnyType
+}
+
+// FromAnnotation converts a parsed type annotation, rejecting unknown type names.
+func FromAnnotation(annotation *ast.TypeAnnotation) (*Type, error) {
+	if annotation.IsArray() {
+		elem, err := FromAnnotation(annotation.Element)
+		if err != nil {
+			return nil, err
+		}
+		return ArrayOf(elem), nil
+	}
+	t, ok := named[annotation.Name.Lexeme]
+	if !ok {
+		return nil, fmt.Errorf("unknown type '%s'", annotation.Name.Lexeme)
+	}
+	return t, nil
+}
+
+// Parse returns the type spelled name, as in an annotation: "int", "[string]", "[[any]]".
+func Parse(name string) (*Type, error) {
+	if strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]") {
+		elem, err := Parse(name[1 : len(name)-1])
+		if err != nil {
+			return nil, err
+		}
+		return ArrayOf(elem), nil
+	}
+	t, ok := named[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown type '%s'", name)
+	}
+	return t, nil
+}
+
+// Of returns the type of a runtime value. Arrays and maps get the join of their element types.
+func Of(v any) *Type {
+	switch v := v.(type) {
+	case nil:
+		return NilType
+	case bool:
+		return BoolType
+	case int:
+		return IntType
+	case float64:
+		return FloatType
+	case string:
+		return StringType
+	case []any:
+		elem := AnyType
+		for i, e := range v {
+			if i == 0 {
+				elem = Of(e)
+			} else {
+				elem = Join(elem, Of(e))
+			}
+		}
+		return ArrayOf(elem)
+	case map[string]any:
+		elem := AnyType
+		for i, key := range value.SortedKeys(v) {
+			if i == 0 {
+				elem = Of(v[key])
+			} else {
+				elem = Join(elem, Of(v[key]))
+			}
+		}
+		return MapOf(elem)
+	case value.Version:
+		return SemverType
+	}
+	return AnyType
+}
+
+// Conforms reports whether a runtime value fits a declared type, used to enforce annotations during evaluation.
+func Conforms(v any, t *Type) bool {
+	if arr, ok := v.([]any); ok && t.Kind == Array {
+		for _, e := range arr {
+			if !Conforms(e, t.Elem) {
+				return false
+			}
+		}
+		return true
+	}
+	if m, ok := v.(map[string]any); ok && t.Kind == Map {
+		for _, e := range m {
+			if !Conforms(e, t.Elem) {
+				return false
+			}
+		}
+		return true
+	}
+	if _, isNumber := value.ToFloat(v); isNumber && t.Kind == Float {
+		return true
+	}
+	return AssignableTo(Of(v), t)
+}
+
+// BuildVariable converts the value of a build variable to the type of the @DEFAULT it replaces: the
+// annotated type, or the type of a literal initializer. Values read from text are typed before the
+// file is known, so -var V=7 becomes the string "7" for @DEFAULT V = "1.0" and stays the int 7 for
+// @DEFAULT V = 1; "007" becomes 7 for an int. A value that does not convert is returned unchanged.
+func BuildVariable(stmt *ast.VariableDeclarationStatement, v any) any {
+	var target *Type
+	if stmt.Type != nil {
+		declared, err := FromAnnotation(stmt.Type)
+		if err != nil {
+			return v
+		}
+		target = declared
+	} else if literal, ok := stmt.Initializer.(*ast.LiteralExpression); ok {
+		target = Of(literal.Value)
+	} else {
+		return v
+	}
+
+	text, isText := v.(string)
+	switch target.Kind {
+	case String:
+		switch v.(type) {
+		case bool, int, float64:
+			return value.Stringify(v)
+		}
+	case Int:
+		if i, err := strconv.Atoi(text); isText && err == nil {
+			return i
+		}
+	case Float:
+		if f, err := strconv.ParseFloat(text, 64); isText && err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return f
+		}
+	}
+	return v
+}
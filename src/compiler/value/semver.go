@@ -0,0 +1,182 @@
+package value
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version (https://semver.org), produced by the semver() built-in.
+// Versions order by precedence rather than as text, so semver("10.0.0") > semver("9.1.0"):
+//
+//	1.0.0-alpha < 1.0.0-alpha.1 < 1.0.0-beta < 1.0.0-rc.1 < 1.0.0 < 1.0.1 < 1.1.0
+//
+// Build metadata ("+build.5") is kept for printing but ignored when comparing.
+type Version struct {
+	Major, Minor, Patch int
+	Prerelease          []string // dot-separated identifiers after "-", nil for a release
+	Build               string   // text after "+", without the "+"
+}
+
+// ParseVersion parses MAJOR.MINOR.PATCH[-PRERELEASE][+BUILD]. A leading "v", as printed by
+// `node --version` and git tags, is accepted and dropped.
+func ParseVersion(text string) (Version, error) {
+	invalid := func(reason string) (Version, error) {
+		return Version{}, fmt.Errorf("invalid semantic version %q: %s", text, reason)
+	}
+
+	s := strings.TrimPrefix(text, "v")
+	var v Version
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s, v.Build = s[:i], s[i+1:]
+		if !validIdentifiers(v.Build, false) {
+			return invalid("build metadata must be dot-separated alphanumeric identifiers")
+		}
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		var pre string
+		s, pre = s[:i], s[i+1:]
+		if !validIdentifiers(pre, true) {
+			return invalid("pre-release must be dot-separated alphanumeric identifiers")
+		}
+		v.Prerelease = strings.Split(pre, ".")
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return invalid("want MAJOR.MINOR.PATCH")
+	}
+	numbers := [3]*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, ok := numericIdentifier(part)
+		if !ok {
+			return invalid(fmt.Sprintf("%q is not a version number", part))
+		}
+		*numbers[i] = n
+	}
+	return v, nil
+}
+
+// validIdentifiers reports whether s is a non-empty, dot-separated list of [0-9A-Za-z-] identifiers.
+// Pre-release identifiers that are numeric must not have leading zeros.
+func validIdentifiers(s string, prerelease bool) bool {
+	for _, ident := range strings.Split(s, ".") {
+		if ident == "" {
+			return false
+		}
+		for _, r := range ident {
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-') {
+				return false
+			}
+		}
+		if _, err := strconv.Atoi(ident); prerelease && err == nil && len(ident) > 1 && ident[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
+// numericIdentifier parses a version number: digits only, no leading zeros.
+func numericIdentifier(s string) (int, bool) {
+	if s == "" || len(s) > 1 && s[0] == '0' {
+		return 0, false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
+
+// Compare returns -1, 0 or 1 as v has lower, equal or higher precedence than other.
+func (v Version) Compare(other Version) int {
+	for _, pair := range [][2]int{{v.Major, other.Major}, {v.Minor, other.Minor}, {v.Patch, other.Patch}} {
+		if c := compareInts(pair[0], pair[1]); c != 0 {
+			return c
+		}
+	}
+	// a release has higher precedence than any of its pre-releases
+	switch {
+	case len(v.Prerelease) == 0 && len(other.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(other.Prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Prerelease) && i < len(other.Prerelease); i++ {
+		if c := compareIdentifiers(v.Prerelease[i], other.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(v.Prerelease), len(other.Prerelease))
+}
+
+// compareIdentifiers orders numeric identifiers numerically and before alphanumeric ones,
+// which order as ASCII text.
+func compareIdentifiers(a, b string) int {
+	aNum, aErr := strconv.Atoi(a)
+	bNum, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareInts(aNum, bNum)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// String formats v in canonical form, without a leading "v": 1.2.3-rc.1+build.5
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Field returns the named component of v: major, minor and patch are ints, prerelease is the
+// text after "-" ("" for a release).
+func (v Version) Field(name string) (any, bool) {
+	switch name {
+	case "major":
+		return v.Major, true
+	case "minor":
+		return v.Minor, true
+	case "patch":
+		return v.Patch, true
+	case "prerelease":
+		return strings.Join(v.Prerelease, "."), true
+	}
+	return nil, false
+}
+
+// ToVersion converts a Version, or a string holding one, to a Version. Comparisons use it so
+// that semver(NODE_VERSION) >= "18.0.0" works without wrapping the literal.
+func ToVersion(v any) (Version, bool) {
+	switch v := v.(type) {
+	case Version:
+		return v, true
+	case string:
+		parsed, err := ParseVersion(v)
+		return parsed, err == nil
+	}
+	return Version{}, false
+}
@@ -0,0 +1,221 @@
+/*
+Package value defines the semantics shared by everything that handles Docklett values at compile time:
+the Interpreter, the Translator and the tools built on them.
+
+Docklett values are plain Go values:
+
+	bool        TRUE, FALSE
+	int         42, loop indices produced by range()
+	float64     1.5, results of arithmetic
+	string      "alpine"
+	[]any       ["curl", "git"], range(0, 3)
+	map[string]any  {"os": "linux", "arch": "arm64"}
+	Version     semver("1.2.3"), see semver.go
+	nil         @SET x without an initializer
+
+Maps have no order of their own: everything that lists their entries (Stringify, keys(), values())
+goes through SortedKeys, so output never depends on Go's map iteration order.
+*/
+package value
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Truthy determines the boolean value of any value (truthiness), used by @IF, @ELIF and logical operators.
+//   - bool: returns the boolean value itself
+//   - nil: false
+//   - int/float64: false if zero, true otherwise
+//   - string: false if empty "", true otherwise
+//   - []any, map: false if empty, true otherwise
+//   - semver: always true
+//   - unknown types: true if not nil
+func Truthy(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case nil:
+		return false
+	case int:
+		return v != 0
+	case float64:
+		return v != 0.0
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	default:
+		// Unknown types are truthy if not nil
+		return v != nil
+	}
+}
+
+// Equal reports whether two values are equal. Equality works across all types:
+// numbers compare by value (5 == 5.0), arrays element by element, maps entry by entry,
+// and values of different kinds are unequal. The exception is a version, which equals a string
+// holding the same version: semver("1.2.3") == "1.2.3".
+func Equal(a, b any) bool {
+	if _, ok := ToFloat(a); ok {
+		c, ok := CompareNumbers(a, b)
+		return ok && c == 0
+	}
+	_, aVersion := a.(Version)
+	_, bVersion := b.(Version)
+	if aVersion || bVersion {
+		aVer, aOk := ToVersion(a)
+		bVer, bOk := ToVersion(b)
+		return aOk && bOk && aVer.Compare(bVer) == 0
+	}
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		bBool, ok := b.(bool)
+		return ok && a == bBool
+	case string:
+		bStr, ok := b.(string)
+		return ok && a == bStr
+	case []any:
+		bArr, ok := b.([]any)
+		if !ok || len(a) != len(bArr) {
+			return false
+		}
+		for i := range a {
+			if !Equal(a[i], bArr[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		bMap, ok := b.(map[string]any)
+		if !ok || len(a) != len(bMap) {
+			return false
+		}
+		for key, val := range a {
+			if bVal, found := bMap[key]; !found || !Equal(val, bVal) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// ToFloat converts an int or float64 to float64, reporting false for every other type.
+func ToFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// CompareNumbers returns -1, 0 or 1 as the number a is less than, equal to or greater than b, and
+// false if either is not a number. Two ints compare exactly, so 9007199254740993 > 9007199254740992;
+// an int and a float compare as floats.
+func CompareNumbers(a, b any) (int, bool) {
+	aInt, aIsInt := a.(int)
+	bInt, bIsInt := b.(int)
+	if aIsInt && bIsInt {
+		return cmp.Compare(aInt, bInt), true
+	}
+	aNum, aOk := ToFloat(a)
+	bNum, bOk := ToFloat(b)
+	if !aOk || !bOk {
+		return 0, false
+	}
+	return cmp.Compare(aNum, bNum), true
+}
+
+// ToInt converts an int, or a float64 holding a whole number, to int.
+func ToInt(v any) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
+
+// Stringify formats a value the way it appears in generated Docker instructions.
+//
+//	"alpine" → alpine     TRUE → true       nil → ""
+//	5.0      → 5          1.5  → 1.5        ["a", 1] → a 1
+//	{"b": 2, "a": 1}      → a=1 b=2
+//	semver("v1.2.3")      → 1.2.3
+func Stringify(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		parts := make([]string, len(v))
+		for i, elem := range v {
+			parts[i] = Stringify(elem)
+		}
+		return strings.Join(parts, " ")
+	case map[string]any:
+		parts := make([]string, 0, len(v))
+		for _, key := range SortedKeys(v) {
+			parts = append(parts, key+"="+Stringify(v[key]))
+		}
+		return strings.Join(parts, " ")
+	case Version:
+		return v.String()
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// SortedKeys returns the keys of a map in ascending order.
+func SortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TypeName returns the Docklett name of a value's type for error messages.
+func TypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bool"
+	case int:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "map"
+	case Version:
+		return "semver"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
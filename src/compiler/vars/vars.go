@@ -0,0 +1,340 @@
+/*
+Package vars reads build variables, the values given to a compilation from outside the file with
+-var NAME=VALUE and -var-file. They are bound in the global scope and replace the initializer of the
+@DEFAULT declaration of the same name.
+
+VALUES are typed, so -var PORT=8080 is an int and compares as one:
+
+	PORT=8080              int
+	RATIO=0.5              float
+	VERSION=1.10           string: a number that would print differently (1.1) stays text, so does 007
+	DEBUG=true             bool (true/false in any case)
+	PKGS=["curl", "git"]   array, written as JSON
+	LABELS={"a": 1}        map, written as JSON
+	TAG="8080"             string: JSON quotes keep digits text
+	MODE=prod              string, as is
+	EMPTY=                 empty string
+
+FILES are chosen by extension:
+
+	.json            one object: {"MODE": "prod", "PORT": 8080}
+	.yaml, .yml      a subset of YAML: "NAME: value" lines, with "- item" lines or a flow list
+	                 [curl, 'git', 3] for a list, # comments, quoted strings, null or ~; a map
+	                 is written as JSON {..}, and so is a list nested in a flow list
+	anything else    .env lines: NAME=VALUE, optionally after "export ", # comments,
+	                 '...' or "..." for strings
+
+Unquoted values in YAML and .env files are typed like -var values. The value then replaces a @DEFAULT,
+which converts it to its type, see types.BuildVariable: -var V=7 is the string "7" for
+@DEFAULT V = "1.0".
+*/
+package vars
+
+import (
+	"bytes"
+	"docklett/compiler/token"
+	"docklett/compiler/value"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseAssignment splits NAME=VALUE and types the value.
+func ParseAssignment(text string) (string, any, error) {
+	name, raw, found := strings.Cut(text, "=")
+	name = strings.TrimSpace(name)
+	if !found {
+		return "", nil, fmt.Errorf("%q must have the form NAME=VALUE", text)
+	}
+	if err := checkName(name); err != nil {
+		return "", nil, err
+	}
+	val, err := ParseValue(raw)
+	if err != nil {
+		return "", nil, fmt.Errorf("variable %s: %w", name, err)
+	}
+	return name, val, nil
+}
+
+// ParseValue types the text of one value, see the package documentation.
+func ParseValue(text string) (any, error) {
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "":
+		return "", nil
+	case strings.EqualFold(text, "true"):
+		return true, nil
+	case strings.EqualFold(text, "false"):
+		return false, nil
+	case strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") || strings.HasPrefix(text, `"`):
+		return decodeJSON([]byte(text))
+	}
+	// a number is only kept when it prints back as the same text: 1.10 and 007 stay strings
+	if i, err := strconv.Atoi(text); err == nil && value.Stringify(i) == text {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) && value.Stringify(f) == text {
+		return f, nil
+	}
+	return text, nil
+}
+
+// ReadFile reads a variable file, picking the format from its extension.
+func ReadFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var vars map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		vars, err = parseJSONFile(data)
+	case ".yaml", ".yml":
+		vars, err = parseYAML(data)
+	default:
+		vars, err = parseEnv(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return vars, nil
+}
+
+// checkName accepts identifiers that can be referenced from a Docklett expression.
+func checkName(name string) error {
+	valid := name != ""
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && unicode.IsDigit(r))) {
+			valid = false
+		}
+	}
+	if _, keyword := token.LookupDocklettKeyword(name); !valid || keyword {
+		return fmt.Errorf("invalid variable name %q", name)
+	}
+	return nil
+}
+
+// decodeJSON decodes one JSON value into Docklett values: integral numbers become int.
+func decodeJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw any
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON value %s: %w", data, err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("invalid JSON value %s: text after the value", data)
+	}
+	return fromJSON(raw), nil
+}
+
+func fromJSON(raw any) any {
+	switch v := raw.(type) {
+	case json.Number:
+		if i, err := strconv.Atoi(v.String()); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i := range v {
+			v[i] = fromJSON(v[i])
+		}
+	case map[string]any:
+		for key := range v {
+			v[key] = fromJSON(v[key])
+		}
+	}
+	return raw
+}
+
+func parseJSONFile(data []byte) (map[string]any, error) {
+	val, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	object, ok := val.(map[string]any)
+	if !ok {
+		return nil, errors.New("a JSON variable file must hold one object")
+	}
+	for name := range object {
+		if err := checkName(name); err != nil {
+			return nil, err
+		}
+	}
+	return object, nil
+}
+
+// parseEnv reads NAME=VALUE lines.
+func parseEnv(data []byte) (map[string]any, error) {
+	vars := make(map[string]any)
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, raw, found := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !found {
+			return nil, fmt.Errorf("line %d: expected NAME=VALUE", n+1)
+		}
+		if err := checkName(name); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		val, err := envValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		vars[name] = val
+	}
+	return vars, nil
+}
+
+// envValue reads a .env value: quoted text is a string, anything else is typed after dropping a " #" comment.
+func envValue(raw string) (any, error) {
+	if len(raw) >= 2 && raw[0] == '\'' && strings.HasSuffix(raw, "'") {
+		return raw[1 : len(raw)-1], nil
+	}
+	if strings.HasPrefix(raw, `"`) {
+		return strconv.Unquote(raw)
+	}
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = raw[:i]
+	}
+	return ParseValue(raw)
+}
+
+// parseYAML reads a top-level mapping of scalars, flow values and block lists of scalars.
+func parseYAML(data []byte) (map[string]any, error) {
+	vars := make(map[string]any)
+	var list string // name of the key whose "- item" lines are being read
+	for n, line := range strings.Split(string(data), "\n") {
+		text := strings.TrimSpace(stripYAMLComment(line))
+		if text == "" || text == "---" {
+			continue
+		}
+		if strings.HasPrefix(text, "- ") || text == "-" {
+			if list == "" {
+				return nil, fmt.Errorf("line %d: list item outside of a list", n+1)
+			}
+			val, err := yamlScalar(strings.TrimSpace(strings.TrimPrefix(text, "-")))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			vars[list] = append(vars[list].([]any), val)
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return nil, fmt.Errorf("line %d: nested mappings are not supported, write the value as {...}", n+1)
+		}
+
+		name, raw, found := strings.Cut(text, ":")
+		name = strings.TrimSpace(name)
+		if !found {
+			return nil, fmt.Errorf("line %d: expected NAME: value", n+1)
+		}
+		if err := checkName(name); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			list = name
+			vars[name] = []any{}
+			continue
+		}
+		list = ""
+		val, err := yamlScalar(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		vars[name] = val
+	}
+	return vars, nil
+}
+
+// yamlScalar types one YAML value; single quotes double a quote inside, as in YAML.
+func yamlScalar(raw string) (any, error) {
+	switch {
+	case raw == "null" || raw == "~":
+		return nil, nil
+	case len(raw) >= 2 && raw[0] == '\'' && strings.HasSuffix(raw, "'"):
+		return strings.ReplaceAll(raw[1:len(raw)-1], "''", "'"), nil
+	case strings.HasPrefix(raw, "["):
+		return yamlFlowList(raw)
+	}
+	return ParseValue(raw)
+}
+
+// yamlFlowList reads a flow list such as [curl, 'git', 3], whose items are typed like other scalars.
+// A JSON array is read as JSON, so lists may only be nested in that form.
+func yamlFlowList(raw string) (any, error) {
+	if val, err := decodeJSON([]byte(raw)); err == nil {
+		return val, nil
+	}
+	if !strings.HasSuffix(raw, "]") {
+		return nil, fmt.Errorf("flow list %s has no closing ]", raw)
+	}
+	items := []any{}
+	inner := strings.TrimSpace(raw[1 : len(raw)-1])
+	if inner == "" {
+		return items, nil
+	}
+	for _, item := range splitFlowItems(inner) {
+		item = strings.TrimSpace(item)
+		if item == "" || strings.ContainsAny(item[:1], "[]{}") {
+			return nil, fmt.Errorf("invalid flow list %s: write nested lists and maps as JSON", raw)
+		}
+		val, err := yamlScalar(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, val)
+	}
+	return items, nil
+}
+
+// splitFlowItems splits the inside of a flow list at the commas outside quotes.
+func splitFlowItems(text string) []string {
+	var items []string
+	var quote rune
+	start := 0
+	for i, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ',':
+			items = append(items, text[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, text[start:])
+}
+
+// stripYAMLComment drops a "#" comment that starts a line or follows a space, outside quotes.
+func stripYAMLComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
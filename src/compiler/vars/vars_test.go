@@ -0,0 +1,119 @@
+package vars
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAssignment(t *testing.T) {
+	tests := []struct {
+		text string
+		name string
+		want any
+	}{
+		{"PORT=8080", "PORT", 8080},
+		{"RATIO=0.5", "RATIO", 0.5},
+		{"OFFSET=-3", "OFFSET", -3},
+		{"VERSION=1.10", "VERSION", "1.10"},
+		{"BUILD=007", "BUILD", "007"},
+		{"SIZE=1e3", "SIZE", "1e3"},
+		{"DEBUG=TRUE", "DEBUG", true},
+		{"OFF=false", "OFF", false},
+		{`PKGS=["curl", "git", 3]`, "PKGS", []any{"curl", "git", 3}},
+		{`LABELS={"a": 1, "b": [2.5]}`, "LABELS", map[string]any{"a": 1, "b": []any{2.5}}},
+		{`TAG="8080"`, "TAG", "8080"},
+		{"MODE=prod", "MODE", "prod"},
+		{"URL=https://x.io/?a=b", "URL", "https://x.io/?a=b"},
+		{"EMPTY=", "EMPTY", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			name, got, err := ParseAssignment(test.text)
+			if err != nil {
+				t.Fatalf("ParseAssignment: %v", err)
+			}
+			if name != test.name || !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %s = %#v, want %s = %#v", name, got, test.name, test.want)
+			}
+		})
+	}
+}
+
+func TestParseAssignment_Errors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"MODE", "must have the form NAME=VALUE"},
+		{"1MODE=x", `invalid variable name "1MODE"`},
+		{"MY-VAR=x", `invalid variable name "MY-VAR"`},
+		{"in=x", `invalid variable name "in"`},
+		{"PKGS=[1, 2", "invalid JSON value"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			_, _, err := ParseAssignment(test.text)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	want := map[string]any{"MODE": "prod", "PORT": 8080, "PKGS": []any{"curl", "git"}, "TAG": "1.0"}
+	files := map[string]string{
+		"vars.json": `{"MODE": "prod", "PORT": 8080, "PKGS": ["curl", "git"], "TAG": "1.0"}`,
+		"vars.yaml": "# build settings\n---\nMODE: prod\nPORT: 8080  # http\nPKGS:\n  - curl\n  - 'git'\nTAG: \"1.0\"\n",
+		"vars.yml":  "MODE: 'prod'\nPORT: 8080\nPKGS: [\"curl\", \"git\"]\nTAG: '1.0'\n",
+		"flow.yaml": "MODE: prod\nPORT: 8080\nPKGS: [curl, 'git']\nTAG: '1.0'\n",
+		".env":      "# build settings\nexport MODE=prod\nPORT=8080 # http\nPKGS=[\"curl\", \"git\"]\nTAG='1.0'\n",
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadFile = %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadFile_Errors(t *testing.T) {
+	files := map[string]string{
+		"list.json":   `["MODE"]`,
+		"name.json":   `{"my-var": 1}`,
+		"nested.yaml": "IMAGE:\n  name: alpine\n",
+		"item.yaml":   "- curl\n",
+		"flow.yaml":   "PKGS: [curl, [git]]\n",
+		"open.yaml":   "PKGS: [curl, git\n",
+		"line.env":    "MODE prod\n",
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ReadFile(path); err == nil || !strings.Contains(err.Error(), name) {
+				t.Errorf("error = %v, want an error naming %s", err, name)
+			}
+		})
+	}
+}
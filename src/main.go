@@ -1,22 +1,77 @@
 package main
 
 import (
+	"context"
 	"docklett/cli"
 	"docklett/compiler"
+	"docklett/compiler/translator"
+	"encoding/json"
 	"fmt"
 	"os"
 )
 
 func main() {
 	commandLine := cli.NewCommandLine()
-	err := commandLine.ParseArgs()
+	err := commandLine.ParseArgs(os.Args[1:])
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
+	if commandLine.Command == cli.CommandFmt {
+		if err := commandLine.RunFmt(os.Stdin, os.Stdout, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if commandLine.Command == cli.CommandPreview {
+		if err := commandLine.RunPreview(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if commandLine.Command == cli.CommandREPL {
+		if err := commandLine.RunREPL(os.Stdin, os.Stdout, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	comp := compiler.NewCompiler()
-	err = comp.Run(commandLine.FilePath)
+	comp.Strict = commandLine.Strict
+	comp.TypeConfig.NoImplicitTruthiness = commandLine.NoImplicitTruthiness
+	comp.StrictInterpolation = commandLine.StrictInterpolation
+	comp.ContextRoot = commandLine.ContextRoot
+	comp.AllowEnv = commandLine.AllowEnv
+	comp.Vars = commandLine.Vars
+	comp.Limits = commandLine.Limits()
+	switch commandLine.Trace {
+	case cli.TraceText:
+		comp.Trace = translator.TextTrace(os.Stdout)
+	case cli.TraceJSON:
+		comp.Trace = translator.JSONTrace(os.Stdout)
+	}
+	ctx := context.Background()
+	if commandLine.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, commandLine.Timeout)
+		defer cancel()
+	}
+	err = comp.RunContext(ctx, commandLine.FilePath)
+	for _, warning := range comp.Warnings {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+	if commandLine.ReportPath != "" {
+		if reportErr := writeReport(commandLine.ReportPath, comp.Report); reportErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", reportErr)
+			os.Exit(1)
+		}
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Compilation failed: %v\n", err)
 		os.Exit(1)
@@ -27,3 +82,12 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// writeReport saves the compile report as indented JSON.
+func writeReport(path string, report compiler.Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}